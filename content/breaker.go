@@ -0,0 +1,162 @@
+package content
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling a downstream service which is considered unavailable.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// CircuitBreaker stops calling a downstream service after a number of consecutive failures.
+// Once the open timeout passes a single trial call is let through, which closes the breaker again if it succeeds.
+// A nil *CircuitBreaker lets every call through.
+type CircuitBreaker struct {
+	lock        sync.Mutex
+	name        string
+	threshold   int
+	openTimeout time.Duration
+	state       BreakerState
+	failures    int
+	lastErr     error
+	openedAt    time.Time
+	trialCall   bool
+	now         func() time.Time
+}
+
+func NewCircuitBreaker(name string, threshold int, openTimeout time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return &CircuitBreaker{
+		name:        name,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		state:       BreakerClosed,
+		now:         time.Now,
+	}
+}
+
+// Do makes the call unless the breaker is open and records whether the downstream service was available.
+func (b *CircuitBreaker) Do(call func() error) error {
+	if b == nil {
+		return call()
+	}
+
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := call()
+	b.record(err)
+	return err
+}
+
+// State returns the state of the breaker. An open breaker whose timeout has passed is reported as half-open.
+func (b *CircuitBreaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Available tells whether the breaker would let a call through.
+func (b *CircuitBreaker) Available() bool {
+	return b.State() != BreakerOpen
+}
+
+func (b *CircuitBreaker) CheckHealth() (string, error) {
+	b.lock.Lock()
+	lastErr := b.lastErr
+	b.lock.Unlock()
+
+	switch b.State() {
+	case BreakerOpen:
+		return "", fmt.Errorf("circuit breaker for %s is open: %v", b.name, lastErr)
+	case BreakerHalfOpen:
+		return fmt.Sprintf("Circuit breaker for %s is half-open", b.name), nil
+	default:
+		return fmt.Sprintf("Circuit breaker for %s is closed", b.name), nil
+	}
+}
+
+func (b *CircuitBreaker) allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return fmt.Errorf("%w: %s is unavailable", ErrCircuitOpen, b.name)
+		}
+		b.state = BreakerHalfOpen
+		b.trialCall = true
+		return nil
+	case BreakerHalfOpen:
+		if b.trialCall {
+			return fmt.Errorf("%w: %s is unavailable", ErrCircuitOpen, b.name)
+		}
+		b.trialCall = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !isUnavailable(err) {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.trialCall = false
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+		b.failures = 0
+		b.trialCall = false
+	}
+}
+
+// isUnavailable tells whether an error means that a downstream service could not serve the request at all,
+// as opposed to it rejecting a particular document.
+func isUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *UnexpectedStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// IsTransient tells whether a failed call may succeed if it is made again later, because the downstream service
+// was unavailable rather than rejecting the document.
+func IsTransient(err error) bool {
+	return !errors.Is(err, ErrUnchanged) && isUnavailable(err)
+}
@@ -0,0 +1,91 @@
+package content
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBreaker(threshold int) (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := NewCircuitBreaker("enriched content", threshold, 30*time.Second)
+	b.now = clock.Now
+	return b, clock
+}
+
+func TestCircuitBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(3)
+	unavailable := func() error {
+		return &UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: http.StatusServiceUnavailable}
+	}
+
+	_ = b.Do(unavailable)
+	_ = b.Do(unavailable)
+	assert.NoError(t, b.Do(func() error { return nil }))
+	_ = b.Do(unavailable)
+	_ = b.Do(unavailable)
+	assert.Equal(t, BreakerClosed, b.State())
+
+	_ = b.Do(unavailable)
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.False(t, b.Available())
+
+	called := false
+	err := b.Do(func() error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.False(t, called)
+
+	_, err = b.CheckHealth()
+	assert.EqualError(t, err, "circuit breaker for enriched content is open: fetching enriched content failed with unexpected status code: 503")
+}
+
+func TestCircuitBreaker_IgnoresRejectedDocuments(t *testing.T) {
+	b, _ := newTestBreaker(1)
+
+	_ = b.Do(func() error {
+		return &UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: http.StatusNotFound}
+	})
+
+	assert.Equal(t, BreakerClosed, b.State())
+	msg, err := b.CheckHealth()
+	assert.NoError(t, err)
+	assert.Equal(t, "Circuit breaker for enriched content is closed", msg)
+}
+
+func TestCircuitBreaker_TrialCallAfterTimeout(t *testing.T) {
+	b, clock := newTestBreaker(1)
+	_ = b.Do(func() error { return fmt.Errorf("connection refused") })
+	assert.Equal(t, BreakerOpen, b.State())
+
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	assert.True(t, b.Available())
+
+	// the failing trial call opens the breaker again
+	_ = b.Do(func() error { return fmt.Errorf("connection refused") })
+	assert.Equal(t, BreakerOpen, b.State())
+
+	clock.now = clock.now.Add(30 * time.Second)
+	err := b.Do(func() error {
+		// no other call is let through while the trial call is in flight
+		assert.ErrorIs(t, b.Do(func() error { return nil }), ErrCircuitOpen)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestCircuitBreaker_NilBreakerLetsCallsThrough(t *testing.T) {
+	var b *CircuitBreaker
+
+	err := b.Do(func() error { return fmt.Errorf("connection refused") })
+
+	assert.EqualError(t, err, "connection refused")
+	assert.True(t, b.Available())
+}
@@ -57,7 +57,7 @@ func (e *EnrichedContentFetcher) GetContent(uuid, tid string) ([]byte, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("fetching enriched content failed with unexpected status code: %d", resp.StatusCode)
+		return nil, &UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: resp.StatusCode}
 	}
 
 	return io.ReadAll(resp.Body)
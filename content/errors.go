@@ -0,0 +1,41 @@
+package content
+
+import "fmt"
+
+type Stage string
+
+const (
+	FetchStage  Stage = "fetch"
+	UploadStage Stage = "upload"
+)
+
+// ExportError tells at which stage of an export a document failed.
+type ExportError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *ExportError) Error() string {
+	switch e.Stage {
+	case FetchStage:
+		return fmt.Sprintf("getting content: %v", e.Err)
+	case UploadStage:
+		return fmt.Sprintf("uploading content: %v", e.Err)
+	default:
+		return e.Err.Error()
+	}
+}
+
+func (e *ExportError) Unwrap() error {
+	return e.Err
+}
+
+// UnexpectedStatusError is returned when a downstream service responds with an unexpected status code.
+type UnexpectedStatusError struct {
+	Operation  string
+	StatusCode int
+}
+
+func (e *UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("%s failed with unexpected status code: %d", e.Operation, e.StatusCode)
+}
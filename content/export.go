@@ -1,9 +1,6 @@
 package content
 
-import (
-	"fmt"
-	"strings"
-)
+import "strings"
 
 const DefaultDate = "0000-00-00"
 
@@ -12,35 +9,92 @@ type Stub struct {
 	CanBeDistributed        string
 	Publication             []string
 	EditorialDesk           string
+	LastModified            string
 }
 
 type Exporter struct {
-	fetcher fetcher
-	updater updater
+	fetcher       fetcher
+	updater       updater
+	limiter       *AdaptiveLimiter
+	fetchBreaker  *CircuitBreaker
+	updateBreaker *CircuitBreaker
+	hashes        HashIndex
 }
 
-func NewExporter(fetcher fetcher, updater updater) *Exporter {
+// NewExporter creates an Exporter whose content retrieval and upload calls share the given limiter
+// and are guarded by the circuit breaker of the respective service. Nil limiter and breakers are disabled.
+// If a hash index is given, content which is unchanged since its last upload is not uploaded again.
+func NewExporter(fetcher fetcher, updater updater, limiter *AdaptiveLimiter, fetchBreaker, updateBreaker *CircuitBreaker, hashes HashIndex) *Exporter {
 	return &Exporter{
-		fetcher: fetcher,
-		updater: updater,
+		fetcher:       fetcher,
+		updater:       updater,
+		limiter:       limiter,
+		fetchBreaker:  fetchBreaker,
+		updateBreaker: updateBreaker,
+		hashes:        hashes,
 	}
 }
 
+// Export uploads the content of the document. It returns ErrUnchanged without uploading it
+// if the content is identical to its last upload.
 func (e *Exporter) Export(tid string, doc *Stub) error {
-	payload, err := e.fetcher.GetContent(doc.UUID, tid)
+	return e.export(tid, doc, false)
+}
+
+// ForceExport uploads the content of the document even if it is unchanged since its last upload,
+// e.g. because it is known to be missing from S3.
+func (e *Exporter) ForceExport(tid string, doc *Stub) error {
+	return e.export(tid, doc, true)
+}
+
+func (e *Exporter) export(tid string, doc *Stub, force bool) error {
+	var payload []byte
+	err := e.fetchBreaker.Do(func() error {
+		return e.limiter.Do(func() (err error) {
+			payload, err = e.fetcher.GetContent(doc.UUID, tid)
+			return err
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("getting content: %w", err)
+		return &ExportError{Stage: FetchStage, Err: err}
+	}
+
+	hash := contentHash(doc.Date, payload)
+	if !force && e.hashes != nil {
+		if last, ok := e.hashes.Get(doc.UUID); ok && last == hash {
+			return ErrUnchanged
+		}
 	}
 
-	err = e.updater.Upload(payload, tid, doc.UUID, doc.Date)
+	err = e.updateBreaker.Do(func() error {
+		return e.limiter.Do(func() error {
+			return e.updater.Upload(payload, tid, doc.UUID, doc.Date)
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("uploading content: %w", err)
+		return &ExportError{Stage: UploadStage, Err: err}
+	}
+
+	if e.hashes != nil {
+		e.hashes.Set(doc.UUID, hash)
 	}
 	return nil
 }
 
 func (e *Exporter) Delete(uuid, tid string) error {
-	return e.updater.Delete(uuid, tid)
+	// Content published again after its deletion has to be uploaded even if it is unchanged
+	if e.hashes != nil {
+		e.hashes.Remove(uuid)
+	}
+
+	return e.updateBreaker.Do(func() error {
+		return e.updater.Delete(uuid, tid)
+	})
+}
+
+// Available tells whether none of the circuit breakers is open.
+func (e *Exporter) Available() bool {
+	return e.fetchBreaker.Available() && e.updateBreaker.Available()
 }
 
 func GetDateOrDefault(payload map[string]interface{}) string {
@@ -15,8 +15,8 @@ func TestExporterHandleContentWithValidContent(t *testing.T) {
 	fetcher := &mockFetcher{t: t, expectedUUID: stubUUID, expectedTid: tid, result: testData}
 	updater := &mockUpdater{t: t, expectedUUID: stubUUID, expectedTid: tid, expectedDate: date, expectedPayload: testData}
 
-	exporter := NewExporter(fetcher, updater)
-	err := exporter.Export(tid, &Stub{stubUUID, date, "", "", nil, ""})
+	exporter := NewExporter(fetcher, updater, nil, nil, nil, nil)
+	err := exporter.Export(tid, &Stub{stubUUID, date, "", "", nil, "", ""})
 
 	assert.NoError(t, err)
 	assert.True(t, fetcher.called)
@@ -31,11 +31,14 @@ func TestExporterHandleContentWithErrorFromFetcher(t *testing.T) {
 	fetcher := &mockFetcher{t: t, expectedUUID: stubUUID, expectedTid: tid, result: testData, err: fmt.Errorf("fetcher err")}
 	updater := &mockUpdater{t: t}
 
-	exporter := NewExporter(fetcher, updater)
-	err := exporter.Export(tid, &Stub{stubUUID, date, "", "", nil, ""})
+	exporter := NewExporter(fetcher, updater, nil, nil, nil, nil)
+	err := exporter.Export(tid, &Stub{stubUUID, date, "", "", nil, "", ""})
 
 	assert.Error(t, err)
 	assert.EqualError(t, err, "getting content: fetcher err")
+	var exportErr *ExportError
+	assert.ErrorAs(t, err, &exportErr)
+	assert.Equal(t, FetchStage, exportErr.Stage)
 	assert.True(t, fetcher.called)
 	assert.False(t, updater.called)
 }
@@ -48,15 +51,63 @@ func TestExporterHandleContentWithErrorFromUpdater(t *testing.T) {
 	fetcher := &mockFetcher{t: t, expectedUUID: stubUUID, expectedTid: tid, result: testData}
 	updater := &mockUpdater{t: t, expectedUUID: stubUUID, expectedTid: tid, expectedDate: date, expectedPayload: testData, err: fmt.Errorf("updater err")}
 
-	exporter := NewExporter(fetcher, updater)
-	err := exporter.Export(tid, &Stub{stubUUID, date, "", "", nil, ""})
+	exporter := NewExporter(fetcher, updater, nil, nil, nil, nil)
+	err := exporter.Export(tid, &Stub{stubUUID, date, "", "", nil, "", ""})
 
 	assert.Error(t, err)
 	assert.EqualError(t, err, "uploading content: updater err")
+	var exportErr *ExportError
+	assert.ErrorAs(t, err, &exportErr)
+	assert.Equal(t, UploadStage, exportErr.Stage)
 	assert.True(t, fetcher.called)
 	assert.True(t, updater.called)
 }
 
+func TestExporterSkipsUnchangedContent(t *testing.T) {
+	tid := "tid_1234"
+	stubUUID := "uuid1"
+	date := "2017-10-09"
+	testData := []byte(stubUUID)
+	fetcher := &mockFetcher{t: t, expectedUUID: stubUUID, expectedTid: tid, result: testData}
+	updater := &mockUpdater{t: t, expectedUUID: stubUUID, expectedTid: tid, expectedDate: date, expectedPayload: testData}
+	exporter := NewExporter(fetcher, updater, nil, nil, nil, NewMemoryHashIndex())
+	doc := &Stub{UUID: stubUUID, Date: date}
+
+	assert.NoError(t, exporter.Export(tid, doc))
+	assert.True(t, updater.called)
+
+	updater.called = false
+	assert.ErrorIs(t, exporter.Export(tid, doc), ErrUnchanged)
+	assert.False(t, updater.called)
+
+	assert.NoError(t, exporter.ForceExport(tid, doc))
+	assert.True(t, updater.called)
+
+	updater.called = false
+	fetcher.result = []byte("changed")
+	updater.expectedPayload = fetcher.result
+	assert.NoError(t, exporter.Export(tid, doc))
+	assert.True(t, updater.called)
+}
+
+func TestExporterUploadsDeletedContentAgain(t *testing.T) {
+	tid := "tid_1234"
+	stubUUID := "uuid1"
+	date := "2017-10-09"
+	testData := []byte(stubUUID)
+	fetcher := &mockFetcher{t: t, expectedUUID: stubUUID, expectedTid: tid, result: testData}
+	updater := &mockUpdater{t: t, expectedUUID: stubUUID, expectedTid: tid, expectedDate: date, expectedPayload: testData, deletable: true}
+	exporter := NewExporter(fetcher, updater, nil, nil, nil, NewMemoryHashIndex())
+	doc := &Stub{UUID: stubUUID, Date: date}
+
+	assert.NoError(t, exporter.Export(tid, doc))
+	assert.NoError(t, exporter.Delete(stubUUID, tid))
+
+	updater.called = false
+	assert.NoError(t, exporter.Export(tid, doc))
+	assert.True(t, updater.called)
+}
+
 type mockFetcher struct {
 	t                         *testing.T
 	expectedUUID, expectedTid string
@@ -78,6 +129,7 @@ type mockUpdater struct {
 	expectedPayload                         []byte
 	err                                     error
 	called                                  bool
+	deletable                               bool
 }
 
 func (u *mockUpdater) Upload(content []byte, tid, uuid, date string) error {
@@ -89,8 +141,13 @@ func (u *mockUpdater) Upload(content []byte, tid, uuid, date string) error {
 	return u.err
 }
 
-func (u *mockUpdater) Delete(_, _ string) error {
-	panic("should not be called")
+func (u *mockUpdater) Delete(uuid, tid string) error {
+	if !u.deletable {
+		panic("should not be called")
+	}
+	assert.Equal(u.t, u.expectedUUID, uuid)
+	assert.Equal(u.t, u.expectedTid, tid)
+	return nil
 }
 
 func TestGetDateWhenFirstPublishedDateIsPresent(t *testing.T) {
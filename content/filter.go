@@ -0,0 +1,77 @@
+package content
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	filterDateFormat = "2006-01-02"
+	// modifiedSinceFormat drops the fractional seconds and the zone of the stored timestamps,
+	// so that comparing with it includes everything modified within the given second.
+	modifiedSinceFormat = "2006-01-02T15:04:05"
+)
+
+// Filter narrows an export down to the documents matching every criterion which is set.
+// The first published date range includes both of its ends. ModifiedSince is an RFC 3339 timestamp.
+type Filter struct {
+	PublishedFrom string   `json:"publishedFrom,omitempty" yaml:"publishedFrom"`
+	PublishedTo   string   `json:"publishedTo,omitempty" yaml:"publishedTo"`
+	ContentTypes  []string `json:"contentTypes,omitempty" yaml:"contentTypes"`
+	Publications  []string `json:"publications,omitempty" yaml:"publications"`
+	EditorialDesk string   `json:"editorialDesk,omitempty" yaml:"editorialDesk"`
+	ModifiedSince string   `json:"modifiedSince,omitempty" yaml:"modifiedSince"`
+}
+
+func (f *Filter) Validate() error {
+	if f.PublishedFrom == "" && f.PublishedTo == "" && len(f.ContentTypes) == 0 && len(f.Publications) == 0 && f.EditorialDesk == "" && f.ModifiedSince == "" {
+		return fmt.Errorf("filter has no criteria")
+	}
+
+	if f.ModifiedSince != "" {
+		since, err := time.Parse(time.RFC3339, f.ModifiedSince)
+		if err != nil {
+			return fmt.Errorf("modifiedSince should be a timestamp like 2024-03-01T10:00:00Z")
+		}
+		if since.After(time.Now()) {
+			return fmt.Errorf("modifiedSince is in the future")
+		}
+	}
+
+	var from, to time.Time
+	var err error
+	if f.PublishedFrom != "" {
+		if from, err = time.Parse(filterDateFormat, f.PublishedFrom); err != nil {
+			return fmt.Errorf("publishedFrom should be a date like 2024-03-01")
+		}
+	}
+	if f.PublishedTo != "" {
+		if to, err = time.Parse(filterDateFormat, f.PublishedTo); err != nil {
+			return fmt.Errorf("publishedTo should be a date like 2024-03-31")
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return fmt.Errorf("publishedFrom is after publishedTo")
+	}
+	return nil
+}
+
+// PublishedBefore returns the day after PublishedTo, which is the exclusive upper bound of the first published date.
+// It returns an empty string if PublishedTo isn't set.
+func (f *Filter) PublishedBefore() string {
+	to, err := time.Parse(filterDateFormat, f.PublishedTo)
+	if err != nil {
+		return ""
+	}
+	return to.AddDate(0, 0, 1).Format(filterDateFormat)
+}
+
+// ModifiedSinceUTC returns ModifiedSince in the UTC format of the stored timestamps, without fractional seconds
+// and zone. It returns an empty string if ModifiedSince isn't set.
+func (f *Filter) ModifiedSinceUTC() string {
+	since, err := time.Parse(time.RFC3339, f.ModifiedSince)
+	if err != nil {
+		return ""
+	}
+	return since.UTC().Format(modifiedSinceFormat)
+}
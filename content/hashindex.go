@@ -0,0 +1,213 @@
+package content
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+// ErrUnchanged is returned instead of uploading content which is identical to its last upload.
+var ErrUnchanged = errors.New("content is unchanged since its last upload")
+
+// minCompactedLines is the size of the hash index file below which it isn't compacted while in use.
+var minCompactedLines = 10000
+
+// HashIndex remembers the hash of the content last uploaded for each UUID.
+type HashIndex interface {
+	Get(uuid string) (string, bool)
+	Set(uuid, hash string)
+	Remove(uuid string)
+}
+
+// contentHash identifies an upload. The date is part of it, as it determines where the content is stored.
+func contentHash(date string, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(date))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type MemoryHashIndex struct {
+	sync.RWMutex
+	hashes map[string]string
+}
+
+func NewMemoryHashIndex() *MemoryHashIndex {
+	return &MemoryHashIndex{
+		hashes: make(map[string]string),
+	}
+}
+
+func (i *MemoryHashIndex) Get(uuid string) (string, bool) {
+	i.RLock()
+	defer i.RUnlock()
+	hash, ok := i.hashes[uuid]
+	return hash, ok
+}
+
+func (i *MemoryHashIndex) Set(uuid, hash string) {
+	i.Lock()
+	defer i.Unlock()
+	i.hashes[uuid] = hash
+}
+
+func (i *MemoryHashIndex) Remove(uuid string) {
+	i.Lock()
+	defer i.Unlock()
+	delete(i.hashes, uuid)
+}
+
+// FileHashIndex keeps the hashes in memory and appends every change to a file, so that the index survives
+// service restarts. A line of the file holds a UUID and its hash, or only a UUID if its hash was removed.
+// The file is compacted when it is loaded, and whenever it has grown to twice the number of hashes.
+// The file is local to the pod, so each pod only knows the uploads it made itself.
+type FileHashIndex struct {
+	*MemoryHashIndex
+	path     string
+	fileLock sync.Mutex
+	file     *os.File
+	lines    int
+	log      *logger.UPPLogger
+}
+
+func NewFileHashIndex(path string, log *logger.UPPLogger) (*FileHashIndex, error) {
+	i := &FileHashIndex{
+		MemoryHashIndex: NewMemoryHashIndex(),
+		path:            path,
+		log:             log,
+	}
+
+	if err := i.load(); err != nil {
+		return nil, err
+	}
+	if err := i.compact(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening hash index file: %w", err)
+	}
+	i.file = file
+	return i, nil
+}
+
+func (i *FileHashIndex) Set(uuid, hash string) {
+	i.fileLock.Lock()
+	defer i.fileLock.Unlock()
+	i.MemoryHashIndex.Set(uuid, hash)
+	i.append(uuid + " " + hash)
+}
+
+func (i *FileHashIndex) Remove(uuid string) {
+	i.fileLock.Lock()
+	defer i.fileLock.Unlock()
+	i.MemoryHashIndex.Remove(uuid)
+	i.append(uuid)
+}
+
+func (i *FileHashIndex) Close() error {
+	i.fileLock.Lock()
+	defer i.fileLock.Unlock()
+	return i.file.Close()
+}
+
+// append writes a change to the file and compacts it if it has grown too much. Callers hold fileLock,
+// so that the file doesn't miss a change made while it is compacted.
+func (i *FileHashIndex) append(line string) {
+	if _, err := i.file.WriteString(line + "\n"); err != nil {
+		i.log.WithError(err).Error("Failed to persist hash index")
+	}
+	i.lines++
+
+	i.RLock()
+	hashes := len(i.hashes)
+	i.RUnlock()
+	if i.lines >= minCompactedLines && i.lines > 2*hashes {
+		i.compactInUse()
+	}
+}
+
+// compactInUse replaces the file with a compacted one and appends further changes to the latter.
+// The current file is kept if compacting it fails.
+func (i *FileHashIndex) compactInUse() {
+	if err := i.compact(); err != nil {
+		i.log.WithError(err).Error("Failed to compact hash index")
+		return
+	}
+
+	file, err := os.OpenFile(i.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		i.log.WithError(err).Error("Failed to reopen compacted hash index")
+		return
+	}
+	_ = i.file.Close()
+	i.file = file
+}
+
+func (i *FileHashIndex) load() error {
+	file, err := os.Open(i.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening hash index file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch len(fields) {
+		case 1:
+			delete(i.hashes, fields[0])
+		case 2:
+			i.hashes[fields[0]] = fields[1]
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("reading hash index file: %w", err)
+	}
+	return nil
+}
+
+// compact replaces the file with the current hashes, dropping the overwritten and removed ones.
+func (i *FileHashIndex) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(i.path), filepath.Base(i.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary hash index file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	i.RLock()
+	defer i.RUnlock()
+	writer := bufio.NewWriter(tmp)
+	for uuid, hash := range i.hashes {
+		if _, err = fmt.Fprintln(writer, uuid, hash); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("writing temporary hash index file: %w", err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing temporary hash index file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary hash index file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), i.path); err != nil {
+		return fmt.Errorf("replacing hash index file: %w", err)
+	}
+	i.lines = len(i.hashes)
+	return nil
+}
@@ -0,0 +1,70 @@
+package content
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileHashIndex_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashes.txt")
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	index, err := NewFileHashIndex(path, log)
+	require.NoError(t, err)
+	index.Set("uuid-a", "hash-1")
+	index.Set("uuid-b", "hash-2")
+	index.Set("uuid-a", "hash-3")
+	index.Remove("uuid-b")
+	require.NoError(t, index.Close())
+
+	index, err = NewFileHashIndex(path, log)
+	require.NoError(t, err)
+	defer index.Close()
+
+	hash, ok := index.Get("uuid-a")
+	assert.True(t, ok)
+	assert.Equal(t, "hash-3", hash)
+	_, ok = index.Get("uuid-b")
+	assert.False(t, ok)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "uuid-a hash-3", strings.TrimSpace(string(data)), "the file should be compacted")
+}
+
+func TestFileHashIndex_CompactsWhileInUse(t *testing.T) {
+	defer func(lines int) { minCompactedLines = lines }(minCompactedLines)
+	minCompactedLines = 4
+	path := filepath.Join(t.TempDir(), "hashes.txt")
+
+	index, err := NewFileHashIndex(path, logger.NewUPPLogger("test", "PANIC"))
+	require.NoError(t, err)
+	defer index.Close()
+	index.Set("uuid-a", "hash-1")
+	index.Set("uuid-a", "hash-2")
+	index.Set("uuid-a", "hash-3")
+	index.Set("uuid-a", "hash-4")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "uuid-a hash-4", strings.TrimSpace(string(data)), "the file should be compacted")
+
+	index.Set("uuid-b", "hash-5")
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "uuid-a hash-4\nuuid-b hash-5", strings.TrimSpace(string(data)), "changes should be appended to the compacted file")
+}
+
+func TestContentHash(t *testing.T) {
+	payload := []byte(`{"uuid":"uuid-a"}`)
+
+	assert.Equal(t, contentHash("2024-03-01", payload), contentHash("2024-03-01", payload))
+	assert.NotEqual(t, contentHash("2024-03-01", payload), contentHash("2024-03-02", payload))
+	assert.NotEqual(t, contentHash("2024-03-01", payload), contentHash("2024-03-01", []byte(`{"uuid":"uuid-b"}`)))
+}
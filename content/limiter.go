@@ -0,0 +1,152 @@
+package content
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// latencySmoothing is the weight of the latest response time in the moving average latency.
+	latencySmoothing = 0.2
+	// backoffFactor is applied to the request rate when the downstream services are struggling.
+	backoffFactor = 0.5
+	// backoffCooldown prevents a burst of slow or failed requests, which were all in flight at the same time,
+	// from slowing down the rate more than once.
+	backoffCooldown = time.Second
+	// recoverySteps is the number of healthy responses needed to get from the minimum to the maximum rate.
+	recoverySteps = 50
+)
+
+// LimiterConfig holds the bounds within which an AdaptiveLimiter moves the request rate.
+type LimiterConfig struct {
+	// MinRate and MaxRate are requests per second.
+	MinRate       float64
+	MaxRate       float64
+	TargetLatency time.Duration
+}
+
+// AdaptiveLimiter is a token bucket whose rate is lowered when the downstream services respond slowly
+// or with 429 and 5xx status codes, and raised again gradually while they respond well.
+// A nil *AdaptiveLimiter doesn't limit anything.
+type AdaptiveLimiter struct {
+	lock          sync.Mutex
+	config        LimiterConfig
+	rate          float64
+	tokens        float64
+	lastRefill    time.Time
+	latency       time.Duration
+	lastBackoff   time.Time
+	now           func() time.Time
+	sleep         func(time.Duration)
+	recoveryDelta float64
+}
+
+func NewAdaptiveLimiter(config LimiterConfig) *AdaptiveLimiter {
+	if config.MinRate <= 0 {
+		config.MinRate = 1
+	}
+	if config.MaxRate < config.MinRate {
+		config.MaxRate = config.MinRate
+	}
+
+	return &AdaptiveLimiter{
+		config:        config,
+		rate:          config.MaxRate,
+		tokens:        1,
+		lastRefill:    time.Now(),
+		now:           time.Now,
+		sleep:         time.Sleep,
+		recoveryDelta: (config.MaxRate - config.MinRate) / recoverySteps,
+	}
+}
+
+// Rate returns the current number of allowed requests per second.
+func (l *AdaptiveLimiter) Rate() float64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.rate
+}
+
+// Do waits for its turn, makes the call and adapts the rate to how the call went.
+func (l *AdaptiveLimiter) Do(call func() error) error {
+	if l == nil {
+		return call()
+	}
+
+	l.wait()
+	start := l.now()
+	err := call()
+	l.observe(l.now().Sub(start), err)
+	return err
+}
+
+func (l *AdaptiveLimiter) wait() {
+	l.lock.Lock()
+	now := l.now()
+	l.tokens += now.Sub(l.lastRefill).Seconds() * l.rate
+	if l.tokens > 1 {
+		l.tokens = 1
+	}
+	l.lastRefill = now
+
+	// The token is taken right away, callers which find the bucket empty queue up behind each other.
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.lock.Unlock()
+
+	if delay > 0 {
+		l.sleep(delay)
+	}
+}
+
+func (l *AdaptiveLimiter) observe(latency time.Duration, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.latency == 0 {
+		l.latency = latency
+	} else {
+		l.latency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(l.latency))
+	}
+
+	if isOverloaded(err) || (l.config.TargetLatency > 0 && l.latency > l.config.TargetLatency) {
+		now := l.now()
+		if now.Sub(l.lastBackoff) < backoffCooldown {
+			return
+		}
+		l.lastBackoff = now
+		l.rate *= backoffFactor
+		if l.rate < l.config.MinRate {
+			l.rate = l.config.MinRate
+		}
+		return
+	}
+
+	if err == nil {
+		l.rate += l.recoveryDelta
+		if l.rate > l.config.MaxRate {
+			l.rate = l.config.MaxRate
+		}
+	}
+}
+
+// isOverloaded tells whether an error signals that a downstream service can't keep up with the requests.
+func isOverloaded(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *UnexpectedStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
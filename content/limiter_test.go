@@ -0,0 +1,137 @@
+package content
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(config LimiterConfig) (*AdaptiveLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewAdaptiveLimiter(config)
+	l.now = clock.Now
+	l.sleep = clock.Sleep
+	l.lastRefill = clock.now
+	return l, clock
+}
+
+func TestAdaptiveLimiter_SpacesOutCalls(t *testing.T) {
+	l, clock := newTestLimiter(LimiterConfig{MinRate: 1, MaxRate: 10})
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, l.Do(func() error { return nil }))
+	}
+
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 100 * time.Millisecond}, clock.slept)
+}
+
+func TestAdaptiveLimiter_BacksOffOnOverload(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(clock *fakeClock) error
+	}{
+		{
+			name: "too many requests",
+			call: func(_ *fakeClock) error {
+				return &UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: http.StatusTooManyRequests}
+			},
+		},
+		{
+			name: "server error",
+			call: func(_ *fakeClock) error {
+				return &ExportError{Stage: UploadStage, Err: &UnexpectedStatusError{Operation: "uploading content", StatusCode: http.StatusBadGateway}}
+			},
+		},
+		{
+			name: "slow response",
+			call: func(clock *fakeClock) error {
+				clock.now = clock.now.Add(2 * time.Second)
+				return nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, clock := newTestLimiter(LimiterConfig{MinRate: 10, MaxRate: 40, TargetLatency: time.Second})
+
+			_ = l.Do(func() error { return test.call(clock) })
+			assert.Equal(t, 20.0, l.Rate())
+		})
+	}
+}
+
+func TestAdaptiveLimiter_BacksOffOncePerCooldown(t *testing.T) {
+	l, clock := newTestLimiter(LimiterConfig{MinRate: 10, MaxRate: 80})
+	overloaded := func() error {
+		return &UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: http.StatusServiceUnavailable}
+	}
+
+	_ = l.Do(overloaded)
+	_ = l.Do(overloaded)
+	assert.Equal(t, 40.0, l.Rate())
+
+	clock.now = clock.now.Add(backoffCooldown)
+	_ = l.Do(overloaded)
+	assert.Equal(t, 20.0, l.Rate())
+
+	clock.now = clock.now.Add(backoffCooldown)
+	_ = l.Do(overloaded)
+	clock.now = clock.now.Add(backoffCooldown)
+	_ = l.Do(overloaded)
+	assert.Equal(t, 10.0, l.Rate())
+}
+
+func TestAdaptiveLimiter_RecoversGradually(t *testing.T) {
+	l, _ := newTestLimiter(LimiterConfig{MinRate: 10, MaxRate: 60})
+
+	_ = l.Do(func() error {
+		return &UnexpectedStatusError{Operation: "uploading content", StatusCode: http.StatusServiceUnavailable}
+	})
+	assert.Equal(t, 30.0, l.Rate())
+
+	_ = l.Do(func() error { return nil })
+	assert.Equal(t, 31.0, l.Rate())
+
+	// failures which don't point at an overloaded service neither slow down nor speed up the rate
+	_ = l.Do(func() error {
+		return &UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: http.StatusNotFound}
+	})
+	_ = l.Do(func() error { return fmt.Errorf("some error") })
+	assert.Equal(t, 31.0, l.Rate())
+
+	for i := 0; i < recoverySteps; i++ {
+		_ = l.Do(func() error { return nil })
+	}
+	assert.Equal(t, 60.0, l.Rate())
+}
+
+func TestAdaptiveLimiter_NilLimiterDoesNotLimit(t *testing.T) {
+	var l *AdaptiveLimiter
+	called := false
+
+	err := l.Do(func() error {
+		called = true
+		return fmt.Errorf("some error")
+	})
+
+	assert.EqualError(t, err, "some error")
+	assert.True(t, called)
+}
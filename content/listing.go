@@ -0,0 +1,71 @@
+package content
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// s3ListingTimeFormat is the timestamp format of the `aws s3 ls` output.
+const s3ListingTimeFormat = "2006-01-02 15:04:05"
+
+// contentKeyRegexp matches the keys under which the S3 writer stores content, i.e. the date of the content
+// followed by its UUID.
+var contentKeyRegexp = regexp.MustCompile("^[0-9]{4}-[0-9]{2}-[0-9]{2}/([a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12})$")
+
+// S3Listing maps the UUIDs of the objects in the S3 bucket to the time they were last modified.
+// The time is zero if the listing doesn't include it.
+type S3Listing map[string]time.Time
+
+// ReadS3Listing reads a listing of the objects in the S3 bucket with one object per line.
+// A line holds the object key, optionally followed by its last modified RFC 3339 timestamp.
+// The output of `aws s3 ls --recursive` is accepted as well. Lines without a content key, e.g. archives or
+// manifests, are skipped, so that they are never taken for orphaned content.
+func ReadS3Listing(reader io.Reader) (S3Listing, error) {
+	listing := make(S3Listing)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(strings.ToLower(scanner.Text()))
+		uuid := listedUUID(fields)
+		if uuid == "" {
+			continue
+		}
+
+		lastModified := parseListedTime(fields)
+		// An object listed more than once counts with its latest modification
+		if previous, ok := listing[uuid]; !ok || lastModified.After(previous) {
+			listing[uuid] = lastModified
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading S3 listing: %w", err)
+	}
+	return listing, nil
+}
+
+// listedUUID returns the UUID of the content key among the fields of a line, or an empty string if there is none.
+func listedUUID(fields []string) string {
+	for _, field := range fields {
+		if match := contentKeyRegexp.FindStringSubmatch(field); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+func parseListedTime(fields []string) time.Time {
+	if len(fields) >= 2 {
+		if t, err := time.Parse(s3ListingTimeFormat, fields[0]+" "+fields[1]); err == nil {
+			return t
+		}
+	}
+	for _, field := range fields {
+		if t, err := time.Parse(time.RFC3339, strings.ToUpper(field)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
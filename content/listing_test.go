@@ -0,0 +1,33 @@
+package content
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadS3Listing(t *testing.T) {
+	manifest := strings.Join([]string{
+		"2024-03-01/811e0591-5c71-4457-b8eb-8c22cf093117",
+		"2024-03-02/9A5E3B4A-55DA-498C-816F-9C534E1392BD 2024-03-02T10:00:00Z",
+		"2024-03-03 08:15:00       1234 2024-03-01/c1a3b8c2-1d3e-4f5a-8b7c-9d0e1f2a3b4c",
+		"2024-03-04 08:15:00       1234 2024-03-04/c1a3b8c2-1d3e-4f5a-8b7c-9d0e1f2a3b4c",
+		"2024-03-05/5b7d9f11-2c4e-4a6b-8d0f-1a3c5e7f9b2d.zip",
+		"archives/2024-03-05/7e9f1a2b-3c4d-4e5f-8a6b-7c8d9e0f1a2b",
+		"7e9f1a2b-3c4d-4e5f-8a6b-7c8d9e0f1a2b",
+		"manifest.txt",
+		"",
+	}, "\n")
+
+	listing, err := ReadS3Listing(strings.NewReader(manifest))
+
+	require.NoError(t, err)
+	assert.Equal(t, S3Listing{
+		"811e0591-5c71-4457-b8eb-8c22cf093117": time.Time{},
+		"9a5e3b4a-55da-498c-816f-9c534e1392bd": time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC),
+		"c1a3b8c2-1d3e-4f5a-8b7c-9d0e1f2a3b4c": time.Date(2024, 3, 4, 8, 15, 0, 0, time.UTC),
+	}, listing)
+}
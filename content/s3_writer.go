@@ -2,11 +2,17 @@ package content
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 )
 
+// ErrListingNotConfigured is returned when the S3 writer listing URL isn't set.
+var ErrListingNotConfigured = errors.New("S3 listing URL is not configured")
+
 type Presignurl struct {
 	URL string `json:"url"`
 }
@@ -19,20 +25,26 @@ type updater interface {
 type S3Updater struct {
 	apiClient           httpClient
 	healthClient        httpClient
+	listClient          httpClient
 	writerAPIURL        string
 	writerGenericAPIURL string
 	presignerAPIURL     string
 	writerHealthURL     string
+	writerListURL       string
 }
 
-func NewS3Updater(apiClient, healthClient httpClient, writerAPIURL, writerGenericAPIURL, presignerAPIURL, writerHealthURL string) *S3Updater {
+// NewS3Updater creates an updater of the S3 bucket. The listing of the bucket goes through its own client,
+// as reading the listing of the whole bucket takes much longer than any other call.
+func NewS3Updater(apiClient, healthClient, listClient httpClient, writerAPIURL, writerGenericAPIURL, presignerAPIURL, writerHealthURL, writerListURL string) *S3Updater {
 	return &S3Updater{
 		apiClient:           apiClient,
 		healthClient:        healthClient,
+		listClient:          listClient,
 		writerAPIURL:        writerAPIURL,
 		writerGenericAPIURL: writerGenericAPIURL,
 		presignerAPIURL:     presignerAPIURL,
 		writerHealthURL:     writerHealthURL,
+		writerListURL:       writerListURL,
 	}
 }
 
@@ -51,7 +63,7 @@ func (u *S3Updater) Delete(uuid, tid string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("deleting content failed with unexpected status code: %d", resp.StatusCode)
+		return &UnexpectedStatusError{Operation: "deleting content", StatusCode: resp.StatusCode}
 	}
 
 	return nil
@@ -79,12 +91,38 @@ func (u *S3Updater) Upload(content []byte, tid, uuid, date string) error {
 	defer resp.Body.Close()
 
 	if !(resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated) {
-		return fmt.Errorf("uploading content failed with unexpected status code: %d", resp.StatusCode)
+		return &UnexpectedStatusError{Operation: "uploading content", StatusCode: resp.StatusCode}
 	}
 
 	return nil
 }
 
+// List returns the listing of the objects in the S3 bucket in the format read by ReadS3Listing.
+// Reading the listing is stopped when the context is cancelled. The caller is responsible for closing the listing.
+func (u *S3Updater) List(ctx context.Context, tid string) (io.ReadCloser, error) {
+	if u.writerListURL == "" {
+		return nil, ErrListingNotConfigured
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.writerListURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", "UPP Content Exporter")
+	req.Header.Add("X-Request-Id", tid)
+
+	resp, err := u.listClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &UnexpectedStatusError{Operation: "listing content", StatusCode: resp.StatusCode}
+	}
+
+	return resp.Body, nil
+}
+
 func (u *S3Updater) UploadZip(buf *bytes.Buffer, key, tid string) error {
 	req, err := http.NewRequest("PUT", u.writerGenericAPIURL+key, buf)
 	if err != nil {
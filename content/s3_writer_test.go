@@ -1,6 +1,7 @@
 package content
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
@@ -50,6 +51,11 @@ func (m *mockS3WriterServer) startMockS3WriterServer(t *testing.T) *httptest.Ser
 		w.WriteHeader(m.DeleteRequest(pathUUID, tid))
 	}).Methods(http.MethodDelete)
 
+	router.HandleFunc("/list", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(m.ListRequest(r.Header.Get("X-Request-Id")))
+		_, _ = io.WriteString(w, "2024-03-01/811e0591-5c71-4457-b8eb-8c22cf093117 2024-03-01T10:00:00Z\n")
+	}).Methods(http.MethodGet)
+
 	router.HandleFunc("/__gtg", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(m.GTG())
 	}).Methods(http.MethodGet)
@@ -72,6 +78,11 @@ func (m *mockS3WriterServer) DeleteRequest(bodyUUID, tid string) int {
 	return args.Int(0)
 }
 
+func (m *mockS3WriterServer) ListRequest(tid string) int {
+	args := m.Called(tid)
+	return args.Int(0)
+}
+
 type mockS3WriterServer struct {
 	mock.Mock
 }
@@ -110,6 +121,9 @@ func TestS3UpdaterUploadContentErrorResponse(t *testing.T) {
 	err := updater.Upload(testData, "tid_1234", testUUID, date)
 	assert.Error(t, err)
 	assert.EqualError(t, err, "uploading content failed with unexpected status code: 503")
+	var statusErr *UnexpectedStatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
 	mockServer.AssertExpectations(t)
 }
 
@@ -193,6 +207,44 @@ func TestS3UpdaterDeleteContentErrorOnRequestDo(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestS3UpdaterList(t *testing.T) {
+	mockServer := new(mockS3WriterServer)
+	mockServer.On("ListRequest", "tid_1234").Return(200)
+	server := mockServer.startMockS3WriterServer(t)
+
+	client := &http.Client{}
+	updater := NewS3Updater(client, client, client, "", "", "", "", server.URL+"/list")
+
+	listing, err := updater.List(context.Background(), "tid_1234")
+	assert.NoError(t, err)
+	defer listing.Close()
+
+	body, err := io.ReadAll(listing)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-03-01/811e0591-5c71-4457-b8eb-8c22cf093117 2024-03-01T10:00:00Z\n", string(body))
+	mockServer.AssertExpectations(t)
+}
+
+func TestS3UpdaterListErrorResponse(t *testing.T) {
+	mockServer := new(mockS3WriterServer)
+	mockServer.On("ListRequest", "tid_1234").Return(503)
+	server := mockServer.startMockS3WriterServer(t)
+
+	client := &http.Client{}
+	updater := NewS3Updater(client, client, client, "", "", "", "", server.URL+"/list")
+
+	_, err := updater.List(context.Background(), "tid_1234")
+	assert.EqualError(t, err, "listing content failed with unexpected status code: 503")
+	mockServer.AssertExpectations(t)
+}
+
+func TestS3UpdaterListNotConfigured(t *testing.T) {
+	updater := newS3Updater("http://server/content/")
+
+	_, err := updater.List(context.Background(), "tid_1234")
+	assert.ErrorIs(t, err, ErrListingNotConfigured)
+}
+
 func TestS3UpdaterCheckHealth(t *testing.T) {
 	mockServer := new(mockS3WriterServer)
 	mockServer.On("GTG").Return(200)
@@ -252,5 +304,5 @@ func TestS3UpdaterCheckHealthErrorOnRequestDo(t *testing.T) {
 
 func newS3Updater(writerAPIUrl string) *S3Updater {
 	client := &http.Client{}
-	return NewS3Updater(client, client, writerAPIUrl, "", "", writerAPIUrl+"/__gtg")
+	return NewS3Updater(client, client, client, writerAPIUrl, "", "", writerAPIUrl+"/__gtg", "")
 }
@@ -0,0 +1,23 @@
+package content
+
+// Stream carries the documents read from the database to a job. Once Docs is closed, Err tells whether reading them
+// was cut short, in which case the documents received are only part of those selected.
+type Stream struct {
+	Docs chan *Stub
+	err  error
+}
+
+func NewStream(docs chan *Stub) *Stream {
+	return &Stream{Docs: docs}
+}
+
+// End closes the stream with the error which cut it short, if any.
+func (s *Stream) End(err error) {
+	s.err = err
+	close(s.Docs)
+}
+
+// Err returns the error which cut the stream short. It may only be called once Docs is closed.
+func (s *Stream) Err() error {
+	return s.err
+}
@@ -0,0 +1,311 @@
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/google/uuid"
+)
+
+// MaxLetters is the number of dead letters kept. Beyond it the oldest letters are dropped.
+const MaxLetters = 10000
+
+// minCompactedLines is the size of the dead letter file below which it isn't compacted while in use.
+var minCompactedLines = 10000
+
+// Stage tells where the incremental export failed to handle a notification.
+type Stage string
+
+const (
+	PolicyStage   Stage = "policy"
+	HandlingStage Stage = "handling"
+)
+
+// Letter is a notification of the incremental export which failed, kept to be replayed later.
+type Letter struct {
+	ID            string       `json:"ID"`
+	Stage         Stage        `json:"Stage"`
+	EventType     string       `json:"EventType"`
+	TransactionID string       `json:"TransactionID"`
+	Stub          content.Stub `json:"Stub"`
+	Error         string       `json:"Error"`
+	FailedAt      time.Time    `json:"FailedAt"`
+	Replays       int          `json:"Replays,omitempty"`
+}
+
+// Store keeps the dead letters in the order they failed. A letter being replayed is kept until its notification
+// was handled again, so that it isn't lost if the service stops in the meantime.
+type Store interface {
+	Add(letter Letter)
+	List() []Letter
+	// Claim returns the letters with the given IDs, or all of them without IDs, for a replay.
+	// Letters already claimed are skipped. Claims are not persisted.
+	Claim(ids ...string) []Letter
+	// Remove drops a claimed letter once its notification was handled again.
+	Remove(id string)
+	// Release gives up the claims on letters which couldn't be replayed.
+	Release(ids ...string)
+}
+
+type MemoryStore struct {
+	sync.RWMutex
+	letters []Letter
+	claimed map[string]bool
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{claimed: make(map[string]bool)}
+}
+
+// Add stores the letter, giving it an ID and a failure time unless it has them already.
+func (s *MemoryStore) Add(letter Letter) {
+	s.Lock()
+	defer s.Unlock()
+	s.add(letter)
+}
+
+// add stores the letter and returns it along with the oldest letters it pushed out, whose claims are dropped.
+func (s *MemoryStore) add(letter Letter) (Letter, []Letter) {
+	if letter.ID == "" {
+		letter.ID = uuid.New().String()
+	}
+	if letter.FailedAt.IsZero() {
+		letter.FailedAt = time.Now().UTC()
+	}
+	s.letters = append(s.letters, letter)
+	if len(s.letters) <= MaxLetters {
+		return letter, nil
+	}
+
+	evicted := append([]Letter(nil), s.letters[:len(s.letters)-MaxLetters]...)
+	for _, e := range evicted {
+		delete(s.claimed, e.ID)
+	}
+	s.letters = append([]Letter(nil), s.letters[len(s.letters)-MaxLetters:]...)
+	return letter, evicted
+}
+
+func (s *MemoryStore) List() []Letter {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]Letter{}, s.letters...)
+}
+
+func (s *MemoryStore) Claim(ids ...string) []Letter {
+	s.Lock()
+	defer s.Unlock()
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var claimed []Letter
+	for _, letter := range s.letters {
+		if (len(ids) == 0 || wanted[letter.ID]) && !s.claimed[letter.ID] {
+			s.claimed[letter.ID] = true
+			claimed = append(claimed, letter)
+		}
+	}
+	return claimed
+}
+
+func (s *MemoryStore) Remove(id string) {
+	s.Lock()
+	defer s.Unlock()
+	s.remove(id)
+}
+
+func (s *MemoryStore) remove(id string) {
+	delete(s.claimed, id)
+	for i, letter := range s.letters {
+		if letter.ID == id {
+			s.letters = append(s.letters[:i:i], s.letters[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) Release(ids ...string) {
+	s.Lock()
+	defer s.Unlock()
+	for _, id := range ids {
+		delete(s.claimed, id)
+	}
+}
+
+// record is a line of the dead letter file. It holds either an added letter or the ID of a removed or dropped one.
+type record struct {
+	Letter *Letter `json:"letter,omitempty"`
+	Taken  string  `json:"taken,omitempty"`
+}
+
+// FileStore keeps the dead letters in memory and appends every change to a file, so that they survive
+// service restarts, including the letters which were being replayed. The file is compacted when it is loaded,
+// and whenever it has grown to twice the number of letters.
+type FileStore struct {
+	*MemoryStore
+	path     string
+	fileLock sync.Mutex
+	file     *os.File
+	lines    int
+	log      *logger.UPPLogger
+}
+
+func NewFileStore(path string, log *logger.UPPLogger) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		path:        path,
+		log:         log,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening dead letter file: %w", err)
+	}
+	s.file = file
+	return s, nil
+}
+
+func (s *FileStore) Add(letter Letter) {
+	s.Lock()
+	defer s.Unlock()
+	letter, evicted := s.add(letter)
+	records := []record{{Letter: &letter}}
+	for _, e := range evicted {
+		records = append(records, record{Taken: e.ID})
+	}
+	s.append(records...)
+}
+
+func (s *FileStore) Remove(id string) {
+	s.Lock()
+	defer s.Unlock()
+	s.remove(id)
+	s.append(record{Taken: id})
+}
+
+func (s *FileStore) Close() error {
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+	return s.file.Close()
+}
+
+// append writes changes to the file and compacts it if it has grown too much. Callers hold the store lock,
+// so that the file doesn't miss a change made while it is compacted.
+func (s *FileStore) append(records ...record) {
+	var lines []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			s.log.WithError(err).Error("Failed to marshal dead letter")
+			return
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+	if _, err := s.file.Write(lines); err != nil {
+		s.log.WithError(err).Error("Failed to persist dead letters")
+	}
+	s.lines += len(records)
+
+	if s.lines >= minCompactedLines && s.lines > 2*len(s.letters) {
+		s.compactInUse()
+	}
+}
+
+// compactInUse replaces the file with a compacted one and appends further changes to the latter.
+// The current file is kept if compacting it fails.
+func (s *FileStore) compactInUse() {
+	if err := s.compact(); err != nil {
+		s.log.WithError(err).Error("Failed to compact dead letters")
+		return
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		s.log.WithError(err).Error("Failed to reopen compacted dead letters")
+		return
+	}
+	_ = s.file.Close()
+	s.file = file
+}
+
+func (s *FileStore) load() error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening dead letter file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r record
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// A line cut short by a crash is skipped
+			s.log.WithError(err).Warn("Skipping unreadable dead letter")
+			continue
+		}
+		switch {
+		case r.Letter != nil:
+			s.add(*r.Letter)
+		case r.Taken != "":
+			s.remove(r.Taken)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("reading dead letter file: %w", err)
+	}
+	return nil
+}
+
+// compact replaces the file with the current letters, dropping the removed ones.
+func (s *FileStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary dead letter file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for i := range s.letters {
+		if err = encoder.Encode(record{Letter: &s.letters[i]}); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("writing temporary dead letter file: %w", err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing temporary dead letter file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary dead letter file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("replacing dead letter file: %w", err)
+	}
+	s.lines = len(s.letters)
+	return nil
+}
@@ -0,0 +1,129 @@
+package deadletter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func letterIDs(letters []Letter) []string {
+	var ids []string
+	for _, letter := range letters {
+		ids = append(ids, letter.ID)
+	}
+	return ids
+}
+
+func TestMemoryStore_ClaimAndRemove(t *testing.T) {
+	store := NewMemoryStore()
+	store.Add(Letter{ID: "letter-a", Stage: HandlingStage})
+	store.Add(Letter{ID: "letter-b", Stage: PolicyStage})
+	store.Add(Letter{ID: "letter-c", Stage: HandlingStage})
+
+	claimed := store.Claim("letter-b", "unknown")
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "letter-b", claimed[0].ID)
+	assert.False(t, claimed[0].FailedAt.IsZero())
+
+	// Claimed letters are kept until removed, but can't be claimed twice
+	assert.Equal(t, []string{"letter-a", "letter-b", "letter-c"}, letterIDs(store.List()))
+	assert.Equal(t, []string{"letter-a", "letter-c"}, letterIDs(store.Claim()))
+
+	store.Remove("letter-b")
+	store.Release("letter-a")
+	assert.Equal(t, []string{"letter-a", "letter-c"}, letterIDs(store.List()))
+	assert.Equal(t, []string{"letter-a"}, letterIDs(store.Claim()))
+}
+
+func TestMemoryStore_DropsOldestLetters(t *testing.T) {
+	store := NewMemoryStore()
+	store.Add(Letter{ID: "oldest"})
+	for i := 0; i < MaxLetters; i++ {
+		store.Add(Letter{})
+	}
+
+	letters := store.List()
+	assert.Len(t, letters, MaxLetters)
+	assert.NotEqual(t, "oldest", letters[0].ID)
+}
+
+func TestFileStore_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletters.jsonl")
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	store, err := NewFileStore(path, log)
+	require.NoError(t, err)
+	store.Add(Letter{ID: "letter-a", Stage: HandlingStage, EventType: "UPDATE", Stub: content.Stub{UUID: "uuid-a"}, Error: "upload failed"})
+	store.Add(Letter{ID: "letter-b", Stage: PolicyStage, EventType: "UPDATE", Stub: content.Stub{UUID: "uuid-b"}})
+	store.Add(Letter{ID: "letter-c", Stage: HandlingStage, EventType: "DELETE", Stub: content.Stub{UUID: "uuid-c"}})
+	store.Claim("letter-a", "letter-c")
+	store.Remove("letter-a")
+	require.NoError(t, store.Close())
+
+	store, err = NewFileStore(path, log)
+	require.NoError(t, err)
+	defer store.Close()
+
+	// The letter claimed but not removed is back
+	letters := store.List()
+	require.Len(t, letters, 2)
+	assert.Equal(t, []string{"letter-b", "letter-c"}, letterIDs(store.Claim()))
+	assert.Equal(t, "letter-b", letters[0].ID)
+	assert.Equal(t, PolicyStage, letters[0].Stage)
+	assert.Equal(t, "uuid-b", letters[0].Stub.UUID)
+}
+
+func TestFileStore_RecordsDroppedLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletters.jsonl")
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	store, err := NewFileStore(path, log)
+	require.NoError(t, err)
+	store.Add(Letter{ID: "oldest"})
+	store.Claim("oldest")
+	for i := 0; i < MaxLetters; i++ {
+		store.Add(Letter{})
+	}
+	assert.NotContains(t, store.claimed, "oldest", "the claim on a dropped letter should be dropped too")
+	letters := store.List()
+	require.NoError(t, store.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `{"taken":"oldest"}`)
+
+	store, err = NewFileStore(path, log)
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, letterIDs(letters), letterIDs(store.List()))
+}
+
+func TestFileStore_CompactsWhileInUse(t *testing.T) {
+	defer func(lines int) { minCompactedLines = lines }(minCompactedLines)
+	minCompactedLines = 4
+	path := filepath.Join(t.TempDir(), "deadletters.jsonl")
+
+	store, err := NewFileStore(path, logger.NewUPPLogger("test", "PANIC"))
+	require.NoError(t, err)
+	defer store.Close()
+	store.Add(Letter{ID: "letter-a"})
+	store.Add(Letter{ID: "letter-b"})
+	store.Remove("letter-a")
+	store.Remove("letter-b")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, strings.TrimSpace(string(data)), "the file should be compacted")
+
+	store.Add(Letter{ID: "letter-c"})
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+	assert.Contains(t, string(data), `"ID":"letter-c"`, "changes should be appended to the compacted file")
+}
@@ -57,7 +57,7 @@ func (ea *ECSArchive) CreateArchive(key string) error {
 	defer ea.mu.Unlock()
 	_, prs := ea.archives[key]
 	if prs {
-		return errors.New("already created")
+		return ErrArchiveExists
 	}
 	a := archive{state: "RUNNING", key: key}
 	ea.archives[key] = a
@@ -107,8 +107,12 @@ func (ea *ECSArchive) DeleteArchive(key string) {
 	delete(ea.archives, key)
 }
 
+// ErrArchiveExists is returned when the archive is already being generated or has been generated.
+var ErrArchiveExists = errors.New("already created")
+
+// GenerateArchiveS3 uploads the archive of the articles between the given dates to S3 and returns its presigned URL.
 // Run in go routine
-func (ea *ECSArchive) GenerateArchiveS3(startDate, endDate, tid string, log *logger.LogEntry) {
+func (ea *ECSArchive) GenerateArchiveS3(startDate, endDate, tid string, log *logger.LogEntry) (string, error) {
 	ea.running <- true
 	defer func() {
 		<-ea.running
@@ -117,46 +121,47 @@ func (ea *ECSArchive) GenerateArchiveS3(startDate, endDate, tid string, log *log
 	key := startDate + "-" + endDate + ".zip"
 	if err := ea.CreateArchive(key); err != nil {
 		log.WithError(err).Warn("CreateArchive error.")
-		return
+		return "", err
 	}
 
 	articles, errCh, err := ea.getArticles(startDate, endDate)
 	if err != nil {
 		ea.DeleteArchive(key)
 		log.WithError(err).Warn("getArticles error.")
-		return
+		return "", err
 	}
 	go reportErrors(errCh, log)
 	archive, err := ea.ExportArticlesZip(articles)
 	if err != nil {
 		ea.DeleteArchive(key)
 		log.WithError(err).Warn("ExportArticlesZip error.")
-		return
+		return "", err
 	}
 
 	err = ea.updater.UploadZip(archive, key, tid)
 	if err != nil {
 		log.WithError(err).Warn("UploadZip error.")
-		return
+		return "", err
 	}
 
 	if err = ea.ChangeArchiveState(key, "CREATED"); err != nil {
 		log.WithError(err).Warn("ChangeArchiveState error.")
-		return
+		return "", err
 	}
 
 	pu, err := ea.updater.PresignURL(key, tid)
 	if err != nil {
 		log.WithError(err).Warn("PresignURL error.")
-		return
+		return "", err
 	}
 
 	if err := ea.AddPresignURLToArchive(key, pu.URL); err != nil {
 		log.WithError(err).Warn("AddPresignURLToArchive error.")
-		return
+		return "", err
 	}
 
 	PutBuffer(archive)
+	return pu.URL, nil
 }
 
 func (ea *ECSArchive) ExportArticlesZip(articles <-chan Article) (*bytes.Buffer, error) {
@@ -28,21 +28,25 @@ if [ "$?" -ne 0 ]; then
   exit 1
 else
   jobID=$(echo "${jobResult}" | jq '.ID' | cut -d'"' -f2 2>/dev/null)
-  echo "Export triggered. Job id: ${jobID}. Checking continuously the status to be in 'Finished'..."
-  sleep 3
-  status="Running"
-  while [ ${status} != "Finished" ]; do
-  job=$(curl -qSfs "${EXPORTER_URL}/jobs/${jobID}" -H "Authorization: ${AUTH}" 2>/dev/null)
+  echo "Export triggered. Job id: ${jobID}. Following its events until it ends..."
+  eventType=""
+  curl -qSfsN "${EXPORTER_URL}/jobs/${jobID}/events" -H "Authorization: ${AUTH}" 2>/dev/null | while read -r line; do
+    case "${line}" in
+    event:*) eventType="${line#event: }" ;;
+    data:*) echo "${eventType}: ${line#data: }" ;;
+    esac
+  done
 
+  job=$(curl -qSfs "${EXPORTER_URL}/jobs/${jobID}" -H "Authorization: ${AUTH}" 2>/dev/null)
   if [ "$?" -ne 0 ]; then
 	echo ">>Failed to retrieve job"
 	exit 1
-  else
-	 status=$(echo "${job}" | jq '.Status' | cut -d'"' -f2 2>/dev/null)
   fi
-
+  status=$(echo "${job}" | jq '.Status' | cut -d'"' -f2 2>/dev/null)
   echo "${job}"
-  sleep 3
-  done
+  if [ "${status}" != "Finished" ]; then
+	echo ">>Export ended with status ${status}"
+	exit 1
+  fi
   echo "Export finished. Check logs if there are failures"
 fi
@@ -0,0 +1,117 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+const (
+	unknownContentType = "unknown"
+	noPublication      = "none"
+)
+
+var ErrUUIDsNotListed = fmt.Errorf("job does not list uuids")
+
+// DryRunSummary breaks down the documents a dry run found by content type and publication.
+// A document with several publications is counted once for each of them.
+type DryRunSummary struct {
+	ContentTypes map[string]int `json:"ContentTypes"`
+	Publications map[string]int `json:"Publications"`
+}
+
+func (s *DryRunSummary) add(doc *content.Stub) {
+	contentType := doc.ContentType
+	if contentType == "" {
+		contentType = unknownContentType
+	}
+	s.ContentTypes[contentType]++
+
+	if len(doc.Publication) == 0 {
+		s.Publications[noPublication]++
+	}
+	for _, publication := range doc.Publication {
+		s.Publications[publication]++
+	}
+}
+
+func (s *DryRunSummary) copy() *DryRunSummary {
+	if s == nil {
+		return nil
+	}
+
+	c := &DryRunSummary{
+		ContentTypes: make(map[string]int, len(s.ContentTypes)),
+		Publications: make(map[string]int, len(s.Publications)),
+	}
+	for k, v := range s.ContentTypes {
+		c.ContentTypes[k] = v
+	}
+	for k, v := range s.Publications {
+		c.Publications[k] = v
+	}
+	return c
+}
+
+// NewDryRunJob creates a job which only counts the documents an export would select.
+// If listUUIDs is set, the UUIDs of the documents are kept in memory as well.
+func NewDryRunJob(isFullExport, listUUIDs bool, log *logger.UPPLogger) *Job {
+	job := NewJob(0, 0, isFullExport, log)
+	job.DryRun = true
+	job.listUUIDs = listUUIDs
+	job.Summary = &DryRunSummary{
+		ContentTypes: make(map[string]int),
+		Publications: make(map[string]int),
+	}
+	return job
+}
+
+// GetJobUUIDs returns the UUIDs found so far by a dry run which was asked to list them.
+func (fe *FullExporter) GetJobUUIDs(jobID string) ([]string, error) {
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	if !job.listUUIDs {
+		return nil, ErrUUIDsNotListed
+	}
+	return append([]string(nil), job.uuids...), nil
+}
+
+// RunDryRun counts the documents in the stream without exporting them.
+func (job *Job) RunDryRun(docs *content.Stream) {
+	job.log.Infof("Dry run started: %v", job.ID)
+	job.setStatus(RUNNING)
+	var streamErr error
+dispatch:
+	for {
+		select {
+		case <-job.ctx.Done():
+			break dispatch
+		case doc, ok := <-docs.Docs:
+			if !ok {
+				streamErr = docs.Err()
+				break dispatch
+			}
+			if !job.waitWhilePaused() {
+				break dispatch
+			}
+
+			job.lock.Lock()
+			job.Progress++
+			job.Summary.add(doc)
+			if job.listUUIDs {
+				job.uuids = append(job.uuids, doc.UUID)
+			}
+			job.lock.Unlock()
+		}
+	}
+
+	status := job.endStatus(streamErr)
+	job.setStatus(status)
+	job.log.Infof("%s dry run %v, documents found: %v", status, job.ID, job.Progress)
+}
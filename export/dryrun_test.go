@@ -0,0 +1,60 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJob_RunDryRun(t *testing.T) {
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(1, nil, store, Retention{}, QueueLimits{})
+	job := NewDryRunJob(true, true, logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	docs := make(chan *content.Stub, 3)
+	docs <- &content.Stub{UUID: "uuid-a", ContentType: "Article", Publication: []string{"pub-1"}}
+	docs <- &content.Stub{UUID: "uuid-b", ContentType: "Article", Publication: []string{"pub-1", "pub-2"}}
+	docs <- &content.Stub{UUID: "uuid-c", ContentType: "ContentPackage"}
+	close(docs)
+
+	job.RunDryRun(content.NewStream(docs))
+
+	stored, err := fe.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FINISHED, stored.Status)
+	assert.True(t, stored.DryRun)
+	assert.Equal(t, 3, stored.Progress)
+	assert.Equal(t, &DryRunSummary{
+		ContentTypes: map[string]int{"Article": 2, "ContentPackage": 1},
+		Publications: map[string]int{"pub-1": 2, "pub-2": 1, noPublication: 1},
+	}, stored.Summary)
+	assert.False(t, fe.IsFullExportRunning())
+
+	uuids, err := fe.GetJobUUIDs(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"uuid-a", "uuid-b", "uuid-c"}, uuids)
+}
+
+func TestFullExporter_GetJobUUIDs(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(1, nil, store, Retention{}, QueueLimits{})
+
+	withoutList := NewDryRunJob(true, false, log)
+	fe.AddJob(withoutList)
+	export := NewJob(1, 0, true, log)
+	fe.AddJob(export)
+
+	_, err := fe.GetJobUUIDs("unknown")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	_, err = fe.GetJobUUIDs(withoutList.ID)
+	assert.ErrorIs(t, err, ErrUUIDsNotListed)
+
+	_, err = fe.GetJobUUIDs(export.ID)
+	assert.ErrorIs(t, err, ErrUUIDsNotListed)
+}
@@ -0,0 +1,170 @@
+package export
+
+import (
+	"sync"
+	"time"
+)
+
+// progressInterval is the minimum time between two progress events of a job.
+const progressInterval = time.Second
+
+// eventBuffer is the number of events kept for a subscriber which is slow to read them.
+// Further progress and failure events are dropped until the subscriber catches up, while state events
+// make room for themselves by dropping the oldest ones.
+const eventBuffer = 64
+
+type EventType string
+
+const (
+	ProgressEvent EventType = "progress"
+	FailureEvent  EventType = "failure"
+	StateEvent    EventType = "state"
+)
+
+// Event describes a change of a job. State events hold a snapshot of the job, progress events its counters only,
+// and failure events the failed document.
+type Event struct {
+	Type    EventType
+	Job     *Job
+	Failure *Failure
+}
+
+// Data returns the payload of the event.
+func (e Event) Data() interface{} {
+	if e.Failure != nil {
+		return e.Failure
+	}
+	return e.Job
+}
+
+// eventHub hands the events of a job to its subscribers. Its lock is never acquired while holding the lock
+// of the job, so that subscribing can take a consistent snapshot of the job.
+type eventHub struct {
+	sync.Mutex
+	subscribers  map[chan Event]struct{}
+	lastProgress time.Time
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns the events of a job and a function to stop receiving them. The channel is closed once
+// the job has ended. Subscribing to a job which has already ended returns its final state only.
+func (fe *FullExporter) Subscribe(jobID string) (<-chan Event, func(), error) {
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return nil, nil, ErrJobNotFound
+	}
+
+	hub := job.events
+	hub.Lock()
+	defer hub.Unlock()
+
+	snapshot := job.Copy()
+	events := make(chan Event, eventBuffer)
+	events <- Event{Type: StateEvent, Job: &snapshot}
+	if !snapshot.Status.isActive() {
+		close(events)
+		return events, func() {}, nil
+	}
+
+	hub.subscribers[events] = struct{}{}
+	unsubscribe := func() {
+		hub.Lock()
+		defer hub.Unlock()
+		if _, ok := hub.subscribers[events]; ok {
+			delete(hub.subscribers, events)
+			close(events)
+		}
+	}
+	return events, unsubscribe, nil
+}
+
+func (h *eventHub) publish(e Event) {
+	h.Lock()
+	defer h.Unlock()
+	h.send(e)
+}
+
+func (h *eventHub) send(e Event) {
+	for events := range h.subscribers {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+// deliver sends the event to the subscribers, dropping their oldest events if their buffer is full,
+// so that no subscriber misses it. The lock of the hub must be held, as it is the only sender.
+func (h *eventHub) deliver(e Event) {
+	for events := range h.subscribers {
+		for sent := false; !sent; {
+			select {
+			case events <- e:
+				sent = true
+			default:
+				select {
+				case <-events:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// publishState sends the state of the job to the subscribers. Once the job has ended, their channels are closed.
+func (h *eventHub) publishState(job Job) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.deliver(Event{Type: StateEvent, Job: &job})
+	if job.Status.isActive() {
+		return
+	}
+
+	for events := range h.subscribers {
+		close(events)
+	}
+	h.subscribers = make(map[chan Event]struct{})
+}
+
+// progressDue tells whether the next progress event should be published, at most one per progressInterval.
+func (h *eventHub) progressDue() bool {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.subscribers) == 0 || time.Since(h.lastProgress) < progressInterval {
+		return false
+	}
+	h.lastProgress = time.Now()
+	return true
+}
+
+// publishProgress sends the counters of the job to the subscribers unless they were sent recently.
+func (job *Job) publishProgress() {
+	if !job.events.progressDue() {
+		return
+	}
+	counters := job.progressCounters()
+	job.events.publish(Event{Type: ProgressEvent, Job: &counters})
+}
+
+// progressCounters returns the job with its counters only, leaving out its failures and settings.
+func (job *Job) progressCounters() Job {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	throughput := job.throughput()
+	return Job{
+		ID:         job.ID,
+		Status:     job.Status,
+		Count:      job.Count,
+		Progress:   job.Progress,
+		Skipped:    job.Skipped,
+		Counters:   job.copyCounters(),
+		Throughput: throughput,
+		ETA:        job.eta(throughput),
+	}
+}
@@ -0,0 +1,130 @@
+package export
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFullExporter_Subscribe(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	_, _, err := fe.Subscribe("unknown")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	events, unsubscribe, err := fe.Subscribe(job.ID)
+	require.NoError(t, err)
+	defer unsubscribe()
+
+	docs := make(chan *content.Stub, 2)
+	docs <- &content.Stub{UUID: "uuid-a"}
+	docs <- &content.Stub{UUID: "uuid-b"}
+	close(docs)
+
+	job.RunExport("tid", content.NewStream(docs), func(_ string, doc *content.Stub) error {
+		if doc.UUID == "uuid-b" {
+			return fmt.Errorf("export failed")
+		}
+		return nil
+	})
+
+	var received []Event
+	for e := range events {
+		received = append(received, e)
+	}
+
+	require.NotEmpty(t, received)
+	assert.Equal(t, StateEvent, received[0].Type)
+	assert.Equal(t, STARTING, received[0].Job.Status)
+
+	last := received[len(received)-1]
+	assert.Equal(t, StateEvent, last.Type)
+	assert.Equal(t, FINISHED, last.Job.Status)
+
+	var failures []Failure
+	progressed := false
+	for _, e := range received {
+		switch e.Type {
+		case FailureEvent:
+			failures = append(failures, *e.Failure)
+		case ProgressEvent:
+			progressed = true
+		}
+	}
+	assert.Equal(t, []Failure{{UUID: "uuid-b", Error: "export failed"}}, failures)
+	assert.True(t, progressed)
+}
+
+func TestFullExporter_SubscribeToEndedJob(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	job.Status = CANCELLED
+	fe.AddJob(job)
+
+	events, unsubscribe, err := fe.Subscribe(job.ID)
+	require.NoError(t, err)
+	defer unsubscribe()
+
+	e, ok := <-events
+	require.True(t, ok)
+	assert.Equal(t, StateEvent, e.Type)
+	assert.Equal(t, CANCELLED, e.Job.Status)
+
+	_, ok = <-events
+	assert.False(t, ok)
+}
+
+func TestFullExporter_SubscribeDeliversTheFinalStateToSlowSubscribers(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	events, unsubscribe, err := fe.Subscribe(job.ID)
+	require.NoError(t, err)
+	defer unsubscribe()
+
+	for i := 0; i < 2*eventBuffer; i++ {
+		job.events.publish(Event{Type: FailureEvent, Failure: &Failure{UUID: fmt.Sprintf("uuid-%d", i)}})
+	}
+	job.Status = FINISHED
+	job.events.publishState(job.Copy())
+
+	var received []Event
+	for e := range events {
+		received = append(received, e)
+	}
+	require.Len(t, received, eventBuffer)
+	last := received[len(received)-1]
+	assert.Equal(t, StateEvent, last.Type)
+	assert.Equal(t, FINISHED, last.Job.Status)
+}
+
+func TestJob_PublishProgressSendsCountersOnly(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	job.Count = 2
+	job.Progress = 1
+	job.Failed = []Failure{{UUID: "uuid-a", Error: "export failed"}}
+	fe.AddJob(job)
+
+	events, unsubscribe, err := fe.Subscribe(job.ID)
+	require.NoError(t, err)
+	defer unsubscribe()
+	<-events
+
+	job.publishProgress()
+
+	e := <-events
+	assert.Equal(t, ProgressEvent, e.Type)
+	assert.Equal(t, job.ID, e.Job.ID)
+	assert.Equal(t, 2, e.Job.Count)
+	assert.Equal(t, 1, e.Job.Progress)
+	assert.Empty(t, e.Job.Failed)
+	assert.Empty(t, e.Job.FailureReasons)
+}
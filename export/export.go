@@ -1,7 +1,10 @@
 package export
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
@@ -10,66 +13,172 @@ import (
 	"github.com/google/uuid"
 )
 
+// checkpointInterval is the number of dispatched documents after which a running job is persisted.
+const checkpointInterval = 100
+
+// circuitRetryInterval is how long a worker waits before retrying a document which couldn't be exported
+// because a circuit breaker was open.
+var circuitRetryInterval = 5 * time.Second
+
 type FullExporter struct {
-	sync.RWMutex
-	jobs                  map[string]*Job
+	store                 JobStore
 	nrOfConcurrentWorkers int
+	retention             Retention
+	queue                 *jobQueue
+	// addLock serialises adding and resuming jobs, so that no full export becomes active between checking for one and adding a job
+	addLock sync.Mutex
 	*content.Exporter
 }
 
 type State string
 
 const (
-	STARTING State = "Starting"
-	RUNNING  State = "Running"
-	FINISHED State = "Finished"
+	QUEUED      State = "Queued"
+	STARTING    State = "Starting"
+	RUNNING     State = "Running"
+	PAUSED      State = "Paused"
+	FINISHED    State = "Finished"
+	INTERRUPTED State = "Interrupted"
+	CANCELLED   State = "Cancelled"
+)
+
+// isActive tells whether a job in this state has not ended yet.
+func (s State) isActive() bool {
+	return s == QUEUED || s == STARTING || s == RUNNING || s == PAUSED
+}
+
+var (
+	ErrJobNotFound     = fmt.Errorf("job not found")
+	ErrJobNotResumable = fmt.Errorf("only interrupted full export jobs can be resumed")
+	ErrJobNotRunning   = fmt.Errorf("job is not running")
+	ErrJobNotPaused    = fmt.Errorf("job is not paused")
+	ErrJobNotRetryable = fmt.Errorf("only finished jobs with failures can be retried")
+	ErrInvalidSettings = fmt.Errorf("invalid job settings")
 )
 
-var ErrJobNotFound = fmt.Errorf("job not found")
+// MaxWorkers is the upper limit of concurrent workers of a single job.
+const MaxWorkers = 100
+
+// JobSettings holds the adjustable parameters of a job. Nil fields are left unchanged.
+type JobSettings struct {
+	Workers  *int `json:"workers,omitempty"`
+	Throttle *int `json:"throttle,omitempty"`
+}
+
+// Validate checks that the number of workers is between 1 and MaxWorkers and the throttle is not negative.
+func (s JobSettings) Validate() error {
+	if s.Workers != nil && (*s.Workers < 1 || *s.Workers > MaxWorkers) {
+		return fmt.Errorf("%w: workers must be between 1 and %d", ErrInvalidSettings, MaxWorkers)
+	}
+	if s.Throttle != nil && *s.Throttle < 0 {
+		return fmt.Errorf("%w: throttle must not be negative", ErrInvalidSettings)
+	}
+	return nil
+}
+
+// Checkpoint marks the position in the UUID ordered document stream up to which every document has been processed.
+type Checkpoint struct {
+	UUID     string `json:"UUID"`
+	Progress int    `json:"Progress"`
+	Skipped  int    `json:"Skipped,omitempty"`
+}
+
+// Counters track the documents handed to the workers of a job. Skipped documents are counted by the job instead.
+type Counters struct {
+	Dispatched int `json:"Dispatched"`
+	Succeeded  int `json:"Succeeded"`
+	Failed     int `json:"Failed"`
+	InFlight   int `json:"InFlight"`
+}
 
 type Job struct {
-	lock                     *sync.RWMutex
-	wg                       *sync.WaitGroup
-	log                      *logger.UPPLogger
-	nrWorker                 int
-	contentRetrievalThrottle int
-	isFullExport             bool
+	lock           *sync.RWMutex
+	wg             *sync.WaitGroup
+	log            *logger.UPPLogger
+	isFullExport   bool
+	store          JobStore
+	processed      map[int]processedDoc
+	ctx            context.Context
+	cancel         context.CancelFunc
+	unpaused       chan struct{}
+	busyWorkers    int
+	workerReleased chan struct{}
+	suspended      bool
+	listUUIDs      bool
+	uuids          []string
+	listing        content.S3Listing
+	discrepancies  map[Discrepancy][]string
+	counters       Counters
+	events         *eventHub
+	callbackURL    string
+	queue          *jobQueue
+	budget         *workerBudget
+	runStarted     time.Time
+	completedAtRun int
+	failureReasons map[string]int
 
-	ID           string   `json:"ID"`
-	Count        int      `json:"Count,omitempty"`
-	Progress     int      `json:"Progress,omitempty"`
-	Failed       []string `json:"Failed,omitempty"`
-	Status       State    `json:"Status"`
-	ErrorMessage string   `json:"ErrorMessage,omitempty"`
+	ID             string           `json:"ID"`
+	Workers        int              `json:"Workers,omitempty"`
+	Throttle       int              `json:"Throttle,omitempty"`
+	Count          int              `json:"Count,omitempty"`
+	Progress       int              `json:"Progress,omitempty"`
+	Skipped        int              `json:"Skipped,omitempty"`
+	Counters       *Counters        `json:"Counters,omitempty"`
+	Throughput     float64          `json:"Throughput,omitempty"`
+	ETA            *time.Time       `json:"ETA,omitempty"`
+	Failed         []Failure        `json:"Failed,omitempty"`
+	FailureReasons map[string]int   `json:"FailureReasons,omitempty"`
+	Status         State            `json:"Status"`
+	Priority       Priority         `json:"Priority,omitempty"`
+	ErrorMessage   string           `json:"ErrorMessage,omitempty"`
+	Checkpoint     *Checkpoint      `json:"Checkpoint,omitempty"`
+	ParentJobID    string           `json:"ParentJobID,omitempty"`
+	ChildJobIDs    []string         `json:"ChildJobIDs,omitempty"`
+	Filter         *content.Filter  `json:"Filter,omitempty"`
+	DryRun         bool             `json:"DryRun,omitempty"`
+	Summary        *DryRunSummary   `json:"Summary,omitempty"`
+	Reconciliation *ReconcileReport `json:"Reconciliation,omitempty"`
+	TransactionID  string           `json:"TransactionID,omitempty"`
+	StartTime      *time.Time       `json:"StartTime,omitempty"`
+	EndTime        *time.Time       `json:"EndTime,omitempty"`
+	Duration       string           `json:"Duration,omitempty"`
 }
 
 func NewJob(nrWorker int, contentRetrievalThrottle int, isFullExport bool, log *logger.UPPLogger) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now().UTC()
 	return &Job{
-		ID:                       uuid.New().String(),
-		nrWorker:                 nrWorker,
-		contentRetrievalThrottle: contentRetrievalThrottle,
-		isFullExport:             isFullExport,
-		log:                      log,
-		lock:                     &sync.RWMutex{},
-		wg:                       &sync.WaitGroup{},
-		Status:                   STARTING,
+		ID:             uuid.New().String(),
+		Workers:        nrWorker,
+		Throttle:       contentRetrievalThrottle,
+		isFullExport:   isFullExport,
+		log:            log,
+		lock:           &sync.RWMutex{},
+		wg:             &sync.WaitGroup{},
+		processed:      make(map[int]processedDoc),
+		ctx:            ctx,
+		cancel:         cancel,
+		workerReleased: make(chan struct{}, 1),
+		events:         newEventHub(),
+		Status:         STARTING,
+		StartTime:      &now,
 	}
 }
 
-func NewFullExporter(nrOfWorkers int, exporter *content.Exporter) *FullExporter {
+func NewFullExporter(nrOfWorkers int, exporter *content.Exporter, store JobStore, retention Retention, limits QueueLimits) *FullExporter {
 	return &FullExporter{
-		jobs:                  make(map[string]*Job),
+		store:                 store,
 		nrOfConcurrentWorkers: nrOfWorkers,
+		retention:             retention,
+		queue:                 newJobQueue(limits),
 		Exporter:              exporter,
 	}
 }
 
 func (fe *FullExporter) GetRunningJobs() []Job {
-	fe.RLock()
-	defer fe.RUnlock()
 	var jobs []Job
-	for _, job := range fe.jobs {
-		if job.Status == RUNNING {
+	for _, job := range fe.store.List() {
+		if status := job.getStatus(); status == RUNNING || status == PAUSED || status == QUEUED {
 			jobs = append(jobs, job.Copy())
 		}
 	}
@@ -77,83 +186,760 @@ func (fe *FullExporter) GetRunningJobs() []Job {
 }
 
 func (fe *FullExporter) GetJob(jobID string) (Job, error) {
-	fe.RLock()
-	defer fe.RUnlock()
-	job, ok := fe.jobs[jobID]
+	job, ok := fe.store.Get(jobID)
 	if !ok {
 		return Job{}, ErrJobNotFound
 	}
 	return job.Copy(), nil
 }
 
+// AddJob stores a new job and evicts the jobs which are beyond the retention policy.
 func (fe *FullExporter) AddJob(job *Job) {
+	fe.addLock.Lock()
+	defer fe.addLock.Unlock()
+	fe.addJob(job)
+}
+
+// AddJobUnlessFullExportRunning adds a new job like AddJob unless a full export is queued or running, in which case
+// it returns false. Jobs added concurrently are checked one after the other, so only one of them can be added.
+func (fe *FullExporter) AddJobUnlessFullExportRunning(job *Job) bool {
+	fe.addLock.Lock()
+	defer fe.addLock.Unlock()
+	if fe.IsFullExportRunning() {
+		return false
+	}
+	fe.addJob(job)
+	return true
+}
+
+func (fe *FullExporter) addJob(job *Job) {
 	if job != nil {
-		fe.Lock()
-		fe.jobs[job.ID] = job
-		fe.Unlock()
+		job.store = fe.store
+		fe.store.Save(job)
+		fe.evictJobs()
 	}
 }
 
+// ResumeJob prepares an interrupted full export job to continue after its last checkpoint.
+func (fe *FullExporter) ResumeJob(jobID string, contentRetrievalThrottle int) (*Job, error) {
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	fe.addLock.Lock()
+	defer fe.addLock.Unlock()
+	if err := job.prepareResume(fe.nrOfConcurrentWorkers, contentRetrievalThrottle); err != nil {
+		return nil, err
+	}
+
+	job.store = fe.store
+	fe.store.Save(job)
+	return job, nil
+}
+
+// CheckResumable tells whether ResumeJob would resume the job, without changing it.
+func (fe *FullExporter) CheckResumable(jobID string) error {
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return ErrJobNotFound
+	}
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	if !job.resumable() {
+		return ErrJobNotResumable
+	}
+	return nil
+}
+
+// CheckRetryable tells whether RetryJob would retry the failures of the job, without changing it.
+func (fe *FullExporter) CheckRetryable(jobID string) error {
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return ErrJobNotFound
+	}
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	if !job.retryable() {
+		return ErrJobNotRetryable
+	}
+	return nil
+}
+
+// RetryJob creates a targeted job for the documents which failed in the given job and links the two jobs.
+// It returns the new job together with the UUIDs it should export.
+func (fe *FullExporter) RetryJob(jobID string, contentRetrievalThrottle int, tid string) (*Job, []string, error) {
+	parent, ok := fe.store.Get(jobID)
+	if !ok {
+		return nil, nil, ErrJobNotFound
+	}
+
+	parent.lock.Lock()
+	if !parent.retryable() {
+		parent.lock.Unlock()
+		return nil, nil, ErrJobNotRetryable
+	}
+	failed := failedUUIDs(parent.Failed)
+
+	child := NewJob(fe.nrOfConcurrentWorkers, contentRetrievalThrottle, false, parent.log)
+	child.ParentJobID = parent.ID
+	child.TransactionID = tid
+	parent.ChildJobIDs = append(parent.ChildJobIDs, child.ID)
+	parent.lock.Unlock()
+
+	fe.AddJob(child)
+	fe.store.Save(parent)
+	return child, failed, nil
+}
+
+// CancelJob stops a starting or running job from dispatching new documents.
+// The job becomes CANCELLED once its in-flight documents are processed.
+func (fe *FullExporter) CancelJob(jobID string) error {
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return ErrJobNotFound
+	}
+	if !job.getStatus().isActive() {
+		return ErrJobNotRunning
+	}
+
+	job.log.Infof("Cancelling job %v", job.ID)
+	job.cancel()
+	return nil
+}
+
+// UpdateJob changes the number of workers and the throttle of an active job. Running jobs pick up the new values
+// for the next document they dispatch.
+func (fe *FullExporter) UpdateJob(jobID string, settings JobSettings) (Job, error) {
+	if err := settings.Validate(); err != nil {
+		return Job{}, err
+	}
+
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	job.lock.Lock()
+	if !job.Status.isActive() {
+		job.lock.Unlock()
+		return Job{}, ErrJobNotRunning
+	}
+	if settings.Workers != nil {
+		job.Workers = *settings.Workers
+	}
+	if settings.Throttle != nil {
+		job.Throttle = *settings.Throttle
+	}
+	workers, throttle := job.Workers, job.Throttle
+	job.lock.Unlock()
+
+	job.log.Infof("Job %v updated to %v worker(s) and %vms throttle", job.ID, workers, throttle)
+	job.notifyDispatcher()
+	job.save()
+	return job.Copy(), nil
+}
+
+// PauseJob stops a running job from handing documents to its workers while keeping its document stream open.
+func (fe *FullExporter) PauseJob(jobID string) error {
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	if !job.pause() {
+		return ErrJobNotRunning
+	}
+
+	job.log.Infof("Pausing job %v", job.ID)
+	job.stateChanged()
+	return nil
+}
+
+// UnpauseJob lets a paused job continue where it stopped.
+func (fe *FullExporter) UnpauseJob(jobID string) error {
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	if !job.unpause() {
+		return ErrJobNotPaused
+	}
+
+	job.log.Infof("Unpausing job %v", job.ID)
+	job.stateChanged()
+	return nil
+}
+
 func (fe *FullExporter) GetWorkerCount() int {
 	return fe.nrOfConcurrentWorkers
 }
 
 func (fe *FullExporter) IsFullExportRunning() bool {
-	fe.RLock()
-	defer fe.RUnlock()
-	for _, job := range fe.jobs {
-		if job.isFullExport && job.Status != FINISHED {
+	for _, job := range fe.store.List() {
+		// Dry runs and reconciliations without fixing don't touch S3, so they don't affect the incremental export
+		if job.isFullExport && job.writesToS3() && job.getStatus().isActive() {
 			return true
 		}
 	}
 	return false
 }
 
+func (job *Job) writesToS3() bool {
+	return !job.DryRun && (job.Reconciliation == nil || job.Reconciliation.Fix)
+}
+
 func (job *Job) Copy() Job {
 	job.lock.Lock()
 	defer job.lock.Unlock()
+	throughput := job.throughput()
 	return Job{
-		Progress: job.Progress,
-		Status:   job.Status,
-		ID:       job.ID,
-		Count:    job.Count,
-		Failed:   job.Failed,
+		Progress:       job.Progress,
+		Status:         job.Status,
+		Priority:       job.Priority,
+		ID:             job.ID,
+		Workers:        job.Workers,
+		Throttle:       job.Throttle,
+		Count:          job.Count,
+		Skipped:        job.Skipped,
+		Counters:       job.copyCounters(),
+		Throughput:     throughput,
+		ETA:            job.eta(throughput),
+		Failed:         append([]Failure(nil), job.Failed...),
+		FailureReasons: job.copyFailureReasons(),
+		ErrorMessage:   job.ErrorMessage,
+		Checkpoint:     job.Checkpoint,
+		ParentJobID:    job.ParentJobID,
+		ChildJobIDs:    append([]string(nil), job.ChildJobIDs...),
+		Filter:         job.Filter,
+		DryRun:         job.DryRun,
+		Summary:        job.Summary.copy(),
+		Reconciliation: job.Reconciliation.copy(),
+		TransactionID:  job.TransactionID,
+		StartTime:      job.StartTime,
+		EndTime:        job.EndTime,
+		Duration:       job.duration(),
 	}
 }
 
-func (job *Job) RunExport(tid string, docs chan *content.Stub, export func(string, *content.Stub) error) {
-	job.log.Infof("Job started: %v", job.ID)
+// copyFailureReasons returns the number of failures of the job by reason, or nil without failures.
+func (job *Job) copyFailureReasons() map[string]int {
+	if len(job.failureReasons) == 0 {
+		return nil
+	}
+	reasons := make(map[string]int, len(job.failureReasons))
+	for reason, count := range job.failureReasons {
+		reasons[reason] = count
+	}
+	return reasons
+}
+
+// addFailure records a document which failed, counting it by reason. Callers hold the lock of the job.
+func (job *Job) addFailure(f Failure) {
+	job.Failed = append(job.Failed, f)
+	if job.failureReasons == nil {
+		job.failureReasons = make(map[string]int)
+	}
+	job.failureReasons[f.reason()]++
+}
+
+// setFailures replaces the failures of the job and counts them again by reason.
+// Callers hold the lock of the job, unless nobody else has access to it yet.
+func (job *Job) setFailures(failures []Failure) {
+	job.Failed = failures
+	job.failureReasons = countFailureReasons(failures)
+}
+
+// copyCounters returns the counters of a job which has dispatched documents to its workers.
+func (job *Job) copyCounters() *Counters {
+	if job.counters == (Counters{}) {
+		return nil
+	}
+	counters := job.counters
+	return &counters
+}
+
+// throughput returns the number of documents per second processed since the job was last started.
+func (job *Job) throughput() float64 {
+	if job.runStarted.IsZero() {
+		return 0
+	}
+	end := time.Now()
+	if job.EndTime != nil {
+		end = *job.EndTime
+	}
+	elapsed := end.Sub(job.runStarted).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	completed := job.completed() - job.completedAtRun
+	return math.Round(float64(completed)/elapsed*100) / 100
+}
+
+// eta estimates when a running export finishes at the given throughput.
+func (job *Job) eta(throughput float64) *time.Time {
+	if job.Status != RUNNING || throughput <= 0 || job.Reconciliation != nil {
+		return nil
+	}
+	remaining := job.Count - job.completed()
+	if remaining <= 0 {
+		return nil
+	}
+	eta := time.Now().Add(time.Duration(float64(remaining) / throughput * float64(time.Second))).UTC().Round(time.Second)
+	return &eta
+}
+
+// duration returns how long the job has been running, or ran if it has ended.
+// It is unknown for jobs which were interrupted by a service restart.
+func (job *Job) duration() string {
+	if job.StartTime == nil || (job.EndTime == nil && !job.Status.isActive()) {
+		return ""
+	}
+	end := time.Now()
+	if job.EndTime != nil {
+		end = *job.EndTime
+	}
+	return end.Sub(*job.StartTime).Round(time.Second).String()
+}
+
+// SetCallbackURL sets the URL which is notified when the job ends.
+func (job *Job) SetCallbackURL(callbackURL string) {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.callbackURL = callbackURL
+}
+
+func (job *Job) CallbackURL() string {
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	return job.callbackURL
+}
+
+// SetCount sets the number of documents the job is expected to go through. A resumed job only goes through
+// the documents after its checkpoint, which come on top of those it already processed.
+func (job *Job) SetCount(remaining int) {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.Count = job.Progress + remaining
+}
+
+// CheckpointUUID returns the UUID after which a resumed job should continue the export.
+func (job *Job) CheckpointUUID() string {
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	if job.Checkpoint == nil {
+		return ""
+	}
+	return job.Checkpoint.UUID
+}
+
+func (job *Job) prepareResume(nrWorker int, contentRetrievalThrottle int) error {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	if !job.resumable() {
+		return ErrJobNotResumable
+	}
+
+	job.Workers = nrWorker
+	job.Throttle = contentRetrievalThrottle
+	job.Status = STARTING
+	job.ErrorMessage = ""
+	job.EndTime = nil
+	job.Progress = 0
+	job.processed = make(map[int]processedDoc)
+	job.Skipped = 0
+
+	// Documents after the checkpoint are exported again, so their failures are no longer relevant
+	var failed []Failure
+	if job.Checkpoint != nil {
+		job.Progress = job.Checkpoint.Progress
+		job.Skipped = job.Checkpoint.Skipped
+		for _, f := range job.Failed {
+			if f.UUID <= job.Checkpoint.UUID {
+				failed = append(failed, f)
+			}
+		}
+	}
+	job.setFailures(failed)
+	job.counters = Counters{
+		Dispatched: job.Progress,
+		Succeeded:  job.Progress - len(failed) - job.Skipped,
+		Failed:     len(failed),
+	}
+	return nil
+}
+
+// resumable tells whether the job is an interrupted full export. Callers hold the lock of the job.
+func (job *Job) resumable() bool {
+	// The S3 listing of a reconciliation isn't persisted, so it can't be resumed either
+	return job.isFullExport && !job.DryRun && job.Reconciliation == nil && job.Status == INTERRUPTED
+}
+
+// retryable tells whether the job has ended with failures. Callers hold the lock of the job.
+func (job *Job) retryable() bool {
+	return !job.Status.isActive() && len(job.Failed) != 0
+}
+
+// acquireWorker blocks until fewer than the configured number of workers are busy and the shared worker budget,
+// if any, has a free worker for the job. It returns false if the job is cancelled in the meantime.
+func (job *Job) acquireWorker() bool {
+	for {
+		job.lock.Lock()
+		if job.busyWorkers < job.Workers {
+			job.busyWorkers++
+			budget, priority := job.budget, job.Priority
+			job.lock.Unlock()
+
+			if budget != nil && !budget.acquire(job.ctx, priority) {
+				job.lock.Lock()
+				job.busyWorkers--
+				job.lock.Unlock()
+				return false
+			}
+			return true
+		}
+		job.lock.Unlock()
+
+		select {
+		case <-job.workerReleased:
+		case <-job.ctx.Done():
+			return false
+		}
+	}
+}
+
+func (job *Job) releaseWorker() {
+	job.lock.Lock()
+	job.busyWorkers--
+	budget := job.budget
+	job.lock.Unlock()
+	if budget != nil {
+		budget.release()
+	}
+	job.notifyDispatcher()
+}
+
+// notifyDispatcher wakes up the dispatcher if it waits for a worker. Only the dispatcher receives from the channel,
+// so a single pending signal is enough.
+func (job *Job) notifyDispatcher() {
+	select {
+	case job.workerReleased <- struct{}{}:
+	default:
+	}
+}
+
+func (job *Job) pause() bool {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	if job.Status != RUNNING {
+		return false
+	}
+	job.Status = PAUSED
+	job.unpaused = make(chan struct{})
+	return true
+}
+
+func (job *Job) unpause() bool {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	if job.Status != PAUSED {
+		return false
+	}
 	job.Status = RUNNING
-	workers := make(chan struct{}, job.nrWorker)
+	if job.suspended {
+		job.suspended = false
+		job.ErrorMessage = ""
+	}
+	close(job.unpaused)
+	job.unpaused = nil
+	return true
+}
+
+// suspend pauses a running job while a downstream service is unavailable.
+func (job *Job) suspend(reason error) {
+	if !job.pause() {
+		return
+	}
+	job.lock.Lock()
+	job.suspended = true
+	job.ErrorMessage = fmt.Sprintf("Paused while downstream services are unavailable: %v", reason)
+	job.lock.Unlock()
+
+	job.log.WithError(reason).Warnf("Suspending job %v", job.ID)
+	job.stateChanged()
+}
+
+// unsuspend lets a job which was paused by suspend continue. Jobs paused by request are left alone.
+func (job *Job) unsuspend() {
+	job.lock.RLock()
+	suspended := job.suspended
+	job.lock.RUnlock()
+	if !suspended || !job.unpause() {
+		return
+	}
+
+	job.log.Infof("Downstream services are available again, unsuspending job %v", job.ID)
+	job.stateChanged()
+}
+
+// exportDocument exports a document, retrying it for as long as a circuit breaker prevents it from being exported.
+// In the meantime the job is suspended so that no further documents are dispatched.
+func (job *Job) exportDocument(tid string, doc *content.Stub, export func(string, *content.Stub) error) error {
 	for {
-		doc, ok := <-docs
+		err := export(tid, doc)
+		if !errors.Is(err, content.ErrCircuitOpen) {
+			job.unsuspend()
+			return err
+		}
+
+		job.suspend(err)
+		select {
+		case <-time.After(circuitRetryInterval):
+		case <-job.ctx.Done():
+			return err
+		}
+	}
+}
+
+// waitWhilePaused blocks as long as the job is paused. It returns false if the job is cancelled in the meantime.
+func (job *Job) waitWhilePaused() bool {
+	job.lock.RLock()
+	unpaused := job.unpaused
+	job.lock.RUnlock()
+	if unpaused == nil {
+		return true
+	}
+
+	select {
+	case <-unpaused:
+		return true
+	case <-job.ctx.Done():
+		return false
+	}
+}
+
+// processedDoc is a document processed ahead of the checkpoint.
+type processedDoc struct {
+	uuid    string
+	skipped bool
+}
+
+// markProcessed records the document with the given sequence number as processed and moves the checkpoint
+// forward as long as every preceding document has been processed too.
+func (job *Job) markProcessed(seq int, uuid string, skipped bool) {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.processed[seq] = processedDoc{uuid: uuid, skipped: skipped}
+
+	next, skippedBefore := 0, 0
+	if job.Checkpoint != nil {
+		next, skippedBefore = job.Checkpoint.Progress, job.Checkpoint.Skipped
+	}
+	for {
+		doc, ok := job.processed[next]
 		if !ok {
-			job.wg.Wait()
-			job.Status = FINISHED
-			job.log.Infof("Finished job %v with %v failure(s), progress: %v", job.ID, len(job.Failed), job.Progress)
-			close(workers)
 			return
 		}
+		delete(job.processed, next)
+		next++
+		if doc.skipped {
+			skippedBefore++
+		}
+		job.Checkpoint = &Checkpoint{UUID: doc.uuid, Progress: next, Skipped: skippedBefore}
+	}
+}
+
+func (job *Job) getStatus() State {
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	return job.Status
+}
+
+func (job *Job) setStatus(status State) {
+	job.lock.Lock()
+	job.Status = status
+	if !status.isActive() {
+		now := time.Now().UTC()
+		job.EndTime = &now
+	}
+	job.lock.Unlock()
+	job.stateChanged()
+}
+
+// stateChanged persists the job and tells its subscribers about its new state. An ended job leaves the queue.
+func (job *Job) stateChanged() {
+	job.save()
+	snapshot := job.Copy()
+	job.events.publishState(snapshot)
+
+	job.lock.RLock()
+	queue := job.queue
+	job.lock.RUnlock()
+	if queue != nil && !snapshot.Status.isActive() {
+		queue.finish(job)
+	}
+}
+
+// startRun marks the job as running and starts measuring its throughput.
+func (job *Job) startRun() {
+	job.lock.Lock()
+	job.runStarted = time.Now()
+	job.completedAtRun = job.completed()
+	job.lock.Unlock()
+	job.setStatus(RUNNING)
+}
+
+// Context is cancelled when the job is cancelled.
+func (job *Job) Context() context.Context {
+	return job.ctx
+}
+
+// Fail finishes the job before any document is exported. A job which was cancelled in the meantime is marked as such.
+func (job *Job) Fail(message string) {
+	if job.ctx.Err() != nil {
+		job.setStatus(CANCELLED)
+		return
+	}
 
-		workers <- struct{}{} // Will block until worker is available to span up new goroutines
+	job.lock.Lock()
+	job.ErrorMessage = message
+	job.lock.Unlock()
+	job.setStatus(FINISHED)
+}
+
+// save persists the current state of the job if it has been added to a store.
+func (job *Job) save() {
+	if job.store != nil {
+		job.store.Save(job)
+	}
+}
+
+// completed returns how many documents the workers are done with. Callers hold the lock of the job.
+func (job *Job) completed() int {
+	return job.counters.Succeeded + job.counters.Failed + job.Skipped
+}
 
-		job.Progress++
-		job.wg.Add(1)
-		go func() {
-			defer job.wg.Done()
-			time.Sleep(time.Duration(job.contentRetrievalThrottle) * time.Millisecond)
-			if err := export(tid, doc); err != nil {
-				job.log.
-					WithTransactionID(tid).
-					WithUUID(doc.UUID).
-					WithError(err).
-					Error("Failed to process document")
+// startWorker processes a document in a new goroutine of an already acquired worker and records its failure, if any.
+// A document which is unchanged since its last upload is counted as skipped instead.
+// The optional done function is called once the document has been processed.
+func (job *Job) startWorker(tid, uuid string, process func() error, done func()) {
+	job.lock.Lock()
+	throttle := job.Throttle
+	job.counters.Dispatched++
+	job.counters.InFlight++
+	job.lock.Unlock()
+
+	job.wg.Add(1)
+	go func() {
+		defer job.wg.Done()
+		time.Sleep(time.Duration(throttle) * time.Millisecond)
+		err := process()
+
+		var failure Failure
+		failed := err != nil && !errors.Is(err, content.ErrUnchanged)
+		job.lock.Lock()
+		job.counters.InFlight--
+		switch {
+		case failed:
+			failure = newFailure(uuid, err)
+			job.counters.Failed++
+			job.addFailure(failure)
+		case err != nil:
+			job.Skipped++
+		default:
+			job.counters.Succeeded++
+		}
+		job.lock.Unlock()
+
+		if failed {
+			job.log.
+				WithTransactionID(tid).
+				WithUUID(uuid).
+				WithError(err).
+				Error("Failed to process document")
+			job.events.publish(Event{Type: FailureEvent, Failure: &failure})
+		}
+		job.publishProgress()
+		if done != nil {
+			done()
+		}
+		job.releaseWorker()
+	}()
+}
 
+// RunExport exports the documents of the stream. If the stream is cut short the job is interrupted, so that
+// a full export can be resumed after its checkpoint.
+func (job *Job) RunExport(tid string, docs *content.Stream, export func(string, *content.Stub) error) {
+	job.log.Infof("Job started: %v", job.ID)
+	job.startRun()
+	var streamErr error
+dispatch:
+	for {
+		select {
+		case <-job.ctx.Done():
+			break dispatch
+		case doc, ok := <-docs.Docs:
+			if !ok {
+				if streamErr = docs.Err(); streamErr != nil {
+					break dispatch
+				}
+				// The count is estimated before the export starts, only the end of the stream tells the actual one
 				job.lock.Lock()
-				job.Failed = append(job.Failed, doc.UUID)
+				job.Count = job.Progress
 				job.lock.Unlock()
+				break dispatch
+			}
+			if !job.waitWhilePaused() {
+				break dispatch
+			}
+
+			if !job.acquireWorker() { // Will block until worker is available to span up new goroutines
+				break dispatch
+			}
+
+			job.lock.Lock()
+			seq := job.Progress
+			job.Progress++
+			progress := job.Progress
+			if job.Count < progress {
+				job.Count = progress
+			}
+			job.lock.Unlock()
+			if progress%checkpointInterval == 0 {
+				job.save()
 			}
-			<-workers
-		}()
+
+			var skipped bool
+			job.startWorker(tid, doc.UUID, func() error {
+				err := job.exportDocument(tid, doc, export)
+				skipped = errors.Is(err, content.ErrUnchanged)
+				return err
+			}, func() {
+				job.markProcessed(seq, doc.UUID, skipped)
+			})
+		}
+	}
+
+	job.wg.Wait()
+
+	status := job.endStatus(streamErr)
+	job.setStatus(status)
+	job.log.Infof("%s job %v with %v failure(s), progress: %v", status, job.ID, len(job.Failed), job.Progress)
+}
+
+// endStatus returns the state in which a job ends once its workers are done. A job whose document stream was cut
+// short is interrupted, with the reason in its error message.
+func (job *Job) endStatus(streamErr error) State {
+	switch {
+	case job.ctx.Err() != nil:
+		return CANCELLED
+	case streamErr != nil:
+		job.log.WithError(streamErr).Errorf("Reading the documents of job %v failed", job.ID)
+		job.lock.Lock()
+		job.ErrorMessage = fmt.Sprintf("Reading the documents failed: %v", streamErr)
+		job.lock.Unlock()
+		return INTERRUPTED
+	default:
+		return FINISHED
 	}
 }
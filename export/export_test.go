@@ -0,0 +1,495 @@
+package export
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJob_MarkProcessedMovesCheckpointInOrder(t *testing.T) {
+	job := NewJob(3, 0, true, logger.NewUPPLogger("test", "PANIC"))
+
+	job.markProcessed(1, "uuid-b", false)
+	assert.Nil(t, job.Checkpoint)
+
+	job.markProcessed(0, "uuid-a", false)
+	assert.Equal(t, &Checkpoint{UUID: "uuid-b", Progress: 2}, job.Checkpoint)
+
+	job.markProcessed(3, "uuid-d", false)
+	assert.Equal(t, &Checkpoint{UUID: "uuid-b", Progress: 2}, job.Checkpoint)
+
+	job.markProcessed(2, "uuid-c", false)
+	assert.Equal(t, &Checkpoint{UUID: "uuid-d", Progress: 4}, job.Checkpoint)
+}
+
+func TestJob_RunExportSetsCheckpoint(t *testing.T) {
+	job := NewJob(2, 0, true, logger.NewUPPLogger("test", "PANIC"))
+
+	docs := make(chan *content.Stub, 3)
+	docs <- &content.Stub{UUID: "uuid-a"}
+	docs <- &content.Stub{UUID: "uuid-b"}
+	docs <- &content.Stub{UUID: "uuid-c"}
+	close(docs)
+
+	job.RunExport("tid", content.NewStream(docs), func(_ string, doc *content.Stub) error {
+		if doc.UUID == "uuid-b" {
+			return fmt.Errorf("export failed")
+		}
+		return nil
+	})
+
+	assert.Equal(t, FINISHED, job.Status)
+	assert.Equal(t, 3, job.Progress)
+	assert.Equal(t, []Failure{{UUID: "uuid-b", Error: "export failed"}}, job.Failed)
+	assert.Equal(t, &Checkpoint{UUID: "uuid-c", Progress: 3}, job.Checkpoint)
+}
+
+func TestJob_CopyDoesNotShareTheJobState(t *testing.T) {
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	job.ChildJobIDs = []string{"child-a"}
+
+	docs := make(chan *content.Stub, 2)
+	docs <- &content.Stub{UUID: "uuid-a"}
+	docs <- &content.Stub{UUID: "uuid-b"}
+	close(docs)
+	job.RunExport("tid", content.NewStream(docs), func(string, *content.Stub) error {
+		return &content.ExportError{Stage: content.UploadStage, Err: &content.UnexpectedStatusError{StatusCode: 503}}
+	})
+
+	copied := job.Copy()
+	assert.Equal(t, map[string]int{"upload: status 503": 2}, copied.FailureReasons)
+
+	copied.Failed[0].UUID = "changed"
+	copied.ChildJobIDs[0] = "changed"
+	copied.FailureReasons["upload: status 503"] = 0
+	assert.Equal(t, "uuid-a", job.Failed[0].UUID)
+	assert.Equal(t, "child-a", job.ChildJobIDs[0])
+	assert.Equal(t, map[string]int{"upload: status 503": 2}, job.Copy().FailureReasons)
+}
+
+func TestJob_RunExportIsInterruptedWhenTheStreamIsCutShort(t *testing.T) {
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	job.SetCount(10)
+
+	stream := content.NewStream(make(chan *content.Stub, 2))
+	stream.Docs <- &content.Stub{UUID: "uuid-a"}
+	stream.Docs <- &content.Stub{UUID: "uuid-b"}
+	stream.End(fmt.Errorf("cursor not found"))
+
+	job.RunExport("tid", stream, func(string, *content.Stub) error {
+		return nil
+	})
+
+	assert.Equal(t, INTERRUPTED, job.Status)
+	assert.Equal(t, "Reading the documents failed: cursor not found", job.ErrorMessage)
+	assert.Equal(t, 10, job.Count)
+	assert.Equal(t, &Checkpoint{UUID: "uuid-b", Progress: 2}, job.Checkpoint)
+	require.NoError(t, job.prepareResume(1, 0))
+	assert.Equal(t, "uuid-b", job.CheckpointUUID())
+}
+
+func TestJob_RunExportCountsSkippedDocuments(t *testing.T) {
+	job := NewJob(2, 0, true, logger.NewUPPLogger("test", "PANIC"))
+
+	docs := make(chan *content.Stub, 3)
+	docs <- &content.Stub{UUID: "uuid-a"}
+	docs <- &content.Stub{UUID: "uuid-b"}
+	docs <- &content.Stub{UUID: "uuid-c"}
+	close(docs)
+
+	job.RunExport("tid", content.NewStream(docs), func(_ string, doc *content.Stub) error {
+		if doc.UUID == "uuid-c" {
+			return nil
+		}
+		return content.ErrUnchanged
+	})
+
+	copied := job.Copy()
+	assert.Equal(t, FINISHED, copied.Status)
+	assert.Equal(t, 3, copied.Progress)
+	assert.Equal(t, 2, copied.Skipped)
+	assert.Equal(t, 1, copied.Counters.Succeeded, "skipped documents shouldn't count as succeeded")
+	assert.Empty(t, copied.Failed)
+	assert.Equal(t, &Checkpoint{UUID: "uuid-c", Progress: 3, Skipped: 2}, copied.Checkpoint)
+}
+
+func TestJob_RunExportCountsDocuments(t *testing.T) {
+	job := NewJob(2, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	// the estimated count drifted from the documents actually streamed
+	job.Count = 5
+
+	docs := make(chan *content.Stub, 3)
+	docs <- &content.Stub{UUID: "uuid-a"}
+	docs <- &content.Stub{UUID: "uuid-b"}
+	docs <- &content.Stub{UUID: "uuid-c"}
+	close(docs)
+
+	job.RunExport("tid", content.NewStream(docs), func(_ string, doc *content.Stub) error {
+		if doc.UUID == "uuid-b" {
+			return fmt.Errorf("export failed")
+		}
+		return nil
+	})
+
+	copied := job.Copy()
+	assert.Equal(t, &Counters{Dispatched: 3, Succeeded: 2, Failed: 1}, copied.Counters)
+	assert.Equal(t, 3, copied.Count)
+	assert.Greater(t, copied.Throughput, 0.0)
+	assert.Nil(t, copied.ETA)
+}
+
+func TestJob_ETA(t *testing.T) {
+	job := NewJob(2, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	job.Status = RUNNING
+	job.Count = 300
+	job.runStarted = time.Now().Add(-10 * time.Second)
+	job.counters = Counters{Dispatched: 110, Succeeded: 95, Failed: 5, InFlight: 10}
+
+	copied := job.Copy()
+	assert.InDelta(t, 10.0, copied.Throughput, 0.1)
+	require.NotNil(t, copied.ETA)
+	assert.WithinDuration(t, time.Now().Add(20*time.Second), *copied.ETA, 2*time.Second)
+}
+
+func TestFullExporter_ResumeJob(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(5, nil, store, Retention{}, QueueLimits{})
+
+	interrupted := NewJob(0, 0, true, log)
+	interrupted.Status = INTERRUPTED
+	interrupted.Progress = 7
+	interrupted.Skipped = 3
+	interrupted.Failed = []Failure{{UUID: "uuid-a"}, {UUID: "uuid-x"}}
+	interrupted.Checkpoint = &Checkpoint{UUID: "uuid-c", Progress: 4, Skipped: 1}
+	store.Save(interrupted)
+
+	targeted := NewJob(0, 0, false, log)
+	targeted.Status = INTERRUPTED
+	store.Save(targeted)
+
+	_, err := fe.ResumeJob("unknown", 0)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	_, err = fe.ResumeJob(targeted.ID, 0)
+	assert.ErrorIs(t, err, ErrJobNotResumable)
+
+	job, err := fe.ResumeJob(interrupted.ID, 10)
+	require.NoError(t, err)
+	assert.Equal(t, STARTING, job.Status)
+	assert.Equal(t, 4, job.Progress)
+	assert.Equal(t, []Failure{{UUID: "uuid-a"}}, job.Failed)
+	assert.Equal(t, map[string]int{"unknown: error": 1}, job.Copy().FailureReasons)
+	assert.Equal(t, 1, job.Skipped, "only the documents skipped before the checkpoint should still count")
+	assert.Equal(t, Counters{Dispatched: 4, Succeeded: 2, Failed: 1}, job.counters)
+	assert.Equal(t, "uuid-c", job.CheckpointUUID())
+	assert.Equal(t, 5, job.Workers)
+	assert.Equal(t, 10, job.Throttle)
+
+	_, err = fe.ResumeJob(interrupted.ID, 0)
+	assert.ErrorIs(t, err, ErrJobNotResumable)
+}
+
+func TestJob_RunExportStopsWhenCancelled(t *testing.T) {
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(1, nil, store, Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	docs := make(chan *content.Stub)
+	exported := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		job.RunExport("tid", content.NewStream(docs), func(_ string, _ *content.Stub) error {
+			exported <- struct{}{}
+			<-release
+			return nil
+		})
+		close(done)
+	}()
+
+	docs <- &content.Stub{UUID: "uuid-a"}
+	<-exported
+	require.NoError(t, fe.CancelJob(job.ID))
+
+	close(release)
+	<-done
+
+	assert.Equal(t, CANCELLED, job.Status)
+	assert.Equal(t, 1, job.Progress)
+	assert.ErrorIs(t, fe.CancelJob(job.ID), ErrJobNotRunning)
+}
+
+func TestFullExporter_RetryJob(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(5, nil, store, Retention{}, QueueLimits{})
+
+	parent := NewJob(1, 0, true, log)
+	parent.Status = FINISHED
+	parent.Failed = []Failure{{UUID: "uuid-a"}, {UUID: "uuid-b"}}
+	fe.AddJob(parent)
+
+	running := NewJob(1, 0, true, log)
+	running.Status = RUNNING
+	running.Failed = []Failure{{UUID: "uuid-c"}}
+	fe.AddJob(running)
+
+	_, _, err := fe.RetryJob("unknown", 0, "tid_test")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	_, _, err = fe.RetryJob(running.ID, 0, "tid_test")
+	assert.ErrorIs(t, err, ErrJobNotRetryable)
+
+	child, failed, err := fe.RetryJob(parent.ID, 10, "tid_test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"uuid-a", "uuid-b"}, failed)
+	assert.Equal(t, parent.ID, child.ParentJobID)
+	assert.Equal(t, "tid_test", child.TransactionID)
+	assert.False(t, child.isFullExport)
+
+	stored, err := fe.GetJob(parent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{child.ID}, stored.ChildJobIDs)
+
+	stored, err = fe.GetJob(child.ID)
+	require.NoError(t, err)
+	assert.Equal(t, STARTING, stored.Status)
+}
+
+func TestJob_PauseAndUnpause(t *testing.T) {
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(1, nil, store, Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	assert.ErrorIs(t, fe.PauseJob(job.ID), ErrJobNotRunning)
+
+	docs := make(chan *content.Stub)
+	exported := make(chan string, 2)
+	done := make(chan struct{})
+
+	go func() {
+		job.RunExport("tid", content.NewStream(docs), func(_ string, doc *content.Stub) error {
+			exported <- doc.UUID
+			return nil
+		})
+		close(done)
+	}()
+
+	docs <- &content.Stub{UUID: "uuid-a"}
+	assert.Equal(t, "uuid-a", <-exported)
+
+	require.NoError(t, fe.PauseJob(job.ID))
+	running := fe.GetRunningJobs()
+	require.Len(t, running, 1)
+	assert.Equal(t, PAUSED, running[0].Status)
+	assert.True(t, fe.IsFullExportRunning())
+
+	docs <- &content.Stub{UUID: "uuid-b"}
+	select {
+	case uuid := <-exported:
+		t.Fatalf("document %s exported while the job was paused", uuid)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.ErrorIs(t, fe.UnpauseJob("unknown"), ErrJobNotFound)
+	require.NoError(t, fe.UnpauseJob(job.ID))
+	assert.Equal(t, "uuid-b", <-exported)
+	assert.ErrorIs(t, fe.UnpauseJob(job.ID), ErrJobNotPaused)
+
+	close(docs)
+	<-done
+	assert.Equal(t, FINISHED, job.Status)
+}
+
+func TestJob_CancelPausedJob(t *testing.T) {
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(1, nil, store, Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	docs := make(chan *content.Stub, 1)
+	done := make(chan struct{})
+
+	go func() {
+		job.RunExport("tid", content.NewStream(docs), func(_ string, _ *content.Stub) error {
+			t.Error("no document should be exported")
+			return nil
+		})
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return job.getStatus() == RUNNING
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, fe.PauseJob(job.ID))
+
+	docs <- &content.Stub{UUID: "uuid-a"}
+	require.NoError(t, fe.CancelJob(job.ID))
+	<-done
+	assert.Equal(t, CANCELLED, job.Status)
+}
+
+func TestFullExporter_UpdateJobResizesWorkers(t *testing.T) {
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(1, nil, store, Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	docs := make(chan *content.Stub, 2)
+	docs <- &content.Stub{UUID: "uuid-a"}
+	docs <- &content.Stub{UUID: "uuid-b"}
+	close(docs)
+
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		job.RunExport("tid", content.NewStream(docs), func(_ string, doc *content.Stub) error {
+			started <- doc.UUID
+			<-release
+			return nil
+		})
+		close(done)
+	}()
+
+	assert.Equal(t, "uuid-a", <-started)
+	select {
+	case uuid := <-started:
+		t.Fatalf("document %s started while the only worker was busy", uuid)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	workers, throttle := 2, 5
+	updated, err := fe.UpdateJob(job.ID, JobSettings{Workers: &workers, Throttle: &throttle})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Workers)
+	assert.Equal(t, 5, updated.Throttle)
+	assert.Equal(t, "uuid-b", <-started)
+
+	close(release)
+	<-done
+	assert.Equal(t, FINISHED, job.Status)
+
+	_, err = fe.UpdateJob(job.ID, JobSettings{Workers: &workers})
+	assert.ErrorIs(t, err, ErrJobNotRunning)
+
+	invalid := 0
+	_, err = fe.UpdateJob(job.ID, JobSettings{Workers: &invalid})
+	assert.ErrorIs(t, err, ErrInvalidSettings)
+}
+
+func TestJob_SuspendedWhileCircuitIsOpen(t *testing.T) {
+	defer func(interval time.Duration) { circuitRetryInterval = interval }(circuitRetryInterval)
+	circuitRetryInterval = 10 * time.Millisecond
+
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(1, nil, store, Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	docs := make(chan *content.Stub, 2)
+	docs <- &content.Stub{UUID: "uuid-a"}
+	docs <- &content.Stub{UUID: "uuid-b"}
+	close(docs)
+
+	available := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		job.RunExport("tid", content.NewStream(docs), func(_ string, _ *content.Stub) error {
+			select {
+			case <-available:
+				return nil
+			default:
+				return &content.ExportError{Stage: content.FetchStage, Err: fmt.Errorf("%w: enriched content is unavailable", content.ErrCircuitOpen)}
+			}
+		})
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return job.getStatus() == PAUSED
+	}, time.Second, 10*time.Millisecond)
+	stored, err := fe.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Contains(t, stored.ErrorMessage, "Paused while downstream services are unavailable")
+	assert.Equal(t, 1, stored.Progress)
+
+	close(available)
+	<-done
+
+	assert.Equal(t, FINISHED, job.Status)
+	assert.Empty(t, job.Failed)
+	assert.Empty(t, job.ErrorMessage)
+	assert.Equal(t, 2, job.Progress)
+}
+
+func TestJob_CancelSuspendedJob(t *testing.T) {
+	defer func(interval time.Duration) { circuitRetryInterval = interval }(circuitRetryInterval)
+	circuitRetryInterval = time.Hour
+
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(1, nil, store, Retention{}, QueueLimits{})
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	docs := make(chan *content.Stub, 1)
+	docs <- &content.Stub{UUID: "uuid-a"}
+	close(docs)
+	done := make(chan struct{})
+
+	go func() {
+		job.RunExport("tid", content.NewStream(docs), func(_ string, _ *content.Stub) error {
+			return &content.ExportError{Stage: content.UploadStage, Err: content.ErrCircuitOpen}
+		})
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return job.getStatus() == PAUSED
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, fe.CancelJob(job.ID))
+	<-done
+
+	assert.Equal(t, CANCELLED, job.Status)
+	require.Len(t, job.Failed, 1)
+	assert.Equal(t, "uuid-a", job.Failed[0].UUID)
+	assert.Equal(t, "upload", job.Failed[0].Stage)
+}
+
+func TestFullExporter_AddJobUnlessFullExportRunningAddsOneOfConcurrentJobs(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	added := make(chan bool, 10)
+	var wg sync.WaitGroup
+	for range cap(added) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			added <- fe.AddJobUnlessFullExportRunning(NewJob(1, 0, true, log))
+		}()
+	}
+	wg.Wait()
+	close(added)
+
+	count := 0
+	for ok := range added {
+		if ok {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count)
+	assert.Len(t, fe.store.List(), 1)
+}
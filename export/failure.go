@@ -0,0 +1,79 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Financial-Times/content-exporter/content"
+)
+
+// Failure describes why a document could not be exported.
+type Failure struct {
+	UUID       string `json:"UUID"`
+	Stage      string `json:"Stage,omitempty"`
+	StatusCode int    `json:"StatusCode,omitempty"`
+	Timeout    bool   `json:"Timeout,omitempty"`
+	Error      string `json:"Error"`
+}
+
+func newFailure(uuid string, err error) Failure {
+	f := Failure{
+		UUID:  uuid,
+		Error: err.Error(),
+	}
+
+	var exportErr *content.ExportError
+	if errors.As(err, &exportErr) {
+		f.Stage = string(exportErr.Stage)
+	}
+
+	var statusErr *content.UnexpectedStatusError
+	if errors.As(err, &statusErr) {
+		f.StatusCode = statusErr.StatusCode
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		f.Timeout = true
+	}
+
+	return f
+}
+
+// reason groups failures by stage and cause, e.g. "fetch: status 404" or "upload: timeout".
+func (f Failure) reason() string {
+	stage := f.Stage
+	if stage == "" {
+		stage = "unknown"
+	}
+
+	switch {
+	case f.StatusCode != 0:
+		return fmt.Sprintf("%s: status %d", stage, f.StatusCode)
+	case f.Timeout:
+		return fmt.Sprintf("%s: timeout", stage)
+	default:
+		return fmt.Sprintf("%s: error", stage)
+	}
+}
+
+func countFailureReasons(failures []Failure) map[string]int {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	reasons := make(map[string]int)
+	for _, f := range failures {
+		reasons[f.reason()]++
+	}
+	return reasons
+}
+
+func failedUUIDs(failures []Failure) []string {
+	uuids := make([]string, 0, len(failures))
+	for _, f := range failures {
+		uuids = append(uuids, f.UUID)
+	}
+	return uuids
+}
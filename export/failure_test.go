@@ -0,0 +1,81 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/stretchr/testify/assert"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestNewFailure(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expected       Failure
+		expectedReason string
+	}{
+		{
+			name: "unexpected status from enriched content",
+			err: &content.ExportError{
+				Stage: content.FetchStage,
+				Err:   &content.UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: http.StatusNotFound},
+			},
+			expected: Failure{
+				UUID:       "uuid1",
+				Stage:      "fetch",
+				StatusCode: http.StatusNotFound,
+				Error:      "getting content: fetching enriched content failed with unexpected status code: 404",
+			},
+			expectedReason: "fetch: status 404",
+		},
+		{
+			name: "timeout from S3 writer",
+			err:  &content.ExportError{Stage: content.UploadStage, Err: timeoutError{}},
+			expected: Failure{
+				UUID:    "uuid1",
+				Stage:   "upload",
+				Timeout: true,
+				Error:   "uploading content: i/o timeout",
+			},
+			expectedReason: "upload: timeout",
+		},
+		{
+			name: "unknown error",
+			err:  fmt.Errorf("some error: %w", context.Canceled),
+			expected: Failure{
+				UUID:  "uuid1",
+				Error: "some error: context canceled",
+			},
+			expectedReason: "unknown: error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFailure("uuid1", test.err)
+
+			assert.Equal(t, test.expected, f)
+			assert.Equal(t, test.expectedReason, f.reason())
+		})
+	}
+}
+
+func TestCountFailureReasons(t *testing.T) {
+	failures := []Failure{
+		{UUID: "uuid1", Stage: "fetch", StatusCode: 404},
+		{UUID: "uuid2", Stage: "fetch", StatusCode: 404},
+		{UUID: "uuid3", Stage: "upload", StatusCode: 503},
+	}
+
+	assert.Equal(t, map[string]int{"fetch: status 404": 2, "upload: status 503": 1}, countFailureReasons(failures))
+	assert.Nil(t, countFailureReasons(nil))
+}
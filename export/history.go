@@ -0,0 +1,102 @@
+package export
+
+import (
+	"sort"
+	"time"
+)
+
+// Retention limits how many ended jobs are kept and for how long. Zero values don't limit.
+type Retention struct {
+	MaxAge  time.Duration
+	MaxJobs int
+}
+
+// ListJobs returns the jobs in the given states, the most recently started first. Without states every job is
+// returned, and a limit of 0 returns all of them.
+func (fe *FullExporter) ListJobs(states []State, limit int) []Job {
+	fe.evictJobs()
+
+	var jobs []Job
+	for _, job := range fe.store.List() {
+		if len(states) == 0 || hasState(states, job.getStatus()) {
+			jobs = append(jobs, job.Copy())
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return startedAfter(jobs[i], jobs[j])
+	})
+
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
+
+func hasState(states []State, status State) bool {
+	for _, s := range states {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func startedAfter(a, b Job) bool {
+	switch {
+	case a.StartTime == nil || b.StartTime == nil:
+		return a.StartTime != nil
+	case !a.StartTime.Equal(*b.StartTime):
+		return a.StartTime.After(*b.StartTime)
+	default:
+		return a.ID < b.ID
+	}
+}
+
+// evictJobs removes the ended jobs which ended before the retention period, and the oldest ones beyond
+// the maximum number of kept jobs. Jobs which are still in progress are always kept.
+func (fe *FullExporter) evictJobs() {
+	if fe.retention.MaxAge <= 0 && fe.retention.MaxJobs <= 0 {
+		return
+	}
+
+	type ended struct {
+		id string
+		at time.Time
+	}
+	var jobs []ended
+	for _, job := range fe.store.List() {
+		job.lock.RLock()
+		if !job.Status.isActive() {
+			jobs = append(jobs, ended{id: job.ID, at: job.endedAt()})
+		}
+		job.lock.RUnlock()
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].at.After(jobs[j].at)
+	})
+
+	cutoff := time.Now().Add(-fe.retention.MaxAge)
+	var evicted []string
+	for i, job := range jobs {
+		tooMany := fe.retention.MaxJobs > 0 && i >= fe.retention.MaxJobs
+		tooOld := fe.retention.MaxAge > 0 && job.at.Before(cutoff)
+		if tooMany || tooOld {
+			evicted = append(evicted, job.id)
+		}
+	}
+	if len(evicted) > 0 {
+		fe.store.Delete(evicted...)
+	}
+}
+
+// endedAt returns when the job ended. Jobs which were interrupted by a service restart are dated by their start.
+func (job *Job) endedAt() time.Time {
+	switch {
+	case job.EndTime != nil:
+		return *job.EndTime
+	case job.StartTime != nil:
+		return *job.StartTime
+	default:
+		return time.Time{}
+	}
+}
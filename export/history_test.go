@@ -0,0 +1,87 @@
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEndedJob(status State, started, ended time.Time, log *logger.UPPLogger) *Job {
+	job := NewJob(1, 0, true, log)
+	job.Status = status
+	job.StartTime = &started
+	job.EndTime = &ended
+	return job
+}
+
+func TestFullExporter_ListJobs(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	now := time.Now()
+
+	oldest := newEndedJob(FINISHED, now.Add(-3*time.Hour), now.Add(-2*time.Hour), log)
+	cancelled := newEndedJob(CANCELLED, now.Add(-2*time.Hour), now.Add(-time.Hour), log)
+	latest := newEndedJob(FINISHED, now.Add(-time.Hour), now, log)
+	running := NewJob(1, 0, true, log)
+	running.Status = RUNNING
+	for _, job := range []*Job{oldest, cancelled, latest, running} {
+		fe.AddJob(job)
+	}
+
+	jobs := fe.ListJobs([]State{FINISHED}, 0)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, latest.ID, jobs[0].ID)
+	assert.Equal(t, oldest.ID, jobs[1].ID)
+	assert.Equal(t, "1h0m0s", jobs[1].Duration)
+
+	jobs = fe.ListJobs(nil, 2)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, running.ID, jobs[0].ID)
+	assert.Equal(t, latest.ID, jobs[1].ID)
+
+	jobs = fe.ListJobs([]State{FINISHED, CANCELLED}, 0)
+	assert.Len(t, jobs, 3)
+}
+
+func TestFullExporter_EvictsJobsBeyondRetention(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	store := NewMemoryJobStore()
+	fe := NewFullExporter(1, nil, store, Retention{MaxAge: 24 * time.Hour, MaxJobs: 2}, QueueLimits{})
+	now := time.Now()
+
+	expired := newEndedJob(FINISHED, now.Add(-50*time.Hour), now.Add(-49*time.Hour), log)
+	older := newEndedJob(FINISHED, now.Add(-5*time.Hour), now.Add(-4*time.Hour), log)
+	old := newEndedJob(CANCELLED, now.Add(-3*time.Hour), now.Add(-2*time.Hour), log)
+	recent := newEndedJob(FINISHED, now.Add(-2*time.Hour), now.Add(-time.Hour), log)
+	// jobs in progress are kept however old they are
+	running := newEndedJob(RUNNING, now.Add(-72*time.Hour), now, log)
+	running.EndTime = nil
+	for _, job := range []*Job{expired, older, old, recent, running} {
+		fe.AddJob(job)
+	}
+
+	_, ok := store.Get(expired.ID)
+	assert.False(t, ok, "the expired job should be evicted")
+	_, ok = store.Get(older.ID)
+	assert.False(t, ok, "the oldest job beyond the maximum number of jobs should be evicted")
+	for _, job := range []*Job{old, recent, running} {
+		_, ok = store.Get(job.ID)
+		assert.True(t, ok)
+	}
+}
+
+func TestJob_EndTimeIsSetWhenTheJobEnds(t *testing.T) {
+	job := NewJob(1, 0, true, logger.NewUPPLogger("test", "PANIC"))
+	require.NotNil(t, job.StartTime)
+
+	job.setStatus(RUNNING)
+	assert.Nil(t, job.EndTime)
+	assert.NotEmpty(t, job.Copy().Duration)
+
+	job.setStatus(FINISHED)
+	require.NotNil(t, job.EndTime)
+	assert.False(t, job.EndTime.Before(*job.StartTime))
+}
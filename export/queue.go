@@ -0,0 +1,288 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority decides the order in which queued jobs are started and in which running jobs get workers
+// from the shared worker budget.
+type Priority string
+
+const (
+	LowPriority    Priority = "Low"
+	NormalPriority Priority = "Normal"
+	HighPriority   Priority = "High"
+)
+
+var priorities = []Priority{LowPriority, NormalPriority, HighPriority}
+
+// ParsePriority returns the priority with the given case-insensitive name.
+func ParsePriority(value string) (Priority, error) {
+	for _, p := range priorities {
+		if strings.EqualFold(string(p), value) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("invalid priority %q, should be one of low, normal and high", value)
+}
+
+func (p Priority) rank() int {
+	for i, priority := range priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return 0
+}
+
+// QueueLimits limit how many jobs run at the same time and how many workers they use together. Zero values don't limit.
+type QueueLimits struct {
+	MaxRunningJobs int
+	WorkerBudget   int
+}
+
+// jobQueue decides when the enqueued jobs start. Jobs going through the whole DB, i.e. full and filtered exports
+// and reconciliations, run one at a time, while targeted jobs run alongside them. Waiting jobs are started by
+// priority, then in the order they were enqueued.
+type jobQueue struct {
+	sync.Mutex
+	limits  QueueLimits
+	budget  *workerBudget
+	waiting []*queuedJob
+	running map[*Job]struct{}
+}
+
+type queuedJob struct {
+	job   *Job
+	start chan struct{}
+}
+
+func newJobQueue(limits QueueLimits) *jobQueue {
+	q := &jobQueue{
+		limits:  limits,
+		running: make(map[*Job]struct{}),
+	}
+	if limits.WorkerBudget > 0 {
+		q.budget = newWorkerBudget(limits.WorkerBudget)
+	}
+	return q
+}
+
+// Enqueue starts the job right away if it may run alongside the running jobs, otherwise it is QUEUED until
+// its turn comes. Targeted jobs get the high priority and other jobs the normal one unless the job has one already.
+// The job leaves the queue when it ends.
+func (fe *FullExporter) Enqueue(job *Job) {
+	job.lock.Lock()
+	if job.Priority == "" {
+		job.Priority = NormalPriority
+		if !job.isFullExport {
+			job.Priority = HighPriority
+		}
+	}
+	job.queue = fe.queue
+	job.budget = fe.queue.budget
+	job.lock.Unlock()
+
+	if !fe.queue.add(job) {
+		job.log.Infof("Job %v is queued", job.ID)
+		job.stateChanged()
+	}
+}
+
+// WaitTurn blocks while the job is queued. It returns false if the job is cancelled before its turn comes,
+// in which case it has been marked as cancelled.
+func (fe *FullExporter) WaitTurn(job *Job) bool {
+	start := fe.queue.startOf(job)
+	if start == nil {
+		return true
+	}
+
+	select {
+	case <-start:
+		return true
+	case <-job.ctx.Done():
+	}
+
+	if !fe.queue.remove(job) {
+		// The job was started in the meantime, so it ends as any other cancelled job does
+		return true
+	}
+	job.log.Infof("Job %v was cancelled while queued", job.ID)
+	job.setStatus(CANCELLED)
+	return false
+}
+
+// add starts the job if it can run now and queues it otherwise. A queued job is marked as such before it can be
+// started by finish. It tells whether the job was started.
+func (q *jobQueue) add(job *Job) bool {
+	q.Lock()
+	defer q.Unlock()
+	if q.canStart(job) {
+		q.running[job] = struct{}{}
+		return true
+	}
+
+	job.lock.Lock()
+	job.Status = QUEUED
+	job.lock.Unlock()
+
+	entry := &queuedJob{job: job, start: make(chan struct{})}
+	i := len(q.waiting)
+	for i > 0 && q.waiting[i-1].job.Priority.rank() < job.Priority.rank() {
+		i--
+	}
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[i+1:], q.waiting[i:])
+	q.waiting[i] = entry
+	return false
+}
+
+// canStart tells whether the job may run alongside the running jobs. Callers hold the queue lock.
+func (q *jobQueue) canStart(job *Job) bool {
+	if q.limits.MaxRunningJobs > 0 && len(q.running) >= q.limits.MaxRunningJobs {
+		return false
+	}
+	if !job.isFullExport {
+		return true
+	}
+	for running := range q.running {
+		if running.isFullExport {
+			return false
+		}
+	}
+	return true
+}
+
+// startOf returns the channel which is closed when the queued job starts, or nil if the job is not waiting.
+func (q *jobQueue) startOf(job *Job) chan struct{} {
+	q.Lock()
+	defer q.Unlock()
+	for _, entry := range q.waiting {
+		if entry.job == job {
+			return entry.start
+		}
+	}
+	return nil
+}
+
+// remove takes a job off the waiting list. It tells whether the job was still waiting.
+func (q *jobQueue) remove(job *Job) bool {
+	q.Lock()
+	defer q.Unlock()
+	for i, entry := range q.waiting {
+		if entry.job == job {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// finish lets the waiting jobs take the place of an ended job.
+func (q *jobQueue) finish(job *Job) {
+	q.Lock()
+	delete(q.running, job)
+	var started []*queuedJob
+	waiting := q.waiting[:0]
+	for _, entry := range q.waiting {
+		switch {
+		case entry.job == job:
+		case q.canStart(entry.job):
+			q.running[entry.job] = struct{}{}
+			started = append(started, entry)
+		default:
+			waiting = append(waiting, entry)
+		}
+	}
+	q.waiting = waiting
+	q.Unlock()
+
+	for _, entry := range started {
+		entry.job.dequeue()
+		close(entry.start)
+	}
+}
+
+// dequeue marks a queued job as starting. Its start time is when it leaves the queue.
+func (job *Job) dequeue() {
+	job.lock.Lock()
+	now := time.Now().UTC()
+	job.StartTime = &now
+	job.lock.Unlock()
+	job.log.Infof("Job %v leaves the queue", job.ID)
+	job.setStatus(STARTING)
+}
+
+// workerBudget limits the workers of all the running jobs together. A free worker goes to the job with
+// the highest priority waiting for one, so a targeted job takes over the workers of a full export as they
+// finish their documents.
+type workerBudget struct {
+	sync.Mutex
+	size    int
+	busy    int
+	waiting map[Priority]int
+	changed chan struct{}
+}
+
+func newWorkerBudget(size int) *workerBudget {
+	return &workerBudget{
+		size:    size,
+		waiting: make(map[Priority]int),
+		changed: make(chan struct{}),
+	}
+}
+
+// acquire blocks until a worker is free and no job with a higher priority waits for one.
+// It returns false if the context is cancelled in the meantime.
+func (b *workerBudget) acquire(ctx context.Context, priority Priority) bool {
+	b.Lock()
+	b.waiting[priority]++
+	defer func() {
+		b.waiting[priority]--
+		b.broadcast()
+		b.Unlock()
+	}()
+
+	for {
+		if b.busy < b.size && !b.higherWaiting(priority) {
+			b.busy++
+			return true
+		}
+
+		changed := b.changed
+		b.Unlock()
+		select {
+		case <-changed:
+			b.Lock()
+		case <-ctx.Done():
+			b.Lock()
+			return false
+		}
+	}
+}
+
+func (b *workerBudget) release() {
+	b.Lock()
+	defer b.Unlock()
+	b.busy--
+	b.broadcast()
+}
+
+func (b *workerBudget) higherWaiting(priority Priority) bool {
+	for p, n := range b.waiting {
+		if n > 0 && p.rank() > priority.rank() {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcast wakes up every job waiting for a worker. Callers hold the budget lock.
+func (b *workerBudget) broadcast() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
@@ -0,0 +1,187 @@
+package export
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriority(t *testing.T) {
+	priority, err := ParsePriority("high")
+	assert.NoError(t, err)
+	assert.Equal(t, HighPriority, priority)
+
+	_, err = ParsePriority("urgent")
+	assert.EqualError(t, err, "invalid priority \"urgent\", should be one of low, normal and high")
+}
+
+func TestFullExporter_EnqueueRunsTargetedJobsAlongsideAFullExport(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+
+	full := NewJob(1, 0, true, log)
+	fe.AddJob(full)
+	fe.Enqueue(full)
+	targeted := NewJob(1, 0, false, log)
+	fe.AddJob(targeted)
+	fe.Enqueue(targeted)
+	secondFull := NewJob(1, 0, true, log)
+	fe.AddJob(secondFull)
+	fe.Enqueue(secondFull)
+
+	assert.Equal(t, STARTING, full.getStatus())
+	assert.Equal(t, NormalPriority, full.Copy().Priority)
+	assert.Equal(t, STARTING, targeted.getStatus())
+	assert.Equal(t, HighPriority, targeted.Copy().Priority)
+	assert.Equal(t, QUEUED, secondFull.getStatus())
+	assert.True(t, fe.WaitTurn(full))
+	assert.True(t, fe.WaitTurn(targeted))
+
+	started := make(chan bool, 1)
+	go func() {
+		started <- fe.WaitTurn(secondFull)
+	}()
+
+	runEmptyExport(full)
+	select {
+	case ok := <-started:
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("the queued full export was not started")
+	}
+	assert.Equal(t, STARTING, secondFull.getStatus())
+}
+
+func TestFullExporter_EnqueueStartsJobsByPriority(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{MaxRunningJobs: 1})
+
+	running := NewJob(1, 0, false, log)
+	low := NewJob(1, 0, false, log)
+	low.Priority = LowPriority
+	normal := NewJob(1, 0, true, log)
+	high := NewJob(1, 0, false, log)
+	for _, job := range []*Job{running, low, normal, high} {
+		fe.AddJob(job)
+		fe.Enqueue(job)
+	}
+	assert.Equal(t, STARTING, running.getStatus())
+	assert.Equal(t, QUEUED, low.getStatus())
+	assert.Equal(t, QUEUED, normal.getStatus())
+	assert.Equal(t, QUEUED, high.getStatus())
+
+	runEmptyExport(running)
+	assert.Equal(t, STARTING, high.getStatus())
+	assert.Equal(t, QUEUED, normal.getStatus())
+
+	runEmptyExport(high)
+	assert.Equal(t, STARTING, normal.getStatus())
+	assert.Equal(t, QUEUED, low.getStatus())
+}
+
+func TestFullExporter_EnqueueDoesNotOverwriteTheStartOfAQueuedJob(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+
+	running := NewJob(1, 0, true, log)
+	fe.AddJob(running)
+	fe.Enqueue(running)
+	queued := NewJob(1, 0, true, log)
+	fe.AddJob(queued)
+	queued.queue = fe.queue
+
+	// The running job ends between the queueing of the job and the publication of its state
+	require.False(t, fe.queue.add(queued))
+	assert.Equal(t, QUEUED, queued.getStatus())
+	runEmptyExport(running)
+	queued.stateChanged()
+
+	assert.Equal(t, STARTING, queued.getStatus())
+	assert.True(t, fe.WaitTurn(queued))
+}
+
+func TestFullExporter_CancelQueuedJob(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+
+	running := NewJob(1, 0, true, log)
+	queued := NewJob(1, 0, true, log)
+	for _, job := range []*Job{running, queued} {
+		fe.AddJob(job)
+		fe.Enqueue(job)
+	}
+
+	started := make(chan bool, 1)
+	go func() {
+		started <- fe.WaitTurn(queued)
+	}()
+	require.NoError(t, fe.CancelJob(queued.ID))
+
+	select {
+	case ok := <-started:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("the cancelled job is still queued")
+	}
+	assert.Equal(t, CANCELLED, queued.getStatus())
+	assert.NotNil(t, queued.Copy().EndTime)
+
+	// The cancelled job no longer waits for the running one
+	runEmptyExport(running)
+	assert.Equal(t, CANCELLED, queued.getStatus())
+}
+
+func TestWorkerBudget_PrefersHigherPriority(t *testing.T) {
+	budget := newWorkerBudget(1)
+	require.True(t, budget.acquire(context.Background(), NormalPriority))
+
+	acquired := make(chan Priority, 2)
+	go func() {
+		if budget.acquire(context.Background(), NormalPriority) {
+			acquired <- NormalPriority
+		}
+	}()
+	require.Eventually(t, func() bool {
+		budget.Lock()
+		defer budget.Unlock()
+		return budget.waiting[NormalPriority] == 1
+	}, time.Second, 10*time.Millisecond)
+	go func() {
+		if budget.acquire(context.Background(), HighPriority) {
+			acquired <- HighPriority
+		}
+	}()
+	require.Eventually(t, func() bool {
+		budget.Lock()
+		defer budget.Unlock()
+		return budget.waiting[HighPriority] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	budget.release()
+	assert.Equal(t, HighPriority, <-acquired)
+	budget.release()
+	assert.Equal(t, NormalPriority, <-acquired)
+}
+
+func TestWorkerBudget_AcquireIsCancelled(t *testing.T) {
+	budget := newWorkerBudget(1)
+	require.True(t, budget.acquire(context.Background(), NormalPriority))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, budget.acquire(ctx, HighPriority))
+	assert.Zero(t, budget.waiting[HighPriority])
+}
+
+func runEmptyExport(job *Job) {
+	docs := make(chan *content.Stub)
+	close(docs)
+	job.RunExport("tid", content.NewStream(docs), func(string, *content.Stub) error {
+		return nil
+	})
+}
@@ -0,0 +1,202 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+// Discrepancy is a kind of difference between the content in Mongo and the objects in S3.
+type Discrepancy string
+
+const (
+	// Missing content is in Mongo but not in S3.
+	Missing Discrepancy = "missing"
+	// Stale content was modified in Mongo after its object was last written to S3.
+	Stale Discrepancy = "stale"
+	// Orphaned objects are in S3 but their content is no longer exported from Mongo.
+	Orphaned Discrepancy = "orphaned"
+)
+
+var ErrNotReconciliation = fmt.Errorf("job is not a reconciliation")
+
+// ReconcileReport counts the discrepancies a reconciliation found so far. Orphaned objects are only known once
+// every document has been compared with the listing.
+type ReconcileReport struct {
+	Fix      bool `json:"Fix,omitempty"`
+	Listed   int  `json:"Listed"`
+	Missing  int  `json:"Missing"`
+	Stale    int  `json:"Stale"`
+	Orphaned int  `json:"Orphaned"`
+}
+
+func (r *ReconcileReport) copy() *ReconcileReport {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	return &c
+}
+
+// NewReconcileJob creates a job which compares the exportable content in Mongo with the listing of the S3 bucket.
+// If fix is set, missing and stale content is exported again and orphaned objects are deleted.
+func NewReconcileJob(nrWorker, contentRetrievalThrottle int, fix bool, listing content.S3Listing, log *logger.UPPLogger) *Job {
+	job := NewJob(nrWorker, contentRetrievalThrottle, true, log)
+	job.listing = listing
+	job.discrepancies = make(map[Discrepancy][]string)
+	job.Reconciliation = &ReconcileReport{
+		Fix:    fix,
+		Listed: len(listing),
+	}
+	return job
+}
+
+// SetListing sets the S3 listing of a reconciliation which was created without one.
+func (job *Job) SetListing(listing content.S3Listing) {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.listing = listing
+	job.Reconciliation.Listed = len(listing)
+}
+
+// GetReconciliation returns the UUIDs of the given kind of discrepancy found so far by a reconciliation.
+func (fe *FullExporter) GetReconciliation(jobID string, discrepancy Discrepancy) ([]string, error) {
+	job, ok := fe.store.Get(jobID)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	if job.discrepancies == nil {
+		return nil, ErrNotReconciliation
+	}
+	return append([]string(nil), job.discrepancies[discrepancy]...), nil
+}
+
+// RunReconcile compares the documents in the stream with the S3 listing of the job. Deleting orphaned objects
+// requires the whole stream to be read without error, so a cancelled reconciliation, or one whose stream is cut
+// short, neither reports nor deletes orphaned objects.
+func (job *Job) RunReconcile(tid string, docs *content.Stream, export func(string, *content.Stub) error, remove func(string, string) error) {
+	job.log.Infof("Reconciliation started: %v", job.ID)
+	job.startRun()
+	fix := job.Reconciliation.Fix
+
+	complete := false
+	var streamErr error
+compare:
+	for {
+		select {
+		case <-job.ctx.Done():
+			break compare
+		case doc, ok := <-docs.Docs:
+			if !ok {
+				streamErr = docs.Err()
+				complete = streamErr == nil
+				break compare
+			}
+			if !job.waitWhilePaused() {
+				break compare
+			}
+
+			if job.compare(doc) == "" || !fix {
+				continue
+			}
+			if !job.acquireWorker() {
+				break compare
+			}
+			job.startWorker(tid, doc.UUID, func() error {
+				return job.exportDocument(tid, doc, export)
+			}, nil)
+		}
+	}
+
+	if complete && job.ctx.Err() == nil {
+		orphaned := job.collectOrphaned()
+		if fix {
+			job.deleteOrphaned(tid, orphaned, remove)
+		}
+	}
+
+	job.wg.Wait()
+
+	status := job.endStatus(streamErr)
+	job.setStatus(status)
+
+	result := job.Copy()
+	job.log.Infof("%s reconciliation %v with %v missing, %v stale, %v orphaned and %v failure(s)",
+		status, job.ID, result.Reconciliation.Missing, result.Reconciliation.Stale, result.Reconciliation.Orphaned, len(result.Failed))
+}
+
+// compare records whether the document is missing from the S3 listing or is stale there.
+// It returns an empty discrepancy if the object in S3 is up-to-date.
+func (job *Job) compare(doc *content.Stub) Discrepancy {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.Progress++
+
+	listedAt, listed := job.listing[doc.UUID]
+	delete(job.listing, doc.UUID)
+
+	var discrepancy Discrepancy
+	switch {
+	case !listed:
+		discrepancy = Missing
+		job.Reconciliation.Missing++
+	case modifiedAfter(doc.LastModified, listedAt):
+		discrepancy = Stale
+		job.Reconciliation.Stale++
+	default:
+		return ""
+	}
+	job.discrepancies[discrepancy] = append(job.discrepancies[discrepancy], doc.UUID)
+	return discrepancy
+}
+
+// collectOrphaned records the listed objects which weren't found in the document stream as orphaned.
+func (job *Job) collectOrphaned() []string {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	orphaned := make([]string, 0, len(job.listing))
+	for uuid := range job.listing {
+		orphaned = append(orphaned, uuid)
+	}
+	sort.Strings(orphaned)
+
+	job.listing = nil
+	job.discrepancies[Orphaned] = orphaned
+	job.Reconciliation.Orphaned = len(orphaned)
+	return orphaned
+}
+
+func (job *Job) deleteOrphaned(tid string, orphaned []string, remove func(string, string) error) {
+	for _, uuid := range orphaned {
+		if !job.waitWhilePaused() || !job.acquireWorker() {
+			return
+		}
+
+		doc := &content.Stub{UUID: uuid}
+		job.startWorker(tid, uuid, func() error {
+			return job.exportDocument(tid, doc, func(tid string, doc *content.Stub) error {
+				return remove(doc.UUID, tid)
+			})
+		}, nil)
+	}
+}
+
+// modifiedAfter tells whether the lastModified timestamp of a document is after the time its object was written.
+// Without either of them the object can't be considered stale.
+func modifiedAfter(lastModified string, listedAt time.Time) bool {
+	if lastModified == "" || listedAt.IsZero() {
+		return false
+	}
+	modified, err := time.Parse(time.RFC3339, lastModified)
+	if err != nil {
+		return false
+	}
+	return modified.After(listedAt)
+}
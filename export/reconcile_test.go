@@ -0,0 +1,170 @@
+package export
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestListing() content.S3Listing {
+	return content.S3Listing{
+		"uuid-a": time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		"uuid-b": time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		"uuid-c": time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		"uuid-e": {},
+	}
+}
+
+func reconcileTestDocs() *content.Stream {
+	docs := make(chan *content.Stub, 4)
+	docs <- &content.Stub{UUID: "uuid-a", LastModified: "2024-03-01T09:00:00.000Z"}
+	docs <- &content.Stub{UUID: "uuid-b", LastModified: "2024-03-01T11:00:00.000Z"}
+	docs <- &content.Stub{UUID: "uuid-d", LastModified: "2024-03-01T11:00:00.000Z"}
+	docs <- &content.Stub{UUID: "uuid-e", LastModified: "2024-03-01T11:00:00.000Z"}
+	close(docs)
+	return content.NewStream(docs)
+}
+
+func TestJob_RunReconcile(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	job := NewReconcileJob(2, 0, false, newTestListing(), logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	unexpected := func(string, *content.Stub) error {
+		return fmt.Errorf("unexpected export")
+	}
+	unexpectedDelete := func(string, string) error {
+		return fmt.Errorf("unexpected delete")
+	}
+	job.RunReconcile("tid_1234", reconcileTestDocs(), unexpected, unexpectedDelete)
+
+	stored, err := fe.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FINISHED, stored.Status)
+	assert.Equal(t, 4, stored.Progress)
+	assert.Empty(t, stored.Failed)
+	assert.Equal(t, &ReconcileReport{Listed: 4, Missing: 1, Stale: 1, Orphaned: 1}, stored.Reconciliation)
+
+	for discrepancy, expected := range map[Discrepancy][]string{
+		Missing:  {"uuid-d"},
+		Stale:    {"uuid-b"},
+		Orphaned: {"uuid-c"},
+	} {
+		uuids, err := fe.GetReconciliation(job.ID, discrepancy)
+		require.NoError(t, err)
+		assert.Equal(t, expected, uuids, discrepancy)
+	}
+}
+
+func TestJob_RunReconcileFixesDiscrepancies(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	job := NewReconcileJob(2, 0, true, newTestListing(), logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+	assert.True(t, fe.IsFullExportRunning())
+
+	var lock sync.Mutex
+	var exported, deleted []string
+	export := func(_ string, doc *content.Stub) error {
+		lock.Lock()
+		defer lock.Unlock()
+		exported = append(exported, doc.UUID)
+		if doc.UUID == "uuid-d" {
+			return fmt.Errorf("uploading content: connection reset")
+		}
+		return nil
+	}
+	remove := func(uuid, tid string) error {
+		assert.Equal(t, "tid_1234", tid)
+		lock.Lock()
+		defer lock.Unlock()
+		deleted = append(deleted, uuid)
+		return nil
+	}
+	job.RunReconcile("tid_1234", reconcileTestDocs(), export, remove)
+
+	stored, err := fe.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FINISHED, stored.Status)
+	assert.ElementsMatch(t, []string{"uuid-b", "uuid-d"}, exported)
+	assert.Equal(t, []string{"uuid-c"}, deleted)
+	require.Len(t, stored.Failed, 1)
+	assert.Equal(t, "uuid-d", stored.Failed[0].UUID)
+	assert.False(t, fe.IsFullExportRunning())
+}
+
+func TestJob_RunReconcileCancelledReportsNoOrphaned(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	job := NewReconcileJob(1, 0, true, newTestListing(), logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+	require.NoError(t, fe.CancelJob(job.ID))
+
+	docs := make(chan *content.Stub)
+	job.RunReconcile("tid_1234", content.NewStream(docs), nil, func(string, string) error {
+		return fmt.Errorf("unexpected delete")
+	})
+
+	stored, err := fe.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, CANCELLED, stored.Status)
+	assert.Equal(t, 0, stored.Reconciliation.Orphaned)
+
+	uuids, err := fe.GetReconciliation(job.ID, Orphaned)
+	require.NoError(t, err)
+	assert.Empty(t, uuids)
+}
+
+func TestJob_RunReconcileCutShortReportsNoOrphaned(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	job := NewReconcileJob(1, 0, true, newTestListing(), logger.NewUPPLogger("test", "PANIC"))
+	fe.AddJob(job)
+
+	docs := content.NewStream(make(chan *content.Stub, 1))
+	docs.Docs <- &content.Stub{UUID: "uuid-a", LastModified: "2024-03-01T09:00:00.000Z"}
+	docs.End(fmt.Errorf("iterating over collection: cursor killed"))
+	var deleted []string
+	job.RunReconcile("tid_1234", docs, nil, func(uuid string, _ string) error {
+		deleted = append(deleted, uuid)
+		return nil
+	})
+
+	stored, err := fe.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, INTERRUPTED, stored.Status)
+	assert.Contains(t, stored.ErrorMessage, "cursor killed")
+	assert.Equal(t, 1, stored.Progress)
+	assert.Equal(t, 0, stored.Reconciliation.Orphaned)
+
+	uuids, err := fe.GetReconciliation(job.ID, Orphaned)
+	require.NoError(t, err)
+	assert.Empty(t, uuids)
+	assert.Empty(t, deleted, "no object should be deleted when the stream ends early")
+}
+
+func TestFullExporter_GetReconciliation(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	export := NewJob(1, 0, true, log)
+	fe.AddJob(export)
+
+	_, err := fe.GetReconciliation("unknown", Missing)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	_, err = fe.GetReconciliation(export.ID, Missing)
+	assert.ErrorIs(t, err, ErrNotReconciliation)
+}
+
+func TestFullExporter_ResumeJobRejectsReconciliation(t *testing.T) {
+	fe := NewFullExporter(1, nil, NewMemoryJobStore(), Retention{}, QueueLimits{})
+	job := NewReconcileJob(1, 0, true, nil, logger.NewUPPLogger("test", "PANIC"))
+	job.Status = INTERRUPTED
+	fe.AddJob(job)
+
+	_, err := fe.ResumeJob(job.ID, 0)
+	assert.ErrorIs(t, err, ErrJobNotResumable)
+}
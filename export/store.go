@@ -0,0 +1,428 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+const interruptedJobMessage = "Job was interrupted by a service restart"
+
+// minCompactedFailures is the number of failures in the failure log below which it isn't compacted while in use.
+var minCompactedFailures = 10000
+
+// JobStore keeps track of the export jobs known to the service.
+type JobStore interface {
+	Save(job *Job)
+	Get(jobID string) (*Job, bool)
+	List() []*Job
+	Delete(jobIDs ...string)
+}
+
+type MemoryJobStore struct {
+	sync.RWMutex
+	jobs map[string]*Job
+}
+
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{
+		jobs: make(map[string]*Job),
+	}
+}
+
+func (s *MemoryJobStore) Save(job *Job) {
+	s.Lock()
+	defer s.Unlock()
+	s.jobs[job.ID] = job
+}
+
+func (s *MemoryJobStore) Get(jobID string) (*Job, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	job, ok := s.jobs[jobID]
+	return job, ok
+}
+
+func (s *MemoryJobStore) List() []*Job {
+	s.RLock()
+	defer s.RUnlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func (s *MemoryJobStore) Delete(jobIDs ...string) {
+	s.Lock()
+	defer s.Unlock()
+	for _, jobID := range jobIDs {
+		delete(s.jobs, jobID)
+	}
+}
+
+// storedJob is the on-disk representation of a job. Its failures are kept apart, see failureRecord.
+type storedJob struct {
+	Job
+	IsFullExport bool   `json:"isFullExport"`
+	CallbackURL  string `json:"callbackURL,omitempty"`
+}
+
+// failureRecord is a line of the failure log. It holds the failures of a job from the given index on, which replace
+// the failures logged from that index before, so that a record logged again, e.g. after a failed write, or the
+// failures of a resumed job which dropped some of them, don't add up with those logged before.
+type failureRecord struct {
+	JobID    string    `json:"jobID"`
+	From     int       `json:"from,omitempty"`
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// FileJobStore keeps jobs in memory and persists them in the background, so that jobs survive service restarts
+// without slowing them down. A snapshot of the jobs without their failures is written to a JSON file, while the
+// failures are appended to a log next to it, so that writing a checkpoint doesn't grow with the failures of the job.
+// The log is compacted when it is loaded, and when it is persisted after it has grown to twice the failures of
+// the stored jobs, e.g. once jobs ended and were evicted.
+type FileJobStore struct {
+	*MemoryJobStore
+	fileLock sync.Mutex
+	path     string
+	failures *os.File
+	// persistedFailures is the number of failures of each job in the log
+	persistedFailures map[string]int
+	// loggedFailures is the number of failures in the log, including those replaced or evicted since
+	loggedFailures int
+	// mustCompact is set when a write to the log failed, which may have left a partial line at its end
+	mustCompact bool
+	dirty       chan struct{}
+	closing     chan struct{}
+	closed      chan struct{}
+	log         *logger.UPPLogger
+}
+
+func NewFileJobStore(path string, log *logger.UPPLogger) (*FileJobStore, error) {
+	s := &FileJobStore{
+		MemoryJobStore:    NewMemoryJobStore(),
+		path:              path,
+		persistedFailures: make(map[string]int),
+		dirty:             make(chan struct{}, 1),
+		closing:           make(chan struct{}),
+		closed:            make(chan struct{}),
+		log:               log,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	var records []failureRecord
+	for _, job := range s.List() {
+		if len(job.Failed) > 0 {
+			records = append(records, failureRecord{JobID: job.ID, Failures: job.Failed})
+			s.persistedFailures[job.ID] = len(job.Failed)
+		}
+	}
+	if err := s.compactFailures(records); err != nil {
+		return nil, err
+	}
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+
+	go s.persist()
+	return s, nil
+}
+
+func (s *FileJobStore) Save(job *Job) {
+	s.MemoryJobStore.Save(job)
+	s.markDirty()
+}
+
+func (s *FileJobStore) Delete(jobIDs ...string) {
+	s.MemoryJobStore.Delete(jobIDs...)
+	s.markDirty()
+}
+
+// Close writes the pending changes and stops persisting the jobs.
+func (s *FileJobStore) Close() error {
+	close(s.closing)
+	<-s.closed
+
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+	return s.failures.Close()
+}
+
+// markDirty asks for the jobs to be persisted. Changes made while the jobs are being written are persisted together.
+func (s *FileJobStore) markDirty() {
+	select {
+	case s.dirty <- struct{}{}:
+	default:
+	}
+}
+
+func (s *FileJobStore) persist() {
+	defer close(s.closed)
+	for {
+		select {
+		case <-s.dirty:
+			if err := s.flush(); err != nil {
+				s.log.WithError(err).Error("Failed to persist job store")
+			}
+		case <-s.closing:
+			if err := s.flush(); err != nil {
+				s.log.WithError(err).Error("Failed to persist job store")
+			}
+			return
+		}
+	}
+}
+
+// load reads the jobs persisted by a previous run. Jobs which were still in progress are marked as interrupted
+// keeping their last known progress.
+func (s *FileJobStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading job store file: %w", err)
+	}
+
+	var stored []storedJob
+	if err = json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("unmarshaling job store file: %w", err)
+	}
+
+	failures, err := s.loadFailures()
+	if err != nil {
+		return err
+	}
+
+	for _, sj := range stored {
+		job := NewJob(sj.Workers, sj.Throttle, sj.IsFullExport, s.log)
+		job.ID = sj.ID
+		job.Count = sj.Count
+		job.Progress = sj.Progress
+		job.Skipped = sj.Skipped
+		if sj.Counters != nil {
+			job.counters = *sj.Counters
+		}
+		// Files written before the failures were logged apart hold them in the snapshot
+		job.setFailures(append(sj.Failed, failures[sj.ID]...))
+		job.Status = sj.Status
+		job.Priority = sj.Priority
+		job.ErrorMessage = sj.ErrorMessage
+		job.Checkpoint = sj.Checkpoint
+		job.ParentJobID = sj.ParentJobID
+		job.ChildJobIDs = sj.ChildJobIDs
+		job.Filter = sj.Filter
+		job.DryRun = sj.DryRun
+		job.Summary = sj.Summary
+		job.Reconciliation = sj.Reconciliation
+		job.TransactionID = sj.TransactionID
+		job.StartTime = sj.StartTime
+		job.EndTime = sj.EndTime
+		job.callbackURL = sj.CallbackURL
+
+		if job.Status.isActive() {
+			s.log.WithField("jobID", job.ID).Warn("Marking job as interrupted")
+			job.Status = INTERRUPTED
+			job.ErrorMessage = interruptedJobMessage
+			// Documents in flight were lost with the service
+			job.counters.Dispatched -= job.counters.InFlight
+			job.counters.InFlight = 0
+		}
+		s.MemoryJobStore.Save(job)
+	}
+	return nil
+}
+
+func (s *FileJobStore) failuresPath() string {
+	return s.path + ".failures"
+}
+
+// loadFailures reads the failure log into the failures of each job.
+func (s *FileJobStore) loadFailures() (map[string][]Failure, error) {
+	failures := make(map[string][]Failure)
+	file, err := os.Open(s.failuresPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return failures, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening job failure file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var r failureRecord
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// A line cut short by a crash is skipped
+			s.log.WithError(err).Warn("Skipping unreadable job failures")
+			continue
+		}
+		logged := failures[r.JobID]
+		if r.From < len(logged) {
+			logged = logged[:r.From]
+		}
+		failures[r.JobID] = append(logged, r.Failures...)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading job failure file: %w", err)
+	}
+	return failures, nil
+}
+
+// compactFailures replaces the failure log with the given records and opens it for appending.
+func (s *FileJobStore) compactFailures(records []failureRecord) error {
+	logged := 0
+	for _, r := range records {
+		logged += len(r.Failures)
+	}
+
+	err := writeFile(s.failuresPath(), func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		for _, r := range records {
+			if err := encoder.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("compacting job failure file: %w", err)
+	}
+
+	file, err := os.OpenFile(s.failuresPath(), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening job failure file: %w", err)
+	}
+	if s.failures != nil {
+		_ = s.failures.Close()
+	}
+	s.failures = file
+	s.loggedFailures = logged
+	s.mustCompact = false
+	return nil
+}
+
+func (s *FileJobStore) flush() error {
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+
+	jobs := s.List()
+	stored := make([]storedJob, 0, len(jobs))
+	var records, all []failureRecord
+	persisted := make(map[string]int, len(jobs))
+	newFailures, liveFailures := 0, 0
+	for _, job := range jobs {
+		copied := job.Copy()
+		if r, ok := s.newFailures(copied); ok {
+			records = append(records, r)
+			newFailures += len(r.Failures)
+		}
+		if len(copied.Failed) > 0 {
+			all = append(all, failureRecord{JobID: job.ID, Failures: copied.Failed})
+		}
+		persisted[job.ID] = len(copied.Failed)
+		liveFailures += len(copied.Failed)
+		copied.Failed = nil
+		copied.FailureReasons = nil
+		stored = append(stored, storedJob{
+			Job:          copied,
+			IsFullExport: job.isFullExport,
+			CallbackURL:  job.CallbackURL(),
+		})
+	}
+
+	// The failures go first, so that the snapshot never refers to failures which aren't persisted
+	logged := s.loggedFailures + newFailures
+	if s.mustCompact || (logged >= minCompactedFailures && logged > 2*liveFailures) {
+		if err := s.compactFailures(all); err != nil {
+			return err
+		}
+	} else if err := s.appendFailures(records); err != nil {
+		return err
+	}
+	s.persistedFailures = persisted
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("marshaling jobs: %w", err)
+	}
+	return writeFile(s.path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// newFailures returns the failures of the job which aren't in the log yet. Failures are only ever appended to a job,
+// except when it is resumed, which drops some of them, and then all of its failures are logged again.
+func (s *FileJobStore) newFailures(job Job) (failureRecord, bool) {
+	persisted := s.persistedFailures[job.ID]
+	switch {
+	case len(job.Failed) < persisted:
+		return failureRecord{JobID: job.ID, Failures: job.Failed}, true
+	case len(job.Failed) > persisted:
+		return failureRecord{JobID: job.ID, From: persisted, Failures: job.Failed[persisted:]}, true
+	default:
+		return failureRecord{}, false
+	}
+}
+
+func (s *FileJobStore) appendFailures(records []failureRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := encoder.Encode(r); err != nil {
+			return fmt.Errorf("marshaling job failures: %w", err)
+		}
+	}
+	if _, err := s.failures.Write(buf.Bytes()); err != nil {
+		s.mustCompact = true
+		return fmt.Errorf("writing job failure file: %w", err)
+	}
+	for _, r := range records {
+		s.loggedFailures += len(r.Failures)
+	}
+	return nil
+}
+
+// writeFile replaces the file at the path with what write writes, through a temporary file
+// so that the file is never left half written.
+func writeFile(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	if err = write(writer); err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing file: %w", err)
+	}
+	return nil
+}
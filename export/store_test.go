@@ -0,0 +1,151 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileJobStore_MarksRunningJobsAsInterrupted(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	path := filepath.Join(t.TempDir(), "jobs.json")
+
+	store, err := NewFileJobStore(path, log)
+	require.NoError(t, err)
+
+	running := NewJob(1, 0, true, log)
+	running.Status = RUNNING
+	running.Count = 10
+	running.Progress = 4
+	running.Failed = []Failure{{UUID: "uuid1", Stage: "fetch", StatusCode: 404, Error: "not found"}}
+	running.counters = Counters{Dispatched: 4, Succeeded: 2, Failed: 1, InFlight: 1}
+	store.Save(running)
+
+	finished := NewJob(1, 0, false, log)
+	finished.TransactionID = "tid_test"
+	finished.SetCallbackURL("https://hooks.example.com/exports")
+	finished.Progress = 2
+	finished.Skipped = 1
+	finished.setStatus(FINISHED)
+	store.Save(finished)
+	require.NoError(t, store.Close())
+
+	reloaded, err := NewFileJobStore(path, log)
+	require.NoError(t, err)
+	defer reloaded.Close()
+	assert.Len(t, reloaded.List(), 2)
+
+	job, ok := reloaded.Get(running.ID)
+	require.True(t, ok)
+	assert.Equal(t, INTERRUPTED, job.Status)
+	assert.Equal(t, 10, job.Count)
+	assert.Equal(t, 4, job.Progress)
+	assert.Equal(t, []Failure{{UUID: "uuid1", Stage: "fetch", StatusCode: 404, Error: "not found"}}, job.Failed)
+	assert.Equal(t, interruptedJobMessage, job.ErrorMessage)
+	assert.True(t, job.isFullExport)
+	assert.Nil(t, job.EndTime)
+	assert.Equal(t, Counters{Dispatched: 3, Succeeded: 2, Failed: 1}, job.counters)
+	assert.Empty(t, job.Copy().Duration)
+
+	job, ok = reloaded.Get(finished.ID)
+	require.True(t, ok)
+	assert.Equal(t, FINISHED, job.Status)
+	assert.Equal(t, 2, job.Progress)
+	assert.Equal(t, 1, job.Skipped)
+	assert.Equal(t, "tid_test", job.TransactionID)
+	assert.Equal(t, "https://hooks.example.com/exports", job.CallbackURL())
+	assert.True(t, finished.StartTime.Equal(*job.StartTime))
+	assert.True(t, finished.EndTime.Equal(*job.EndTime))
+}
+
+func TestFileJobStore_MissingFile(t *testing.T) {
+	store, err := NewFileJobStore(filepath.Join(t.TempDir(), "jobs.json"), logger.NewUPPLogger("test", "PANIC"))
+
+	require.NoError(t, err)
+	assert.Empty(t, store.List())
+	assert.NoError(t, store.Close())
+}
+
+func TestFileJobStore_LogsFailuresApartFromTheSnapshot(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	path := filepath.Join(t.TempDir(), "jobs.json")
+
+	store, err := NewFileJobStore(path, log)
+	require.NoError(t, err)
+	job := NewJob(1, 0, true, log)
+	job.Status = INTERRUPTED
+	job.Checkpoint = &Checkpoint{UUID: "uuid2", Progress: 2}
+	job.Failed = []Failure{{UUID: "uuid1", Error: "failed"}}
+	store.Save(job)
+	require.NoError(t, store.flush())
+	job.lock.Lock()
+	job.Failed = append(job.Failed, Failure{UUID: "uuid3", Error: "failed"})
+	job.lock.Unlock()
+	store.Save(job)
+	require.NoError(t, store.flush())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "uuid1")
+
+	// Resuming drops the failures after the checkpoint
+	require.NoError(t, job.prepareResume(1, 0))
+	job.setStatus(INTERRUPTED)
+	require.NoError(t, store.Close())
+
+	reloaded, err := NewFileJobStore(path, log)
+	require.NoError(t, err)
+	defer reloaded.Close()
+	loaded, ok := reloaded.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, []Failure{{UUID: "uuid1", Error: "failed"}}, loaded.Failed)
+}
+
+func TestFileJobStore_LoadsFailuresLoggedAgainOnce(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"ID":"job-1","Status":"Finished"}]`), 0o644))
+	failures := `{"jobID":"job-1","failures":[{"UUID":"uuid1"},{"UUID":"uuid2"}]}
+{"jobID":"job-1","from":1,"failures":[{"UUID":"uuid2"},{"UUID":"uuid3"}]}
+{"jobID":"job-1","from":1,"failures":[{"UUID":"uuid2"},{"UUID":"uuid3"}]}
+`
+	require.NoError(t, os.WriteFile(path+".failures", []byte(failures), 0o644))
+
+	store, err := NewFileJobStore(path, log)
+	require.NoError(t, err)
+	defer store.Close()
+
+	job, ok := store.Get("job-1")
+	require.True(t, ok)
+	assert.Equal(t, []Failure{{UUID: "uuid1"}, {UUID: "uuid2"}, {UUID: "uuid3"}}, job.Failed)
+}
+
+func TestFileJobStore_CompactsTheFailureLogWhileInUse(t *testing.T) {
+	defer func(failures int) { minCompactedFailures = failures }(minCompactedFailures)
+	minCompactedFailures = 2
+	log := logger.NewUPPLogger("test", "PANIC")
+	path := filepath.Join(t.TempDir(), "jobs.json")
+
+	store, err := NewFileJobStore(path, log)
+	require.NoError(t, err)
+	defer store.Close()
+	evicted := NewJob(1, 0, true, log)
+	evicted.Failed = []Failure{{UUID: "uuid1"}, {UUID: "uuid2"}}
+	store.Save(evicted)
+	require.NoError(t, store.flush())
+
+	store.Delete(evicted.ID)
+	kept := NewJob(1, 0, true, log)
+	kept.Failed = []Failure{{UUID: "uuid3"}}
+	store.Save(kept)
+	require.NoError(t, store.flush())
+
+	data, err := os.ReadFile(path + ".failures")
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "uuid1", "the failures of the evicted job should be compacted away")
+	assert.Contains(t, string(data), "uuid3")
+}
@@ -21,6 +21,7 @@ require (
 	github.com/sethgrid/pester v0.0.0-20160429172022-8053687f9965
 	github.com/stretchr/testify v1.8.4
 	go.mongodb.org/mongo-driver v1.10.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -71,5 +72,4 @@ require (
 	golang.org/x/sync v0.3.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
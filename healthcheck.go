@@ -31,12 +31,14 @@ type exportStatusManager interface {
 	IsFullExportRunning() bool
 }
 
-func newHealthService(dbChecker, readChecker, writeChecker healthChecker, queueChecker queueChecker, statusManager exportStatusManager) *healthService {
+func newHealthService(dbChecker, readChecker, writeChecker, readBreaker, writeBreaker healthChecker, queueChecker queueChecker, statusManager exportStatusManager) *healthService {
 	dbCheck := newDBCheck(dbChecker)
 	readerCheck := newReadEndpointCheck(readChecker)
 	writerCheck := newS3WriterCheck(writeChecker)
 
-	healthChecks := []health.Check{dbCheck, readerCheck, writerCheck}
+	healthChecks := []health.Check{dbCheck, readerCheck, writerCheck, newReadCircuitBreakerCheck(readBreaker), newWriteCircuitBreakerCheck(writeBreaker)}
+	// Open circuit breakers don't fail the GTG: they reflect the state of a downstream service, which the
+	// connectivity checks already cover, and the paused exports continue by themselves once it recovers.
 	gtgChecks := []health.Check{dbCheck, readerCheck, writerCheck}
 
 	if !reflect.ValueOf(queueChecker).IsNil() {
@@ -91,6 +93,28 @@ func newS3WriterCheck(checker healthChecker) health.Check {
 	}
 }
 
+func newReadCircuitBreakerCheck(checker healthChecker) health.Check {
+	return health.Check{
+		Name:             "CircuitBreakerForApiPolicyComponent",
+		BusinessImpact:   "Content cannot be read for export, so published content reaches S3 late and running exports are held up.",
+		PanicGuide:       "https://runbooks.in.ft.com/content-exporter",
+		Severity:         2,
+		TechnicalSummary: "Content retrieval failed repeatedly and is suspended. Running export jobs and INCREMENTAL export are paused until the Api Policy Component recovers",
+		Checker:          checker.CheckHealth,
+	}
+}
+
+func newWriteCircuitBreakerCheck(checker healthChecker) health.Check {
+	return health.Check{
+		Name:             "CircuitBreakerForContentRWS3",
+		BusinessImpact:   "Exported content is not written to S3, and content deletions are not applied there until Content-RW-S3 recovers.",
+		PanicGuide:       "https://runbooks.in.ft.com/content-exporter",
+		Severity:         2,
+		TechnicalSummary: "Content uploads failed repeatedly and are suspended. Running export jobs and INCREMENTAL export are paused until Content-RW-S3 recovers",
+		Checker:          checker.CheckHealth,
+	}
+}
+
 func newKafkaConnectivityCheck(checker healthChecker) health.Check {
 	return health.Check{
 		Name:             "CheckConnectivityToKafka",
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
@@ -14,12 +15,15 @@ import (
 	"time"
 
 	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/content-exporter/deadletter"
 	"github.com/Financial-Times/content-exporter/ecsarchive"
 	"github.com/Financial-Times/content-exporter/export"
 	"github.com/Financial-Times/content-exporter/mongo"
 	"github.com/Financial-Times/content-exporter/policy"
 	"github.com/Financial-Times/content-exporter/queue"
+	"github.com/Financial-Times/content-exporter/schedule"
 	"github.com/Financial-Times/content-exporter/web"
+	"github.com/Financial-Times/content-exporter/webhook"
 	health "github.com/Financial-Times/go-fthealth/v1_1"
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/http-handlers-go/httphandlers"
@@ -145,6 +149,12 @@ func main() {
 		Desc:   "Health URL to S3 writer endpoint",
 		EnvVar: "S3_WRITER_HEALTH_URL",
 	})
+	s3WriterListURL := app.String(cli.StringOpt{
+		Name:   "s3WriterListURL",
+		Value:  "",
+		Desc:   "URL listing the objects of the S3 bucket for reconciliation jobs. If empty, the listing has to be sent with the request",
+		EnvVar: "S3_WRITER_LIST_URL",
+	})
 	xPolicyHeaderValues := app.String(cli.StringOpt{
 		Name:   "xPolicyHeaderValues",
 		Desc:   "Values for X-Policy header separated by comma, e.g. INCLUDE_RICH_CONTENT,EXPAND_IMAGES",
@@ -176,6 +186,30 @@ func main() {
 		Desc:   "Delay in seconds for notifications to being handled",
 		EnvVar: "DELAY_FOR_NOTIFICATION",
 	})
+	retryInitialInterval := app.Int(cli.IntOpt{
+		Name:   "retryInitialInterval",
+		Value:  500,
+		Desc:   "Time in milliseconds before a notification which failed because a downstream service was unavailable is handled again. It doubles after each attempt",
+		EnvVar: "RETRY_INITIAL_INTERVAL",
+	})
+	retryMaxInterval := app.Int(cli.IntOpt{
+		Name:   "retryMaxInterval",
+		Value:  30000,
+		Desc:   "Maximum time in milliseconds between two attempts to handle a notification",
+		EnvVar: "RETRY_MAX_INTERVAL",
+	})
+	retryJitter := app.Int(cli.IntOpt{
+		Name:   "retryJitter",
+		Value:  20,
+		Desc:   "Percentage by which the time between two attempts to handle a notification is randomly shortened or lengthened",
+		EnvVar: "RETRY_JITTER",
+	})
+	retryMaxAge := app.Int(cli.IntOpt{
+		Name:   "retryMaxAge",
+		Value:  300,
+		Desc:   "Time in seconds after its receipt during which a notification is retried. Notifications aren't retried if 0",
+		EnvVar: "RETRY_MAX_AGE",
+	})
 
 	rangeInHours := app.Int(cli.IntOpt{
 		Name:   "rangeInHours",
@@ -190,11 +224,119 @@ func main() {
 		Desc:   "Delay in milliseconds between content retrieval calls",
 		EnvVar: "CONTENT_RETRIEVAL_THROTTLE",
 	})
+	nrOfWorkers := app.Int(cli.IntOpt{
+		Name:   "nrOfWorkers",
+		Value:  20,
+		Desc:   "Default number of concurrent workers of an export job",
+		EnvVar: "NR_OF_WORKERS",
+	})
+	workerBudget := app.Int(cli.IntOpt{
+		Name:   "workerBudget",
+		Value:  0,
+		Desc:   "Maximum number of concurrent workers of all the running export jobs together. Higher priority jobs get the workers first. No limit if 0",
+		EnvVar: "WORKER_BUDGET",
+	})
+	maxRunningJobs := app.Int(cli.IntOpt{
+		Name:   "maxRunningJobs",
+		Value:  0,
+		Desc:   "Maximum number of export jobs running at the same time, further jobs are queued. No limit if 0",
+		EnvVar: "MAX_RUNNING_JOBS",
+	})
+	maxRequestRate := app.Int(cli.IntOpt{
+		Name:   "maxRequestRate",
+		Value:  0,
+		Desc:   "Maximum number of content retrieval and upload calls per second. The rate is lowered automatically when the downstream services slow down or fail. Rate limiting is disabled if 0",
+		EnvVar: "MAX_REQUEST_RATE",
+	})
+	minRequestRate := app.Int(cli.IntOpt{
+		Name:   "minRequestRate",
+		Value:  1,
+		Desc:   "Number of content retrieval and upload calls per second below which the rate is never lowered",
+		EnvVar: "MIN_REQUEST_RATE",
+	})
+	targetLatency := app.Int(cli.IntOpt{
+		Name:   "targetLatency",
+		Value:  1000,
+		Desc:   "Average response time in milliseconds of content retrieval and upload calls above which the request rate is lowered",
+		EnvVar: "TARGET_LATENCY",
+	})
+	circuitBreakerThreshold := app.Int(cli.IntOpt{
+		Name:   "circuitBreakerThreshold",
+		Value:  10,
+		Desc:   "Number of consecutive failed calls to the enriched content API or the S3 writer after which calls to it are suspended",
+		EnvVar: "CIRCUIT_BREAKER_THRESHOLD",
+	})
+	circuitBreakerTimeout := app.Int(cli.IntOpt{
+		Name:   "circuitBreakerTimeout",
+		Value:  30,
+		Desc:   "Time in seconds after which a suspended service is called again to check if it recovered",
+		EnvVar: "CIRCUIT_BREAKER_TIMEOUT",
+	})
 	contentOriginAllowlist := app.String(cli.StringOpt{
 		Name:   "contentOriginAllowlist",
 		Desc:   `The Content Origin allowlist for incoming notifications - i.e. ^http://.*-transformer-(pr|iw)-uk-.*\.svc\.ft\.com(:\d{2,5})?/content/[\w-]+.*$`,
 		EnvVar: "CONTENT_ORIGIN_ALLOWLIST",
 	})
+	skipUnchanged := app.Bool(cli.BoolOpt{
+		Name:   "skipUnchanged",
+		Value:  false,
+		Desc:   "Skip uploading content which is unchanged since its last upload",
+		EnvVar: "SKIP_UNCHANGED",
+	})
+	hashIndexPath := app.String(cli.StringOpt{
+		Name:   "hashIndexPath",
+		Value:  "",
+		Desc:   "Path to a file where the hashes of the uploaded content are persisted when skipUnchanged is set. Hashes are kept only in memory if empty",
+		EnvVar: "HASH_INDEX_PATH",
+	})
+	deadLetterPath := app.String(cli.StringOpt{
+		Name:   "deadLetterPath",
+		Value:  "",
+		Desc:   "Path to a file where the notifications which the incremental export failed to handle are persisted. Dead letters are kept only in memory if empty",
+		EnvVar: "DEAD_LETTER_PATH",
+	})
+	webhookSecret := app.String(cli.StringOpt{
+		Name:   "webhookSecret",
+		Value:  "",
+		Desc:   "Secret signing the notifications posted to the callback URLs of exports and archives. Callbacks are disabled if empty",
+		EnvVar: "WEBHOOK_SECRET",
+	})
+	webhookAllowlist := app.String(cli.StringOpt{
+		Name:   "webhookAllowlist",
+		Value:  "",
+		Desc:   `Regular expression which callback URLs must match, i.e. ^https://hooks\.example\.com/. Callbacks are disabled if empty`,
+		EnvVar: "WEBHOOK_ALLOWLIST",
+	})
+	schedules := app.String(cli.StringOpt{
+		Name:   "schedules",
+		Value:  "",
+		Desc:   "Recurring exports as a YAML or JSON list of schedules with a name, a cron expression and fullExport, filter or since",
+		EnvVar: "SCHEDULES",
+	})
+	schedulePath := app.String(cli.StringOpt{
+		Name:   "schedulePath",
+		Value:  "",
+		Desc:   "Path to a YAML file with the schedules of recurring exports. Used instead of schedules if set",
+		EnvVar: "SCHEDULE_PATH",
+	})
+	jobRetention := app.Int(cli.IntOpt{
+		Name:   "jobRetention",
+		Value:  168,
+		Desc:   "Number of hours for which ended export jobs are kept. Jobs are kept forever if 0",
+		EnvVar: "JOB_RETENTION",
+	})
+	maxEndedJobs := app.Int(cli.IntOpt{
+		Name:   "maxEndedJobs",
+		Value:  1000,
+		Desc:   "Maximum number of ended export jobs to keep, the oldest ones are evicted first. No limit if 0",
+		EnvVar: "MAX_ENDED_JOBS",
+	})
+	jobStorePath := app.String(cli.StringOpt{
+		Name:   "jobStorePath",
+		Value:  "",
+		Desc:   "Path to a file where export jobs are persisted, their failures going to the same path with a .failures suffix. Jobs are kept only in memory if empty",
+		EnvVar: "JOB_STORE_PATH",
+	})
 	logLevel := app.String(cli.StringOpt{
 		Name:   "logLevel",
 		Value:  "INFO",
@@ -210,7 +352,7 @@ func main() {
 	maxGoRoutines := app.Int(cli.IntOpt{
 		Name:   "maxGoRoutines",
 		Value:  100,
-		Desc:   "Maximum goroutines to allocate for kafka message handling",
+		Desc:   "Maximum goroutines to allocate for kafka message handling. The notifications for the same content are handled in order by a single goroutine",
 		EnvVar: "MAX_GO_ROUTINES",
 	})
 	kafkaClusterArn := app.String(cli.StringOpt{
@@ -288,12 +430,49 @@ func main() {
 		healthClient := newHealthClient()
 
 		fetcher := content.NewEnrichedContentFetcher(apiClient, healthClient, *enrichedContentAPIURL, *enrichedContentHealthURL, *xPolicyHeaderValues, *authorization)
-		uploader := content.NewS3Updater(apiClient, healthClient, *s3WriterAPIURL, *s3WriterGenericAPIURL, *s3PresignerAPIURL, *s3WriterHealthURL)
+		uploader := content.NewS3Updater(apiClient, healthClient, newListClient(), *s3WriterAPIURL, *s3WriterGenericAPIURL, *s3PresignerAPIURL, *s3WriterHealthURL, *s3WriterListURL)
 
 		ecsArchive := ecsarchive.NewECSAarchive(ecsDB, uploader, 1)
 
-		exporter := content.NewExporter(fetcher, uploader)
-		fullExporter := export.NewFullExporter(20, exporter)
+		var limiter *content.AdaptiveLimiter
+		if *maxRequestRate > 0 {
+			limiter = content.NewAdaptiveLimiter(content.LimiterConfig{
+				MinRate:       float64(*minRequestRate),
+				MaxRate:       float64(*maxRequestRate),
+				TargetLatency: time.Duration(*targetLatency) * time.Millisecond,
+			})
+		}
+		fetchBreaker := content.NewCircuitBreaker("enriched content", *circuitBreakerThreshold, time.Duration(*circuitBreakerTimeout)*time.Second)
+		uploadBreaker := content.NewCircuitBreaker("S3 writer", *circuitBreakerThreshold, time.Duration(*circuitBreakerTimeout)*time.Second)
+		var hashIndex content.HashIndex
+		if *skipUnchanged {
+			hashIndex = content.NewMemoryHashIndex()
+			if *hashIndexPath != "" {
+				fileHashIndex, err := content.NewFileHashIndex(*hashIndexPath, log)
+				if err != nil {
+					log.WithError(err).Fatal("Failed to load hash index")
+				}
+				defer fileHashIndex.Close()
+				hashIndex = fileHashIndex
+			}
+		}
+		exporter := content.NewExporter(fetcher, uploader, limiter, fetchBreaker, uploadBreaker, hashIndex)
+		var jobStore export.JobStore = export.NewMemoryJobStore()
+		if *jobStorePath != "" {
+			fileJobStore, err := export.NewFileJobStore(*jobStorePath, log)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to load job store")
+			}
+			defer fileJobStore.Close()
+			jobStore = fileJobStore
+		}
+		fullExporter := export.NewFullExporter(*nrOfWorkers, exporter, jobStore, export.Retention{
+			MaxAge:  time.Duration(*jobRetention) * time.Hour,
+			MaxJobs: *maxEndedJobs,
+		}, export.QueueLimits{
+			MaxRunningJobs: *maxRunningJobs,
+			WorkerBudget:   *workerBudget,
+		})
 		locker := export.NewLocker()
 		var kafkaListener *queue.Listener
 
@@ -302,6 +481,16 @@ func main() {
 		}
 
 		if *isIncExportEnabled {
+			var deadLetters deadletter.Store = deadletter.NewMemoryStore()
+			if *deadLetterPath != "" {
+				fileDeadLetters, err := deadletter.NewFileStore(*deadLetterPath, log)
+				if err != nil {
+					log.WithError(err).Fatal("Failed to load dead letters")
+				}
+				defer fileDeadLetters.Close()
+				deadLetters = fileDeadLetters
+			}
+
 			kafkaListener, err = prepareIncrementalExport(
 				log,
 				consumerAddrs,
@@ -311,11 +500,18 @@ func main() {
 				*allowedContentTypes,
 				exporter,
 				delayForNotification,
+				queue.RetryConfig{
+					InitialInterval: time.Duration(*retryInitialInterval) * time.Millisecond,
+					MaxInterval:     time.Duration(*retryMaxInterval) * time.Millisecond,
+					Jitter:          float64(*retryJitter) / 100,
+					MaxAge:          time.Duration(*retryMaxAge) * time.Second,
+				},
 				locker,
 				maxGoRoutines,
 				*kafkaClusterArn,
 				*opaURL,
 				*opaPolicyPath,
+				deadLetters,
 			)
 
 			if err != nil {
@@ -328,10 +524,28 @@ func main() {
 			log.Warn("INCREMENTAL export is not enabled")
 		}
 
-		hService := newHealthService(mongoClient, fetcher, uploader, kafkaListener, fullExporter)
+		hService := newHealthService(mongoClient, fetcher, uploader, fetchBreaker, uploadBreaker, kafkaListener, fullExporter)
 		inquirer := mongo.NewInquirer(mongoClient, log)
-		requestHandler := web.NewRequestHandler(fullExporter, inquirer, locker, *isIncExportEnabled, *contentRetrievalThrottle, log, ecsArchive, *rangeInHours)
-		go serveEndpoints(*appSystemCode, *appName, *port, log, requestHandler, hService)
+		var callbackAllowlist *regexp.Regexp
+		if *webhookAllowlist != "" {
+			callbackAllowlist = regexp.MustCompile(*webhookAllowlist)
+		}
+		notifier := webhook.NewNotifier(newWebhookClient(), *webhookSecret, callbackAllowlist)
+		requestHandler := web.NewRequestHandler(fullExporter, inquirer, locker, *isIncExportEnabled, *contentRetrievalThrottle, log, ecsArchive, *rangeInHours, uploader, notifier)
+		scheduler, err := prepareScheduler(*schedules, *schedulePath, requestHandler, log)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load export schedules")
+		}
+		scheduler.Start()
+		defer scheduler.Stop()
+		scheduleHandler := web.NewScheduleHandler(scheduler, log)
+
+		var deadLetterHandler *web.DeadLetterHandler
+		if kafkaListener != nil {
+			deadLetterHandler = web.NewDeadLetterHandler(kafkaListener, log)
+		}
+
+		go serveEndpoints(*appSystemCode, *appName, *port, log, requestHandler, scheduleHandler, deadLetterHandler, hService)
 
 		log.
 			WithField("event", "service_started").
@@ -367,6 +581,30 @@ func newAPIClient() *pester.Client {
 	return client
 }
 
+// newListClient creates the client reading the listing of the S3 bucket. It has no overall timeout, as the listing
+// of the whole bucket takes long to read, but the S3 writer has to start responding in time.
+func newListClient() *http.Client {
+	tr := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ResponseHeaderTimeout: 5 * time.Minute,
+	}
+
+	return &http.Client{
+		Transport: tr,
+	}
+}
+
+// newWebhookClient creates the client posting the webhook notifications. It doesn't retry, as the notifier
+// retries the notifications itself.
+func newWebhookClient() *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+	}
+}
+
 func newHealthClient() *http.Client {
 	tr := &http.Transport{
 		MaxIdleConnsPerHost: 10,
@@ -388,11 +626,13 @@ func prepareIncrementalExport(
 	allowedContentTypes []string,
 	exporter *content.Exporter,
 	delayForNotification *int,
+	retry queue.RetryConfig,
 	locker *export.Locker,
 	maxGoRoutines *int,
 	kafkaClusterArn string,
 	opaURL string,
 	opaPolicyPath string,
+	deadLetters deadletter.Store,
 ) (*queue.Listener, error) {
 	config := kafka.ConsumerConfig{
 		ClusterArn:              &kafkaClusterArn,
@@ -409,7 +649,7 @@ func prepareIncrementalExport(
 
 	contentOriginAllowListRegex := regexp.MustCompile(*contentOriginAllowlist)
 
-	messageHandler := queue.NewNotificationHandler(exporter, *delayForNotification)
+	messageHandler := queue.NewNotificationHandler(exporter, *delayForNotification, retry)
 	messageMapper := queue.NewMessageMapper(contentOriginAllowListRegex, allowedContentTypes)
 
 	paths := map[string]string{
@@ -419,12 +659,33 @@ func prepareIncrementalExport(
 	opaClient := opa.NewOpenPolicyAgentClient(opaURL, paths, opa.WithLogger(log))
 	opaAgent := policy.NewOpenPolicyAgent(opaClient, log)
 
-	listener := queue.NewListener(messageConsumer, messageHandler, messageMapper, opaAgent, locker, *maxGoRoutines, log)
+	listener := queue.NewListener(messageConsumer, messageHandler, messageMapper, opaAgent, deadLetters, locker, *maxGoRoutines, log)
 
 	return listener, nil
 }
 
-func serveEndpoints(appSystemCode, appName, port string, log *logger.UPPLogger, requestHandler *web.RequestHandler, healthService *healthService) {
+func prepareScheduler(schedules, schedulePath string, requestHandler *web.RequestHandler, log *logger.UPPLogger) (*schedule.Scheduler, error) {
+	data := []byte(schedules)
+	if schedulePath != "" {
+		var err error
+		data, err = os.ReadFile(schedulePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading schedule file: %w", err)
+		}
+	}
+
+	var parsed []schedule.Schedule
+	if len(bytes.TrimSpace(data)) > 0 {
+		var err error
+		parsed, err = schedule.ParseSchedules(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return schedule.NewScheduler(parsed, requestHandler, log)
+}
+
+func serveEndpoints(appSystemCode, appName, port string, log *logger.UPPLogger, requestHandler *web.RequestHandler, scheduleHandler *web.ScheduleHandler, deadLetterHandler *web.DeadLetterHandler, healthService *healthService) {
 	serveMux := http.NewServeMux()
 
 	hc := health.HealthCheck{SystemCode: appSystemCode, Name: appName, Description: appDescription, Checks: healthService.healthChecks}
@@ -434,9 +695,29 @@ func serveEndpoints(appSystemCode, appName, port string, log *logger.UPPLogger,
 
 	servicesRouter := mux.NewRouter()
 	servicesRouter.HandleFunc("/export", requestHandler.Export).Methods(http.MethodPost)
+	servicesRouter.HandleFunc("/reconcile", requestHandler.Reconcile).Methods(http.MethodPost)
 	servicesRouter.HandleFunc("/jobs/{jobID}", requestHandler.GetJob).Methods(http.MethodGet)
-	servicesRouter.HandleFunc("/jobs", requestHandler.GetRunningJobs).Methods(http.MethodGet)
+	servicesRouter.HandleFunc("/jobs/{jobID}", requestHandler.UpdateJob).Methods(http.MethodPatch)
+	servicesRouter.HandleFunc("/jobs/{jobID}", requestHandler.CancelJob).Methods(http.MethodDelete)
+	servicesRouter.HandleFunc("/jobs/{jobID}/pause", requestHandler.PauseJob).Methods(http.MethodPost)
+	servicesRouter.HandleFunc("/jobs/{jobID}/resume", requestHandler.ResumeJob).Methods(http.MethodPost)
+	servicesRouter.HandleFunc("/jobs/{jobID}/retry", requestHandler.RetryJob).Methods(http.MethodPost)
+	servicesRouter.HandleFunc("/jobs/{jobID}/uuids", requestHandler.GetJobUUIDs).Methods(http.MethodGet)
+	servicesRouter.HandleFunc("/jobs/{jobID}/reconciliation/{discrepancy:missing|stale|orphaned}", requestHandler.GetReconciliation).Methods(http.MethodGet)
+	servicesRouter.HandleFunc("/jobs", requestHandler.GetJobs).Methods(http.MethodGet)
+	servicesRouter.HandleFunc("/schedules", scheduleHandler.GetSchedules).Methods(http.MethodGet)
 	servicesRouter.HandleFunc("/ecsarchive/{startDate}/{endDate}", requestHandler.GenerateArticlesZipS3).Methods(http.MethodGet)
+	// Dead letters come from the incremental export, so there are none without it
+	if deadLetterHandler != nil {
+		servicesRouter.HandleFunc("/deadletters", deadLetterHandler.GetDeadLetters).Methods(http.MethodGet)
+		servicesRouter.HandleFunc("/deadletters/replay", deadLetterHandler.ReplayDeadLetters).Methods(http.MethodPost)
+	}
+
+	// Job events are streamed for as long as the job runs, so they bypass the request logging
+	// which would hide the write deadline of the stream
+	eventsRouter := mux.NewRouter()
+	eventsRouter.HandleFunc("/jobs/{jobID}/events", requestHandler.GetJobEvents).Methods(http.MethodGet)
+	serveMux.Handle("/jobs/{jobID}/events", eventsRouter)
 
 	var monitoringRouter http.Handler = servicesRouter
 	monitoringRouter = httphandlers.TransactionAwareRequestLoggingHandler(log, monitoringRouter)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Financial-Times/content-exporter/content"
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/upp-go-sdk/pkg/mongodb"
 	"go.mongodb.org/mongo-driver/bson"
@@ -49,28 +50,36 @@ func NewClient(
 	}, nil
 }
 
-func (c *Client) findContent(ctx context.Context, candidates []string) (cursor, int, error) {
+func (c *Client) findContent(ctx context.Context, candidates []string, filter *content.Filter, after string) (cursor, error) {
 	collection := c.client.Database(c.database).Collection(c.collection)
 
-	query, projection := findUUIDsQueryElements(candidates, c.allowedContentTypes, c.allowedPublishUUIDs)
+	query, projection := findUUIDsQueryElements(candidates, filter, after, c.allowedContentTypes, c.allowedPublishUUIDs)
 	queryStr, _ := json.Marshal(query)
 	c.log.WithField("query", string(queryStr)).Debug("Generated query")
 
+	// Sorting by UUID gives a stable order, so that an interrupted export can be resumed after its last checkpoint,
+	// and a cursor which timed out while its export job was paused can be re-opened after its last document.
 	opts := options.Find().
 		SetProjection(projection).
+		SetSort(bson.D{{Key: "uuid", Value: 1}}).
 		SetBatchSize(100)
 
 	cur, err := collection.Find(ctx, query, opts)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error finding documents: %w", err)
+		return nil, fmt.Errorf("error finding documents: %w", err)
 	}
+	return cur, nil
+}
+
+func (c *Client) countContent(ctx context.Context, candidates []string, filter *content.Filter, after string) (int, error) {
+	collection := c.client.Database(c.database).Collection(c.collection)
 
+	query, _ := findUUIDsQueryElements(candidates, filter, after, c.allowedContentTypes, c.allowedPublishUUIDs)
 	count, err := collection.CountDocuments(ctx, query)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error counting documents: %w", err)
+		return 0, fmt.Errorf("error counting documents: %w", err)
 	}
-
-	return cur, int(count), nil
+	return int(count), nil
 }
 
 func (c *Client) CheckHealth() (string, error) {
@@ -92,7 +101,7 @@ func (c *Client) Close(ctx context.Context) error {
 	return c.client.Disconnect(ctx)
 }
 
-func findUUIDsQueryElements(candidates, allowedContentTypes, allowedPublishUUIDs []string) (bson.M, bson.M) {
+func findUUIDsQueryElements(candidates []string, filter *content.Filter, after string, allowedContentTypes, allowedPublishUUIDs []string) (bson.M, bson.M) {
 	//Mongo expects empty arrays not nil
 	if allowedContentTypes == nil {
 		allowedContentTypes = []string{}
@@ -122,12 +131,58 @@ func findUUIDsQueryElements(candidates, allowedContentTypes, allowedPublishUUIDs
 	if len(candidates) != 0 {
 		andQuery = append(andQuery, bson.M{"uuid": bson.M{"$in": candidates}})
 	}
+	if filter != nil {
+		andQuery = append(andQuery, filterQueryElements(filter)...)
+	}
+	if after != "" {
+		andQuery = append(andQuery, bson.M{"uuid": bson.M{"$gt": after}})
+	}
 
 	fieldsProjection := bson.M{
 		"uuid":               1,
 		"firstPublishedDate": 1,
 		"publishedDate":      1,
+		"type":               1,
+		"publication":        1,
+		"lastModified":       1,
 	}
 
 	return bson.M{"$and": andQuery}, fieldsProjection
 }
+
+// filterQueryElements narrows down the exportable documents. The criteria can only restrict the allowed
+// content types and publications further, as they are combined with the rest of the query.
+func filterQueryElements(filter *content.Filter) []bson.M {
+	var elements []bson.M
+
+	// Dates are stored as ISO 8601 strings, so comparing them with the day prefixes works
+	published := bson.M{}
+	if filter.PublishedFrom != "" {
+		published["$gte"] = filter.PublishedFrom
+	}
+	if before := filter.PublishedBefore(); before != "" {
+		published["$lt"] = before
+	}
+	if len(published) != 0 {
+		elements = append(elements, bson.M{"firstPublishedDate": published})
+	}
+
+	if len(filter.ContentTypes) != 0 {
+		elements = append(elements, bson.M{"type": bson.M{"$in": filter.ContentTypes}})
+	}
+	if len(filter.Publications) != 0 {
+		elements = append(elements, bson.M{"publication": bson.M{"$in": filter.Publications}})
+	}
+	if filter.EditorialDesk != "" {
+		elements = append(elements, bson.M{"editorialDesk": filter.EditorialDesk})
+	}
+	if since := filter.ModifiedSinceUTC(); since != "" {
+		// Content which has never been modified since its publication has no lastModified timestamp
+		elements = append(elements, bson.M{"$or": []bson.M{
+			{"lastModified": bson.M{"$gte": since}},
+			{"lastModified": bson.M{"$exists": false}, "publishedDate": bson.M{"$gte": since}},
+		}})
+	}
+
+	return elements
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Financial-Times/content-exporter/content"
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,7 +18,7 @@ import (
 )
 
 // integration tests runs with local mongo instance
-func mockNewClient(ctx context.Context, uri, database, collection string, allowedContentTypes []string, log *logger.UPPLogger) (*Client, error) {
+func mockNewClient(ctx context.Context, uri, database, collection string, allowedContentTypes, allowedPublishUUIDs []string, log *logger.UPPLogger) (*Client, error) {
 	uri = fmt.Sprintf("mongodb://%s", uri)
 	opts := options.Client().ApplyURI(uri)
 
@@ -31,6 +32,7 @@ func mockNewClient(ctx context.Context, uri, database, collection string, allowe
 		database:            database,
 		collection:          collection,
 		allowedContentTypes: allowedContentTypes,
+		allowedPublishUUIDs: allowedPublishUUIDs,
 		log:                 log,
 	}, nil
 }
@@ -53,7 +55,7 @@ func setupConnection(t *testing.T) (*Client, func()) {
 
 	log := logger.NewUPPLogger("test", "PANIC")
 
-	client, err := mockNewClient(ctx, mongoURL, database, collection, []string{"Article"}, log)
+	client, err := mockNewClient(ctx, mongoURL, database, collection, []string{"Article"}, []string{"pub-1", "pub-2"}, log)
 	require.NoError(t, err)
 
 	return client, func() {
@@ -108,25 +110,32 @@ func cleanupTestContent(ctx context.Context, t *testing.T, client *Client, testU
 
 func TestMongo_FindUUIDs(t *testing.T) {
 	emptyResult := make([]string, 0)
-	type content struct {
-		uuid             string
-		cType            string
-		canBeDistributed *string
-		body             *string
-		bodyXML          *string
+	type document struct {
+		uuid               string
+		cType              string
+		canBeDistributed   *string
+		body               *string
+		bodyXML            *string
+		firstPublishedDate string
+		publication        []string
+		editorialDesk      string
+		publishedDate      string
+		lastModified       string
 	}
 	stringAsPtr := func(s string) *string {
 		return &s
 	}
 	tests := []struct {
 		name                string
-		existingContent     []content
+		existingContent     []document
 		candidates          []string
+		filter              *content.Filter
+		after               string
 		expectedResultUUIDs []string
 	}{
 		{
 			name: "Test that content with irrelevant content type will not be fetched",
-			existingContent: []content{
+			existingContent: []document{
 				{
 					uuid:    "a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e",
 					cType:   "LiveBlog",
@@ -137,7 +146,7 @@ func TestMongo_FindUUIDs(t *testing.T) {
 		},
 		{
 			name: "Test that content with relevant content type and no body or bodyXML will not be fetched",
-			existingContent: []content{
+			existingContent: []document{
 				{
 					uuid:  "2c1d77f1-c087-495b-bcd7-0680844d622b",
 					cType: "Article",
@@ -147,7 +156,7 @@ func TestMongo_FindUUIDs(t *testing.T) {
 		},
 		{
 			name: "Test that content with relevant content type, existing bodyXML and distribution flag set to no will not be fetched",
-			existingContent: []content{
+			existingContent: []document{
 				{
 					uuid:             "fd1f2c02-711f-4cc2-941e-0f03a62b8406",
 					cType:            "Article",
@@ -159,7 +168,7 @@ func TestMongo_FindUUIDs(t *testing.T) {
 		},
 		{
 			name: "Test that content with relevant content type, existing bodyXML and valid distribution flag will be fetched",
-			existingContent: []content{
+			existingContent: []document{
 				{
 					uuid:             "test-uuid-1",
 					cType:            "Article",
@@ -171,7 +180,7 @@ func TestMongo_FindUUIDs(t *testing.T) {
 		},
 		{
 			name: "Test that content with relevant content type, existing body and valid distribution flag will be fetched",
-			existingContent: []content{
+			existingContent: []document{
 				{
 					uuid:             "test-uuid-2",
 					cType:            "Article",
@@ -183,7 +192,7 @@ func TestMongo_FindUUIDs(t *testing.T) {
 		},
 		{
 			name: "Test that content with relevant content type, existing body and non-existing distribution flag will be fetched",
-			existingContent: []content{
+			existingContent: []document{
 				{
 					uuid:  "test-uuid-3",
 					cType: "Article",
@@ -194,7 +203,7 @@ func TestMongo_FindUUIDs(t *testing.T) {
 		},
 		{
 			name: "Test that valid content will be fetched when it is among candidates",
-			existingContent: []content{
+			existingContent: []document{
 				{
 					uuid:             "test-uuid-4",
 					cType:            "Article",
@@ -223,6 +232,115 @@ func TestMongo_FindUUIDs(t *testing.T) {
 			candidates:          []string{"test-uuid-4", "test-uuid-6"},
 			expectedResultUUIDs: []string{"test-uuid-4", "test-uuid-6"},
 		},
+		{
+			name: "Test that only content after the checkpoint will be fetched when resuming",
+			existingContent: []document{
+				{
+					uuid:    "test-uuid-8",
+					cType:   "Article",
+					bodyXML: stringAsPtr("<body> Simple body </body>"),
+				},
+				{
+					uuid:    "test-uuid-9",
+					cType:   "Article",
+					bodyXML: stringAsPtr("<body> Simple body </body>"),
+				},
+			},
+			after:               "test-uuid-8",
+			expectedResultUUIDs: []string{"test-uuid-9"},
+		},
+		{
+			name: "Test that only content published within the filter date range will be fetched",
+			existingContent: []document{
+				{
+					uuid:               "test-uuid-10",
+					cType:              "Article",
+					bodyXML:            stringAsPtr("<body> Simple body </body>"),
+					firstPublishedDate: "2024-02-29T23:59:59.000Z",
+				},
+				{
+					uuid:               "test-uuid-11",
+					cType:              "Article",
+					bodyXML:            stringAsPtr("<body> Simple body </body>"),
+					firstPublishedDate: "2024-03-01T00:00:00.000Z",
+				},
+				{
+					uuid:               "test-uuid-12",
+					cType:              "Article",
+					bodyXML:            stringAsPtr("<body> Simple body </body>"),
+					firstPublishedDate: "2024-03-31T23:59:59.000Z",
+				},
+				{
+					uuid:               "test-uuid-13",
+					cType:              "Article",
+					bodyXML:            stringAsPtr("<body> Simple body </body>"),
+					firstPublishedDate: "2024-04-01T00:00:00.000Z",
+				},
+			},
+			filter:              &content.Filter{PublishedFrom: "2024-03-01", PublishedTo: "2024-03-31"},
+			expectedResultUUIDs: []string{"test-uuid-11", "test-uuid-12"},
+		},
+		{
+			name: "Test that only content matching the filter content types, publications and editorial desk will be fetched",
+			existingContent: []document{
+				{
+					uuid:          "test-uuid-14",
+					cType:         "Article",
+					bodyXML:       stringAsPtr("<body> Simple body </body>"),
+					publication:   []string{"pub-1"},
+					editorialDesk: "/FT/Newsdesk",
+				},
+				{
+					uuid:          "test-uuid-15",
+					cType:         "Article",
+					bodyXML:       stringAsPtr("<body> Simple body </body>"),
+					publication:   []string{"pub-2"},
+					editorialDesk: "/FT/Newsdesk",
+				},
+				{
+					uuid:          "test-uuid-16",
+					cType:         "Article",
+					bodyXML:       stringAsPtr("<body> Simple body </body>"),
+					publication:   []string{"pub-1"},
+					editorialDesk: "/FT/Sport",
+				},
+			},
+			filter:              &content.Filter{ContentTypes: []string{"Article"}, Publications: []string{"pub-1"}, EditorialDesk: "/FT/Newsdesk"},
+			expectedResultUUIDs: []string{"test-uuid-14"},
+		},
+		{
+			name: "Test that only content modified since the given timestamp will be fetched",
+			existingContent: []document{
+				{
+					uuid:          "test-uuid-17",
+					cType:         "Article",
+					bodyXML:       stringAsPtr("<body> Simple body </body>"),
+					publishedDate: "2024-01-01T09:00:00.000Z",
+					lastModified:  "2024-03-01T10:00:00.123Z",
+				},
+				{
+					uuid:          "test-uuid-18",
+					cType:         "Article",
+					bodyXML:       stringAsPtr("<body> Simple body </body>"),
+					publishedDate: "2024-01-01T09:00:00.000Z",
+					lastModified:  "2024-02-29T23:59:59.999Z",
+				},
+				{
+					uuid:          "test-uuid-19",
+					cType:         "Article",
+					bodyXML:       stringAsPtr("<body> Simple body </body>"),
+					publishedDate: "2024-03-02T08:00:00.000Z",
+				},
+				{
+					uuid:          "test-uuid-20",
+					cType:         "Article",
+					bodyXML:       stringAsPtr("<body> Simple body </body>"),
+					publishedDate: "2024-02-01T08:00:00.000Z",
+				},
+			},
+			filter:              &content.Filter{ModifiedSince: "2024-03-01T11:00:00+01:00"},
+			expectedResultUUIDs: []string{"test-uuid-17", "test-uuid-19"},
+		},
 	}
 	client, teardown := setupConnection(t)
 	defer teardown()
@@ -232,15 +350,30 @@ func TestMongo_FindUUIDs(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			uuids := make([]string, 0)
-			for _, content := range test.existingContent {
-				uuids = append(uuids, content.uuid)
+			for _, doc := range test.existingContent {
+				uuids = append(uuids, doc.uuid)
 				toInsert := make(map[string]interface{})
-				toInsert["uuid"] = content.uuid
-				toInsert["type"] = content.cType
-				toInsert["body"] = content.body
-				toInsert["bodyXML"] = content.bodyXML
-				if content.canBeDistributed != nil {
-					toInsert["canBeDistributed"] = content.canBeDistributed
+				toInsert["uuid"] = doc.uuid
+				toInsert["type"] = doc.cType
+				toInsert["body"] = doc.body
+				toInsert["bodyXML"] = doc.bodyXML
+				if doc.canBeDistributed != nil {
+					toInsert["canBeDistributed"] = doc.canBeDistributed
+				}
+				if doc.firstPublishedDate != "" {
+					toInsert["firstPublishedDate"] = doc.firstPublishedDate
+				}
+				if doc.publication != nil {
+					toInsert["publication"] = doc.publication
+				}
+				if doc.editorialDesk != "" {
+					toInsert["editorialDesk"] = doc.editorialDesk
+				}
+				if doc.publishedDate != "" {
+					toInsert["publishedDate"] = doc.publishedDate
+				}
+				if doc.lastModified != "" {
+					toInsert["lastModified"] = doc.lastModified
 				}
 				insertTestContent(ctx, t, client, toInsert)
 			}
@@ -249,7 +382,9 @@ func TestMongo_FindUUIDs(t *testing.T) {
 			readCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 			defer cancel()
 
-			iter, count, err := client.findContent(readCtx, test.candidates)
+			count, err := client.countContent(readCtx, test.candidates, test.filter, test.after)
+			require.NoError(t, err)
+			iter, err := client.findContent(readCtx, test.candidates, test.filter, test.after)
 			require.NoError(t, err)
 
 			defer func() {
@@ -269,3 +404,31 @@ func TestMongo_FindUUIDs(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterQueryElements(t *testing.T) {
+	filter := &content.Filter{
+		PublishedFrom: "2024-03-01",
+		PublishedTo:   "2024-03-31",
+		ContentTypes:  []string{"Article"},
+		Publications:  []string{"pub-1"},
+		EditorialDesk: "/FT/Newsdesk",
+	}
+
+	assert.Equal(t, []bson.M{
+		{"firstPublishedDate": bson.M{"$gte": "2024-03-01", "$lt": "2024-04-01"}},
+		{"type": bson.M{"$in": []string{"Article"}}},
+		{"publication": bson.M{"$in": []string{"pub-1"}}},
+		{"editorialDesk": "/FT/Newsdesk"},
+	}, filterQueryElements(filter))
+
+	assert.Equal(t, []bson.M{
+		{"firstPublishedDate": bson.M{"$lt": "2024-04-01"}},
+	}, filterQueryElements(&content.Filter{PublishedTo: "2024-03-31"}))
+
+	assert.Equal(t, []bson.M{
+		{"$or": []bson.M{
+			{"lastModified": bson.M{"$gte": "2024-03-01T09:30:00"}},
+			{"lastModified": bson.M{"$exists": false}, "publishedDate": bson.M{"$gte": "2024-03-01T09:30:00"}},
+		}},
+	}, filterQueryElements(&content.Filter{ModifiedSince: "2024-03-01T10:30:00.250+01:00"}))
+}
@@ -3,14 +3,28 @@ package mongo
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/Financial-Times/content-exporter/content"
 	"github.com/Financial-Times/go-logger/v2"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	targetedQueryTimeout = 30 * time.Second
+	fullQueryTimeout     = 120 * time.Second
+	// candidatesBatchSize keeps the query of a targeted export well below the maximum BSON document size.
+	candidatesBatchSize = 10000
+	// maxCursorReopens bounds how many times in a row a failing cursor is re-opened without streaming any document.
+	// The server closes cursors left idle for too long, as happens while an export job is paused.
+	maxCursorReopens = 3
 )
 
 type contentFinder interface {
-	findContent(ctx context.Context, candidates []string) (cursor, int, error)
+	findContent(ctx context.Context, candidates []string, filter *content.Filter, after string) (cursor, error)
+	countContent(ctx context.Context, candidates []string, filter *content.Filter, after string) (int, error)
 }
 
 type cursor interface {
@@ -32,28 +46,150 @@ func NewInquirer(finder contentFinder, log *logger.UPPLogger) *Inquirer {
 	}
 }
 
-func (i *Inquirer) Inquire(ctx context.Context, candidates []string) (chan *content.Stub, int, error) {
-	cur, length, err := i.finder.findContent(ctx, candidates)
+// inquiry holds the query of the documents to stream.
+type inquiry struct {
+	batches [][]string
+	filter  *content.Filter
+	after   string
+	timeout time.Duration
+}
+
+// Inquire streams the exportable documents in UUID order, narrowed down by the optional filter.
+// If after is set, only documents with a greater UUID are returned. The stream stops early when ctx is cancelled,
+// and ends with an error if the documents can't be read to the end.
+// Long candidate lists are queried in batches, whose cursors are opened one after the other.
+func (i *Inquirer) Inquire(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+	q := inquiry{
+		batches: batchCandidates(candidates),
+		filter:  filter,
+		after:   after,
+		timeout: targetedQueryTimeout,
+	}
+	if len(candidates) == 0 {
+		q.timeout = fullQueryTimeout
+	}
+
+	total := 0
+	for _, batch := range q.batches {
+		count, err := i.countBatch(ctx, q, batch)
+		if err != nil {
+			return nil, 0, err
+		}
+		total += count
+	}
+
+	// The first cursor is opened right away, so that a failing query is reported before the job starts
+	first, err := i.findBatch(ctx, q, q.batches[0], q.after)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	docs := make(chan *content.Stub, 8)
-	go i.processDocuments(context.Background(), cur, docs)
+	stream := content.NewStream(make(chan *content.Stub, 8))
+	go i.processDocuments(ctx, q, first, stream)
+
+	return stream, total, nil
+}
+
+func (i *Inquirer) findBatch(ctx context.Context, q inquiry, batch []string, after string) (cursor, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
 
-	return docs, length, nil
+	return i.finder.findContent(queryCtx, batch, q.filter, after)
 }
 
-func (i *Inquirer) processDocuments(ctx context.Context, c cursor, docs chan *content.Stub) {
-	defer func() {
-		close(docs)
-		_ = c.Close(ctx)
-	}()
+func (i *Inquirer) countBatch(ctx context.Context, q inquiry, batch []string) (int, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
 
+	return i.finder.countContent(queryCtx, batch, q.filter, q.after)
+}
+
+// batchCandidates splits the candidates into sorted batches, so that the documents of consecutive batches
+// keep following each other in UUID order.
+func batchCandidates(candidates []string) [][]string {
+	if len(candidates) <= candidatesBatchSize {
+		return [][]string{candidates}
+	}
+
+	sorted := append([]string(nil), candidates...)
+	sort.Strings(sorted)
+
+	var batches [][]string
+	for len(sorted) > candidatesBatchSize {
+		batches = append(batches, sorted[:candidatesBatchSize])
+		sorted = sorted[candidatesBatchSize:]
+	}
+	return append(batches, sorted)
+}
+
+func (i *Inquirer) processDocuments(ctx context.Context, q inquiry, first cursor, stream *content.Stream) {
 	counter := 0
+	for n, batch := range q.batches {
+		c := first
+		if n > 0 {
+			var err error
+			if c, err = i.findBatch(ctx, q, batch, q.after); err != nil {
+				i.endStream(ctx, stream, counter, fmt.Errorf("finding documents: %w", err))
+				return
+			}
+		}
+
+		if err := i.processBatch(ctx, q, batch, c, stream.Docs, &counter); err != nil || ctx.Err() != nil {
+			i.endStream(ctx, stream, counter, err)
+			return
+		}
+	}
 
+	i.log.Infof("Processed %v docs", counter)
+	stream.End(nil)
+}
+
+// processBatch sends the documents of the batch to docs. A failing cursor is re-opened after the last document sent,
+// unless it keeps failing without any document being sent.
+func (i *Inquirer) processBatch(ctx context.Context, q inquiry, batch []string, c cursor, docs chan *content.Stub, counter *int) error {
+	after := q.after
+	reopens := 0
+	for {
+		last, err := i.processCursor(ctx, c, docs, counter)
+		_ = c.Close(context.Background())
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+
+		if last != "" {
+			after = last
+			reopens = 0
+		}
+		if reopens == maxCursorReopens {
+			return err
+		}
+		reopens++
+
+		i.log.WithError(err).Warnf("Re-opening the cursor after document %q", after)
+		if c, err = i.findBatch(ctx, q, batch, after); err != nil {
+			return fmt.Errorf("finding documents: %w", err)
+		}
+	}
+}
+
+// endStream ends a stream which was stopped by the cancellation of ctx or cut short by an error.
+func (i *Inquirer) endStream(ctx context.Context, stream *content.Stream, counter int, err error) {
+	if ctx.Err() != nil {
+		i.log.Infof("Processing docs stopped after %v docs", counter)
+		stream.End(nil)
+		return
+	}
+
+	i.log.WithError(err).Errorf("Processing docs failed after %v docs", counter)
+	stream.End(err)
+}
+
+// processCursor sends the documents of the cursor to docs until the cursor is exhausted or ctx is cancelled.
+// It returns the UUID of the last document sent, and an error if the cursor fails.
+func (i *Inquirer) processCursor(ctx context.Context, c cursor, docs chan *content.Stub, counter *int) (string, error) {
+	last := ""
 	for c.Next(ctx) {
-		counter++
+		*counter++
 
 		var doc bson.M
 		if err := c.Decode(&doc); err != nil {
@@ -66,13 +202,17 @@ func (i *Inquirer) processDocuments(ctx context.Context, c cursor, docs chan *co
 			i.log.WithError(err).Warn("Failed to map document")
 			continue
 		}
-		docs <- stub
+		select {
+		case docs <- stub:
+			last = stub.UUID
+		case <-ctx.Done():
+			return last, nil
+		}
 	}
-	if err := c.Err(); err != nil {
-		i.log.WithError(err).Error("Error occurred while iterating over collection")
+	if err := c.Err(); err != nil && ctx.Err() == nil {
+		return last, fmt.Errorf("iterating over collection: %w", err)
 	}
-
-	i.log.Infof("Processed %v docs", counter)
+	return last, nil
 }
 
 func mapStub(doc map[string]interface{}) (*content.Stub, error) {
@@ -81,9 +221,31 @@ func mapStub(doc map[string]interface{}) (*content.Stub, error) {
 		return nil, fmt.Errorf("uuid not found in document: %v", doc)
 	}
 
+	contentType, _ := doc["type"].(string)
+	lastModified, _ := doc["lastModified"].(string)
+
 	return &content.Stub{
 		UUID:             docUUID.(string),
 		Date:             content.GetDateOrDefault(doc),
 		CanBeDistributed: "",
+		ContentType:      contentType,
+		Publication:      mapStrings(doc["publication"]),
+		LastModified:     lastModified,
 	}, nil
 }
+
+// mapStrings converts a decoded BSON array to a slice of strings, skipping elements of other types.
+func mapStrings(value interface{}) []string {
+	values, ok := value.(primitive.A)
+	if !ok {
+		return nil
+	}
+
+	var result []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -17,9 +18,14 @@ type mockFinder struct {
 	mock.Mock
 }
 
-func (f *mockFinder) findContent(ctx context.Context, candidates []string) (cursor, int, error) {
-	args := f.Called(ctx, candidates)
-	return args.Get(0).(cursor), args.Int(1), args.Error(2)
+func (f *mockFinder) findContent(ctx context.Context, candidates []string, filter *content.Filter, after string) (cursor, error) {
+	args := f.Called(ctx, candidates, filter, after)
+	return args.Get(0).(cursor), args.Error(1)
+}
+
+func (f *mockFinder) countContent(ctx context.Context, candidates []string, filter *content.Filter, after string) (int, error) {
+	args := f.Called(ctx, candidates, filter, after)
+	return args.Int(0), args.Error(1)
 }
 
 type mockCursor struct {
@@ -54,31 +60,38 @@ func TestInquirer_InquireSuccessfully(t *testing.T) {
 	ctx := context.Background()
 	log := logger.NewUPPLogger("test", "PANIC")
 
-	finder.On("findContent", ctx, mock.AnythingOfType("[]string")).Return(cursor, 1, nil)
+	finder.On("countContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "").Return(1, nil)
+	finder.On("findContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "").Return(cursor, nil)
 	cursor.On("Next", ctx).Return(true).Once()
 	cursor.On("Decode", mock.AnythingOfType("*primitive.M")).Return(nil).
 		Run(func(args mock.Arguments) {
 			arg := args.Get(0).(*primitive.M)
 			*arg = make(map[string]interface{})
 			(*arg)["uuid"] = testUUID
+			(*arg)["type"] = "Article"
+			(*arg)["publication"] = primitive.A{"pub-1", "pub-2"}
+			(*arg)["lastModified"] = "2024-03-01T10:00:00.123Z"
 		}).Once()
 	cursor.On("Next", ctx).Return(false)
 	cursor.On("Err").Return(nil)
 	cursor.On("Close", ctx).Return(nil)
 	inquirer := NewInquirer(finder, log)
 
-	docCh, count, err := inquirer.Inquire(ctx, nil)
+	stream, count, err := inquirer.Inquire(ctx, nil, nil, "")
 	assert.NoError(t, err)
 	assert.Equal(t, 1, count)
 waitLoop:
 	for {
 		select {
-		case doc, open := <-docCh:
+		case doc, open := <-stream.Docs:
 			if !open {
 				break waitLoop
 			}
 			assert.Equal(t, testUUID, doc.UUID)
 			assert.Equal(t, content.DefaultDate, doc.Date)
+			assert.Equal(t, "Article", doc.ContentType)
+			assert.Equal(t, []string{"pub-1", "pub-2"}, doc.Publication)
+			assert.Equal(t, "2024-03-01T10:00:00.123Z", doc.LastModified)
 
 		case <-time.After(3 * time.Second):
 			t.FailNow()
@@ -97,7 +110,8 @@ func TestInquirer_InquireWithoutValidContent(t *testing.T) {
 	log := logger.NewUPPLogger("test", "PANIC")
 	ctx := context.Background()
 
-	finder.On("findContent", ctx, candidates).Return(cursor, 1, nil)
+	finder.On("countContent", mock.Anything, candidates, (*content.Filter)(nil), "").Return(1, nil)
+	finder.On("findContent", mock.Anything, candidates, (*content.Filter)(nil), "").Return(cursor, nil)
 	cursor.On("Next", ctx).Return(true).Once()
 	cursor.On("Decode", mock.AnythingOfType("*primitive.M")).Return(nil).
 		Run(func(args mock.Arguments) {
@@ -109,13 +123,13 @@ func TestInquirer_InquireWithoutValidContent(t *testing.T) {
 	cursor.On("Close", ctx).Return(nil)
 	inquirer := NewInquirer(finder, log)
 
-	docCh, count, err := inquirer.Inquire(ctx, candidates)
+	stream, count, err := inquirer.Inquire(ctx, candidates, nil, "")
 	assert.NoError(t, err)
 	assert.Equal(t, 1, count)
 waitLoop:
 	for {
 		select {
-		case _, open := <-docCh:
+		case _, open := <-stream.Docs:
 			if !open {
 				break waitLoop
 			}
@@ -137,15 +151,16 @@ func TestInquirer_InquireErrorFindingUUIDs(t *testing.T) {
 	log := logger.NewUPPLogger("test", "PANIC")
 	ctx := context.Background()
 
-	finder.On("findContent", ctx, candidates).Return(cursor, 0, fmt.Errorf("mongo err"))
+	finder.On("countContent", mock.Anything, candidates, (*content.Filter)(nil), "").Return(1, nil)
+	finder.On("findContent", mock.Anything, candidates, (*content.Filter)(nil), "").Return(cursor, fmt.Errorf("mongo err"))
 
 	inquirer := NewInquirer(finder, log)
 
-	docCh, count, err := inquirer.Inquire(ctx, candidates)
+	stream, count, err := inquirer.Inquire(ctx, candidates, nil, "")
 	assert.Error(t, err)
 	assert.EqualError(t, err, "mongo err")
 	assert.Equal(t, 0, count)
-	assert.Nil(t, docCh)
+	assert.Nil(t, stream)
 
 	finder.AssertExpectations(t)
 	cursor.AssertExpectations(t)
@@ -160,7 +175,8 @@ func TestInquirer_InquireErrorOnDecoding(t *testing.T) {
 	log := logger.NewUPPLogger("test", "PANIC")
 	ctx := context.Background()
 
-	finder.On("findContent", ctx, candidates).Return(cursor, 1, nil)
+	finder.On("countContent", mock.Anything, candidates, (*content.Filter)(nil), "").Return(1, nil)
+	finder.On("findContent", mock.Anything, candidates, (*content.Filter)(nil), "").Return(cursor, nil)
 	cursor.On("Next", ctx).Return(true).Once()
 	cursor.On("Decode", mock.AnythingOfType("*primitive.M")).Return(fmt.Errorf("decode error"))
 	cursor.On("Next", ctx).Return(false)
@@ -168,13 +184,13 @@ func TestInquirer_InquireErrorOnDecoding(t *testing.T) {
 	cursor.On("Close", ctx).Return(nil)
 	inquirer := NewInquirer(finder, log)
 
-	docCh, count, err := inquirer.Inquire(ctx, candidates)
+	stream, count, err := inquirer.Inquire(ctx, candidates, nil, "")
 	assert.NoError(t, err)
 	assert.Equal(t, 1, count)
 waitLoop:
 	for {
 		select {
-		case _, open := <-docCh:
+		case _, open := <-stream.Docs:
 			if !open {
 				break waitLoop
 			}
@@ -186,3 +202,206 @@ waitLoop:
 	finder.AssertExpectations(t)
 	cursor.AssertExpectations(t)
 }
+
+func TestInquirer_InquireStopsWhenCancelled(t *testing.T) {
+	finder := new(mockFinder)
+	cursor := new(mockCursor)
+
+	log := logger.NewUPPLogger("test", "PANIC")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	finder.On("countContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "").Return(2, nil)
+	finder.On("findContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "").Return(cursor, nil)
+	cursor.On("Next", ctx).Return(true)
+	cursor.On("Decode", mock.AnythingOfType("*primitive.M")).Return(nil).
+		Run(func(args mock.Arguments) {
+			arg := args.Get(0).(*primitive.M)
+			*arg = make(map[string]interface{})
+			(*arg)["uuid"] = "uuid1"
+		})
+	cursor.On("Close", context.Background()).Return(nil)
+	inquirer := NewInquirer(finder, log)
+
+	stream, _, err := inquirer.Inquire(ctx, nil, nil, "")
+	assert.NoError(t, err)
+
+	<-stream.Docs
+	cancel()
+waitLoop:
+	for {
+		select {
+		case _, open := <-stream.Docs:
+			if !open {
+				break waitLoop
+			}
+		case <-time.After(3 * time.Second):
+			t.FailNow()
+		}
+	}
+	finder.AssertExpectations(t)
+	cursor.AssertNotCalled(t, "Err")
+}
+
+func TestInquirer_InquireInBatches(t *testing.T) {
+	finder := new(mockFinder)
+	first := new(mockCursor)
+	second := new(mockCursor)
+
+	candidates := make([]string, 0, candidatesBatchSize+1)
+	for n := candidatesBatchSize; n >= 0; n-- {
+		candidates = append(candidates, fmt.Sprintf("uuid-%05d", n))
+	}
+	firstBatch := mock.MatchedBy(func(batch []string) bool {
+		return len(batch) == candidatesBatchSize && batch[0] == "uuid-00000"
+	})
+	secondBatch := []string{fmt.Sprintf("uuid-%05d", candidatesBatchSize)}
+	ctx := context.Background()
+
+	firstClosed := make(chan struct{})
+	finder.On("countContent", mock.Anything, firstBatch, (*content.Filter)(nil), "").Return(1, nil)
+	finder.On("countContent", mock.Anything, secondBatch, (*content.Filter)(nil), "").Return(1, nil)
+	finder.On("findContent", mock.Anything, firstBatch, (*content.Filter)(nil), "").Return(first, nil).Once()
+	finder.On("findContent", mock.Anything, secondBatch, (*content.Filter)(nil), "").Return(second, nil).Once().
+		Run(func(_ mock.Arguments) {
+			select {
+			case <-firstClosed:
+			default:
+				t.Error("the second batch was queried while the first cursor was open")
+			}
+		})
+	for i, c := range []*mockCursor{first, second} {
+		uuid := fmt.Sprintf("uuid%d", i+1)
+		c.On("Next", ctx).Return(true).Once()
+		c.On("Decode", mock.AnythingOfType("*primitive.M")).Return(nil).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(0).(*primitive.M)
+				*arg = map[string]interface{}{"uuid": uuid}
+			}).Once()
+		c.On("Next", ctx).Return(false)
+		c.On("Err").Return(nil)
+	}
+	first.On("Close", ctx).Return(nil).Run(func(_ mock.Arguments) {
+		close(firstClosed)
+	})
+	second.On("Close", ctx).Return(nil)
+	inquirer := NewInquirer(finder, logger.NewUPPLogger("test", "PANIC"))
+
+	stream, count, err := inquirer.Inquire(ctx, candidates, nil, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	var uuids []string
+	for doc := range stream.Docs {
+		uuids = append(uuids, doc.UUID)
+	}
+	assert.Equal(t, []string{"uuid1", "uuid2"}, uuids)
+	assert.NoError(t, stream.Err())
+	finder.AssertExpectations(t)
+	first.AssertExpectations(t)
+	second.AssertExpectations(t)
+}
+
+func TestInquirer_InquireEndsWithBatchError(t *testing.T) {
+	finder := new(mockFinder)
+	first := new(mockCursor)
+
+	candidates := make([]string, 0, candidatesBatchSize+1)
+	for n := 0; n <= candidatesBatchSize; n++ {
+		candidates = append(candidates, fmt.Sprintf("uuid-%05d", n))
+	}
+	ctx := context.Background()
+
+	finder.On("countContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "").Return(1, nil)
+	finder.On("findContent", mock.Anything, candidates[:candidatesBatchSize], (*content.Filter)(nil), "").Return(first, nil)
+	finder.On("findContent", mock.Anything, candidates[candidatesBatchSize:], (*content.Filter)(nil), "").Return(first, fmt.Errorf("mongo err"))
+	first.On("Next", ctx).Return(false)
+	first.On("Err").Return(nil)
+	first.On("Close", ctx).Return(nil).Once()
+	inquirer := NewInquirer(finder, logger.NewUPPLogger("test", "PANIC"))
+
+	stream, count, err := inquirer.Inquire(ctx, candidates, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	for range stream.Docs {
+	}
+	assert.EqualError(t, stream.Err(), "finding documents: mongo err")
+	finder.AssertExpectations(t)
+	first.AssertExpectations(t)
+}
+
+func TestInquirer_InquireReopensAFailingCursor(t *testing.T) {
+	finder := new(mockFinder)
+	first := new(mockCursor)
+	reopened := new(mockCursor)
+	ctx := context.Background()
+
+	finder.On("countContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "").Return(2, nil)
+	finder.On("findContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "").Return(first, nil).Once()
+	finder.On("findContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "uuid1").Return(reopened, nil).Once()
+	for i, c := range []*mockCursor{first, reopened} {
+		uuid := fmt.Sprintf("uuid%d", i+1)
+		c.On("Next", ctx).Return(true).Once()
+		c.On("Decode", mock.AnythingOfType("*primitive.M")).Return(nil).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(0).(*primitive.M)
+				*arg = map[string]interface{}{"uuid": uuid}
+			}).Once()
+		c.On("Next", ctx).Return(false)
+		c.On("Close", ctx).Return(nil)
+	}
+	first.On("Err").Return(fmt.Errorf("cursor not found"))
+	reopened.On("Err").Return(nil)
+	inquirer := NewInquirer(finder, logger.NewUPPLogger("test", "PANIC"))
+
+	stream, _, err := inquirer.Inquire(ctx, nil, nil, "")
+	require.NoError(t, err)
+
+	var uuids []string
+	for doc := range stream.Docs {
+		uuids = append(uuids, doc.UUID)
+	}
+	assert.Equal(t, []string{"uuid1", "uuid2"}, uuids)
+	assert.NoError(t, stream.Err())
+	finder.AssertExpectations(t)
+	first.AssertExpectations(t)
+	reopened.AssertExpectations(t)
+}
+
+func TestInquirer_InquireEndsWithCursorError(t *testing.T) {
+	finder := new(mockFinder)
+	cursor := new(mockCursor)
+	failing := new(mockCursor)
+	ctx := context.Background()
+
+	finder.On("countContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "").Return(3, nil)
+	finder.On("findContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "").Return(cursor, nil)
+	finder.On("findContent", mock.Anything, mock.AnythingOfType("[]string"), (*content.Filter)(nil), "uuid1").Return(failing, nil).
+		Times(maxCursorReopens)
+	cursor.On("Next", ctx).Return(true).Once()
+	cursor.On("Decode", mock.AnythingOfType("*primitive.M")).Return(nil).
+		Run(func(args mock.Arguments) {
+			arg := args.Get(0).(*primitive.M)
+			*arg = map[string]interface{}{"uuid": "uuid1"}
+		}).Once()
+	cursor.On("Next", ctx).Return(false)
+	cursor.On("Err").Return(fmt.Errorf("connection reset"))
+	cursor.On("Close", ctx).Return(nil)
+	failing.On("Next", ctx).Return(false)
+	failing.On("Err").Return(fmt.Errorf("connection reset"))
+	failing.On("Close", ctx).Return(nil)
+	inquirer := NewInquirer(finder, logger.NewUPPLogger("test", "PANIC"))
+
+	stream, _, err := inquirer.Inquire(ctx, nil, nil, "")
+	require.NoError(t, err)
+
+	var uuids []string
+	for doc := range stream.Docs {
+		uuids = append(uuids, doc.UUID)
+	}
+	assert.Equal(t, []string{"uuid1"}, uuids)
+	assert.EqualError(t, stream.Err(), "iterating over collection: connection reset")
+	finder.AssertExpectations(t)
+	cursor.AssertExpectations(t)
+	failing.AssertExpectations(t)
+}
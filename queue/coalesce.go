@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"errors"
+	"sync"
+)
+
+// errSuperseded is returned for an UPDATE which was still waiting out its delay when a later notification
+// for the same content arrived.
+var errSuperseded = errors.New("superseded by a later notification for the same content")
+
+// pendingUpdates keeps the UPDATE waiting out its delay for each content UUID, so that a burst of notifications
+// for the same content results in a single export of its latest version, and a DELETE isn't followed by the
+// export of an older UPDATE.
+type pendingUpdates struct {
+	sync.Mutex
+	byUUID         map[string]*pendingUpdate
+	byNotification map[*Notification]*pendingUpdate
+}
+
+type pendingUpdate struct {
+	n          *Notification
+	superseded chan struct{}
+}
+
+func newPendingUpdates() *pendingUpdates {
+	return &pendingUpdates{
+		byUUID:         make(map[string]*pendingUpdate),
+		byNotification: make(map[*Notification]*pendingUpdate),
+	}
+}
+
+// supersede cancels the UPDATE pending for the content of the notification. An UPDATE becomes the pending one
+// in its place. Notifications have to be superseded in the order they were received.
+func (p *pendingUpdates) supersede(n *Notification) {
+	p.Lock()
+	defer p.Unlock()
+	if previous, ok := p.byUUID[n.Stub.UUID]; ok {
+		close(previous.superseded)
+		delete(p.byUUID, n.Stub.UUID)
+	}
+	if n.EvType != UPDATE {
+		return
+	}
+
+	update := &pendingUpdate{n: n, superseded: make(chan struct{})}
+	p.byUUID[n.Stub.UUID] = update
+	p.byNotification[n] = update
+}
+
+// lookup returns the pending entry of the UPDATE, or nil if it didn't go through supersede.
+func (p *pendingUpdates) lookup(n *Notification) *pendingUpdate {
+	p.Lock()
+	defer p.Unlock()
+	return p.byNotification[n]
+}
+
+// done forgets the UPDATE once its delay is over.
+func (p *pendingUpdates) done(update *pendingUpdate) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.byNotification, update.n)
+	if p.byUUID[update.n.Stub.UUID] == update {
+		delete(p.byUUID, update.n.Stub.UUID)
+	}
+}
@@ -1,9 +1,12 @@
 package queue
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 
+	"github.com/Financial-Times/content-exporter/deadletter"
 	"github.com/Financial-Times/content-exporter/export"
 	"github.com/Financial-Times/content-exporter/policy"
 	"github.com/Financial-Times/go-logger/v2"
@@ -20,12 +23,18 @@ type Listener struct {
 	sync.RWMutex
 	paused              bool
 	terminator          *export.Terminator
+	stopping            context.Context
+	stop                context.CancelFunc
+	receivedLock        sync.RWMutex
 	received            chan *Notification
 	pending             map[string]*Notification
 	notificationHandler *NotificationHandler
 	messageMapper       *MessageMapper
 	policyEvaluator     Agent
+	deadLetters         deadletter.Store
 	workers             chan struct{}
+	lanesLock           sync.Mutex
+	lanes               map[string][]*Notification
 	log                 *logger.UPPLogger
 }
 
@@ -34,20 +43,26 @@ func NewListener(
 	notificationHandler *NotificationHandler,
 	messageMapper *MessageMapper,
 	policyEvaluator Agent,
+	deadLetters deadletter.Store,
 	locker *export.Locker,
 	maxGoRoutines int,
 	log *logger.UPPLogger,
 ) *Listener {
+	stopping, stop := context.WithCancel(context.Background())
 	return &Listener{
 		messageConsumer:     messageConsumer,
 		locker:              locker,
+		stopping:            stopping,
+		stop:                stop,
 		received:            make(chan *Notification, 1),
 		pending:             make(map[string]*Notification),
 		terminator:          export.NewTerminator(),
 		notificationHandler: notificationHandler,
 		messageMapper:       messageMapper,
 		policyEvaluator:     policyEvaluator,
+		deadLetters:         deadLetters,
 		workers:             make(chan struct{}, maxGoRoutines),
+		lanes:               make(map[string][]*Notification),
 		log:                 log,
 	}
 }
@@ -71,6 +86,7 @@ func (l *Listener) Start() {
 	go l.handleNotifications()
 
 	defer func() {
+		l.stop()
 		l.terminator.ShutDownPrepared = true
 		l.terminatePendingNotifications()
 	}()
@@ -114,6 +130,8 @@ func (l *Listener) Stop() {
 
 func (l *Listener) cleanup() {
 	l.terminator.Cleanup.Do(func() {
+		l.receivedLock.Lock()
+		defer l.receivedLock.Unlock()
 		close(l.received)
 	})
 }
@@ -152,6 +170,18 @@ func (l *Listener) handleMessage(msg kafka.FTMessage) {
 		return
 	}
 
+	if !l.dispatch(n, log) {
+		return
+	}
+	if l.terminator.ShutDownPrepared {
+		l.cleanup()
+	}
+}
+
+// dispatch hands the notification to the notification handler unless the content policy says to skip it.
+// Notifications whose policy can't be evaluated are dead-lettered. It returns false if the notification
+// wasn't handed over, and releases the dead letter of a replayed notification if the listener is shutting down.
+func (l *Listener) dispatch(n *Notification, log *logger.LogEntry) bool {
 	input := map[string]interface{}{
 		"payload": map[string]interface{}{
 			"publication":   n.Stub.Publication,
@@ -162,31 +192,43 @@ func (l *Listener) handleMessage(msg kafka.FTMessage) {
 	res, err := l.policyEvaluator.EvaluateContentPolicy(input)
 	if err != nil {
 		log.WithError(err).Error("Error with policy evaluation")
-		return
+		l.deadLetter(n, deadletter.PolicyStage, err)
+		l.replayed(n)
+		return false
 	}
 	if res.Skip {
 		log.WithField("reasons", res.Reasons).Infof("Skipping content")
-		return
+		l.replayed(n)
+		return false
 	}
 
-	l.Lock()
-	l.pending[n.Tid] = n
-	l.Unlock()
-	select {
-	case l.received <- n:
-	case <-n.Quit:
-		log.WithUUID(n.Stub.UUID).Error("Notification handling is terminated")
-		return
+	// received is closed once the listener is stopping, which can't happen during the send
+	l.receivedLock.RLock()
+	defer l.receivedLock.RUnlock()
+	if l.stopping.Err() == nil {
+		l.Lock()
+		l.pending[n.Tid] = n
+		l.Unlock()
+		select {
+		case l.received <- n:
+			return true
+		case <-n.Quit:
+		case <-l.stopping.Done():
+		}
 	}
-	if l.terminator.ShutDownPrepared {
-		l.cleanup()
+
+	log.WithUUID(n.Stub.UUID).Error("Notification handling is terminated")
+	if n.LetterID != "" {
+		l.deadLetters.Release(n.LetterID)
 	}
+	return false
 }
 
 func (l *Listener) handleNotifications() {
 	l.log.Info("Started handling notifications")
 	for n := range l.received {
 		log := l.log.WithTransactionID(n.Tid)
+		l.notificationHandler.coalesce(n)
 
 		if l.paused {
 			log.Info("PAUSED handling notification")
@@ -196,32 +238,139 @@ func (l *Listener) handleNotifications() {
 			log.Info("PAUSE finished. Resuming handling notification")
 		}
 
-		l.workers <- struct{}{}
-		go func(notification *Notification, log *logger.LogEntry) {
-			defer func() {
-				<-l.workers
-			}()
-
-			log = log.
-				WithUUID(notification.Stub.UUID).
-				WithField("event_type", notification.EvType)
-
-			err := l.notificationHandler.handleNotification(notification)
-			if err != nil {
-				log.WithError(err).Error("Failed to handle notification")
-			} else {
-				log.Info("Successfully handled notification")
+		if !l.notificationHandler.available() {
+			log.Warn("PAUSED handling notifications while downstream services are unavailable")
+			for !l.notificationHandler.available() {
+				time.Sleep(time.Millisecond * 500)
 			}
+			log.Info("Downstream services are available. Resuming handling notifications")
+		}
 
-			l.Lock()
-			delete(l.pending, notification.Tid)
-			l.Unlock()
-		}(n, log)
+		l.enqueue(n)
 	}
 	l.log.Info("Stopped handling notifications")
 	l.terminator.ShutDown = true
 }
 
+// enqueue appends the notification to the lane of its content, so that the notifications for the same content
+// are handled one after the other in the order they were received. A lane whose content has no notification
+// being handled is started on a new worker, blocking while all the workers are busy.
+func (l *Listener) enqueue(n *Notification) {
+	l.lanesLock.Lock()
+	if queued, ok := l.lanes[n.Stub.UUID]; ok {
+		l.lanes[n.Stub.UUID] = append(queued, n)
+		l.lanesLock.Unlock()
+		return
+	}
+	l.lanes[n.Stub.UUID] = nil
+	l.lanesLock.Unlock()
+
+	l.workers <- struct{}{}
+	go l.runLane(n)
+}
+
+// runLane handles the notification, then the ones queued behind it for the same content, until the lane is empty.
+func (l *Listener) runLane(n *Notification) {
+	defer func() {
+		<-l.workers
+	}()
+
+	for {
+		l.handle(n)
+
+		l.lanesLock.Lock()
+		queued := l.lanes[n.Stub.UUID]
+		if len(queued) == 0 {
+			delete(l.lanes, n.Stub.UUID)
+			l.lanesLock.Unlock()
+			return
+		}
+		n = queued[0]
+		l.lanes[n.Stub.UUID] = queued[1:]
+		l.lanesLock.Unlock()
+	}
+}
+
+func (l *Listener) handle(n *Notification) {
+	log := l.log.
+		WithTransactionID(n.Tid).
+		WithUUID(n.Stub.UUID).
+		WithField("event_type", n.EvType)
+
+	err := l.notificationHandler.handleNotification(n)
+	switch {
+	case errors.Is(err, errSuperseded):
+		log.Info("Skipping notification superseded by a later one")
+	case err != nil:
+		log.WithError(err).Error("Failed to handle notification")
+		l.deadLetter(n, deadletter.HandlingStage, err)
+	default:
+		log.Info("Successfully handled notification")
+	}
+
+	l.replayed(n)
+
+	l.Lock()
+	delete(l.pending, n.Tid)
+	l.Unlock()
+}
+
+// replayed removes the dead letter which the notification was replayed from, once it was handled again.
+func (l *Listener) replayed(n *Notification) {
+	if n.LetterID != "" {
+		l.deadLetters.Remove(n.LetterID)
+	}
+}
+
+// deadLetter keeps a failed notification so that it can be replayed.
+func (l *Listener) deadLetter(n *Notification, stage deadletter.Stage, err error) {
+	l.deadLetters.Add(deadletter.Letter{
+		Stage:         stage,
+		EventType:     string(n.EvType),
+		TransactionID: n.Tid,
+		Stub:          n.Stub,
+		Error:         err.Error(),
+		Replays:       n.Replays,
+	})
+}
+
+// DeadLetters returns the notifications which failed, the oldest first.
+func (l *Listener) DeadLetters() []deadletter.Letter {
+	return l.deadLetters.List()
+}
+
+// Replay claims the dead letters with the given IDs, or all of them without IDs, and dispatches them again in
+// the background, evaluating their content policy first. A letter is removed once its notification was handled
+// again, and notifications which fail again are dead-lettered anew. It returns the number of replayed notifications.
+func (l *Listener) Replay(ids ...string) int {
+	letters := l.deadLetters.Claim(ids...)
+	go func() {
+		for i, letter := range letters {
+			if l.stopping.Err() != nil {
+				// Keep the letters which couldn't be replayed before the shutdown for a later replay
+				for _, remaining := range letters[i:] {
+					l.deadLetters.Release(remaining.ID)
+				}
+				return
+			}
+
+			n := &Notification{
+				Stub:       letter.Stub,
+				EvType:     EventType(letter.EventType),
+				Tid:        letter.TransactionID,
+				Terminator: export.NewTerminator(),
+				Replays:    letter.Replays + 1,
+				LetterID:   letter.ID,
+				Received:   time.Now(),
+			}
+			log := l.log.WithTransactionID(n.Tid).WithUUID(n.Stub.UUID)
+			log.Infof("Replaying dead letter %v", letter.ID)
+			l.dispatch(n, log)
+		}
+	}()
+	return len(letters)
+}
+
 func (l *Listener) terminatePendingNotifications() {
 	l.RLock()
 	defer l.RUnlock()
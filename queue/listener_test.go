@@ -0,0 +1,160 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/content-exporter/deadletter"
+	"github.com/Financial-Times/content-exporter/export"
+	"github.com/Financial-Times/content-exporter/policy"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type agentMock struct {
+	err error
+}
+
+func (a *agentMock) EvaluateContentPolicy(_ map[string]interface{}) (*policy.ContentPolicyResult, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &policy.ContentPolicyResult{}, nil
+}
+
+func TestListener_DeadLettersPolicyErrors(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	store := deadletter.NewMemoryStore()
+	l := NewListener(nil, nil, nil, &agentMock{err: fmt.Errorf("opa is unavailable")}, store, export.NewLocker(), 1, log)
+	n := &Notification{Stub: content.Stub{UUID: "uuid-a"}, EvType: UPDATE, Tid: "tid_1234", Terminator: export.NewTerminator()}
+
+	assert.False(t, l.dispatch(n, log.WithTransactionID(n.Tid)))
+
+	letters := l.DeadLetters()
+	require.Len(t, letters, 1)
+	assert.Equal(t, deadletter.PolicyStage, letters[0].Stage)
+	assert.Equal(t, "UPDATE", letters[0].EventType)
+	assert.Equal(t, "tid_1234", letters[0].TransactionID)
+	assert.Equal(t, "uuid-a", letters[0].Stub.UUID)
+	assert.Equal(t, "opa is unavailable", letters[0].Error)
+}
+
+func TestListener_Replay(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	store := deadletter.NewMemoryStore()
+	store.Add(deadletter.Letter{ID: "letter-a", Stage: deadletter.HandlingStage, EventType: "DELETE", TransactionID: "tid_1234", Stub: content.Stub{UUID: "uuid-a"}})
+	store.Add(deadletter.Letter{ID: "letter-b", Stage: deadletter.HandlingStage, EventType: "UPDATE", Stub: content.Stub{UUID: "uuid-b"}})
+	updater := new(mockUpdater)
+	updater.On("Delete", "uuid-a", "tid_1234").Return(nil).Once()
+	handler := NewNotificationHandler(content.NewExporter(nil, updater, nil, nil, nil, nil), 0, RetryConfig{})
+	l := NewListener(nil, handler, nil, &agentMock{}, store, export.NewLocker(), 1, log)
+
+	assert.Equal(t, 1, l.Replay("letter-a"))
+
+	var n *Notification
+	select {
+	case n = <-l.received:
+		assert.Equal(t, "uuid-a", n.Stub.UUID)
+		assert.Equal(t, DELETE, n.EvType)
+		assert.Equal(t, "tid_1234", n.Tid)
+		assert.Equal(t, 1, n.Replays)
+	case <-time.After(time.Second):
+		t.Fatal("the dead letter was not replayed")
+	}
+
+	// The letter is kept until its notification was handled again
+	assert.Len(t, l.DeadLetters(), 2)
+	assert.Equal(t, 0, l.Replay("letter-a"))
+
+	l.handle(n)
+	letters := l.DeadLetters()
+	require.Len(t, letters, 1)
+	assert.Equal(t, "letter-b", letters[0].ID)
+	updater.AssertExpectations(t)
+}
+
+func TestListener_ReplayKeepsLettersWhenStopping(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	store := deadletter.NewMemoryStore()
+	store.Add(deadletter.Letter{ID: "letter-a", Stage: deadletter.HandlingStage, EventType: "DELETE", Stub: content.Stub{UUID: "uuid-a"}})
+	l := NewListener(nil, nil, nil, &agentMock{}, store, export.NewLocker(), 1, log)
+	l.stop()
+	l.cleanup()
+
+	assert.Equal(t, 1, l.Replay())
+
+	require.Eventually(t, func() bool {
+		return len(store.Claim("letter-a")) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Len(t, l.DeadLetters(), 1)
+}
+
+func TestListener_HandlesNotificationsForTheSameContentInOrder(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	fetcher := new(mockFetcher)
+	updater := new(mockUpdater)
+	handler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{})
+	l := NewListener(nil, handler, nil, &agentMock{}, deadletter.NewMemoryStore(), export.NewLocker(), 2, log)
+	go l.handleNotifications()
+	defer close(l.received)
+
+	var lock sync.Mutex
+	var calls []string
+	record := func(call string) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls = append(calls, call)
+	}
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	deleted := make(chan struct{})
+	fetcher.On("GetContent", "uuid-a", "tid_1").Run(func(_ mock.Arguments) {
+		close(fetching)
+		<-release
+	}).Return([]byte("payload"), nil).Once()
+	updater.On("Upload", []byte("payload"), "tid_1", "uuid-a", "aDate").Run(func(_ mock.Arguments) {
+		record("upload")
+	}).Return(nil).Once()
+	updater.On("Delete", "uuid-a", "tid_2").Run(func(_ mock.Arguments) {
+		record("delete")
+		close(deleted)
+	}).Return(nil).Once()
+	updater.On("Delete", "uuid-b", "tid_3").Return(nil).Once()
+
+	update := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid-a"}, EvType: UPDATE, Tid: "tid_1", Terminator: export.NewTerminator()}
+	require.True(t, l.dispatch(update, log.WithTransactionID(update.Tid)))
+	<-fetching
+
+	deletion := &Notification{Stub: content.Stub{UUID: "uuid-a"}, EvType: DELETE, Tid: "tid_2", Terminator: export.NewTerminator()}
+	require.True(t, l.dispatch(deletion, log.WithTransactionID(deletion.Tid)))
+	other := &Notification{Stub: content.Stub{UUID: "uuid-b"}, EvType: DELETE, Tid: "tid_3", Terminator: export.NewTerminator()}
+	require.True(t, l.dispatch(other, log.WithTransactionID(other.Tid)))
+
+	// Other content is handled alongside, while the DELETE waits for the UPDATE of its content
+	require.Eventually(t, func() bool {
+		l.RLock()
+		defer l.RUnlock()
+		_, ok := l.pending["tid_3"]
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+	lock.Lock()
+	assert.Empty(t, calls)
+	lock.Unlock()
+
+	close(release)
+	select {
+	case <-deleted:
+	case <-time.After(time.Second):
+		t.Fatal("the DELETE was not handled")
+	}
+	lock.Lock()
+	assert.Equal(t, []string{"upload", "delete"}, calls)
+	lock.Unlock()
+	fetcher.AssertExpectations(t)
+	updater.AssertExpectations(t)
+}
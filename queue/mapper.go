@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/Financial-Times/content-exporter/content"
 	"github.com/Financial-Times/content-exporter/export"
@@ -70,6 +71,7 @@ func (e *event) toNotification(tid string) (*Notification, error) {
 		EvType:     evType,
 		Terminator: export.NewTerminator(),
 		Tid:        tid,
+		Received:   time.Now(),
 	}, nil
 }
 
@@ -189,7 +189,7 @@ func TestKafkaMessageMapper_MapNotification(t *testing.T) {
 
 			require.NoError(t, err)
 
-			cmpOpts := cmpopts.IgnoreFields(Notification{}, "Stub.Date", "Terminator")
+			cmpOpts := cmpopts.IgnoreFields(Notification{}, "Stub.Date", "Terminator", "Received")
 			assert.Truef(t, cmp.Equal(test.expectedNotification, n, cmpOpts), "Mapped notification differs from expected:\n%s", cmp.Diff(test.expectedNotification, n, cmpOpts))
 		})
 	}
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -20,35 +21,74 @@ type Notification struct {
 	EvType EventType
 	Tid    string
 	*export.Terminator
+	// Replays counts how many times the notification was replayed from the dead letters
+	Replays int
+	// LetterID is the dead letter which the notification was replayed from, if any
+	LetterID string
+	// Received is when the notification was consumed or replayed. The max age of its retries counts from it.
+	Received time.Time
 }
 
+// circuitRetryInterval is how long a notification waits before it is handled again after a circuit breaker
+// prevented it from being handled.
+var circuitRetryInterval = 5 * time.Second
+
 type NotificationHandler struct {
 	exporter *content.Exporter
 	delay    int
+	retry    RetryConfig
+	pending  *pendingUpdates
 }
 
-func NewNotificationHandler(exporter *content.Exporter, delayForNotification int) *NotificationHandler {
+func NewNotificationHandler(exporter *content.Exporter, delayForNotification int, retry RetryConfig) *NotificationHandler {
 	return &NotificationHandler{
 		exporter: exporter,
 		delay:    delayForNotification,
+		retry:    retry,
+		pending:  newPendingUpdates(),
 	}
 }
 
+// coalesce lets the notification supersede the UPDATE still waiting out its delay for the same content.
+// It is called in the order the notifications are received, before they are handled.
+func (h *NotificationHandler) coalesce(n *Notification) {
+	h.pending.supersede(n)
+}
+
 func (h *NotificationHandler) handleNotification(n *Notification) error {
+	if n.Received.IsZero() {
+		n.Received = time.Now()
+	}
+
 	switch n.EvType {
 	case UPDATE:
-		select {
-		case <-time.After(time.Duration(h.delay) * time.Second):
-		case <-n.Quit:
-			return fmt.Errorf("delayed update terminated due to shutdown signal")
+		var superseded chan struct{}
+		update := h.pending.lookup(n)
+		if update != nil {
+			superseded = update.superseded
+		}
+
+		err := h.waitDelay(n, superseded)
+		if update != nil {
+			h.pending.done(update)
+		}
+		if err != nil {
+			return err
 		}
 
-		if err := h.exporter.Export(n.Tid, &n.Stub); err != nil {
+		err = h.retryWithBackoff(n, func() error {
+			return h.exporter.Export(n.Tid, &n.Stub)
+		})
+		// Content which is identical to its last upload is already in S3
+		if err != nil && !errors.Is(err, content.ErrUnchanged) {
 			return fmt.Errorf("exporting content: %w", err)
 		}
 
 	case DELETE:
-		if err := h.exporter.Delete(n.Stub.UUID, n.Tid); err != nil {
+		err := h.retryWithBackoff(n, func() error {
+			return h.exporter.Delete(n.Stub.UUID, n.Tid)
+		})
+		if err != nil {
 			return fmt.Errorf("deleting content: %w", err)
 		}
 
@@ -58,3 +98,78 @@ func (h *NotificationHandler) handleNotification(n *Notification) error {
 
 	return nil
 }
+
+// waitDelay waits out the delay of an UPDATE unless a later notification for the same content supersedes it.
+// A nil superseded channel, of an UPDATE which wasn't coalesced, never fires.
+func (h *NotificationHandler) waitDelay(n *Notification, superseded chan struct{}) error {
+	select {
+	case <-superseded:
+		return errSuperseded
+	default:
+	}
+
+	select {
+	case <-time.After(time.Duration(h.delay) * time.Second):
+		return nil
+	case <-superseded:
+		return errSuperseded
+	case <-n.Quit:
+		return fmt.Errorf("delayed update terminated due to shutdown signal")
+	}
+}
+
+// retryWithBackoff repeats the call, waiting longer after each attempt, while it fails because a downstream service
+// is unavailable and the notification is younger than the max age of the retries.
+func (h *NotificationHandler) retryWithBackoff(n *Notification, call func() error) error {
+	for attempts := 1; ; attempts++ {
+		err := h.retryWhileUnavailable(n, call)
+		// An open circuit is only left by a shutdown or once the notification is too old
+		if !content.IsTransient(err) || errors.Is(err, content.ErrCircuitOpen) {
+			return err
+		}
+
+		wait := h.retry.backoff(attempts)
+		if h.tooOld(n, wait) {
+			if attempts > 1 {
+				return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+			}
+			return err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-n.Quit:
+			return fmt.Errorf("retrying terminated due to shutdown signal: %w", err)
+		}
+	}
+}
+
+// retryWhileUnavailable repeats the call for as long as a circuit breaker prevents it from reaching the downstream services
+// and the notification is younger than the max age of the retries.
+func (h *NotificationHandler) retryWhileUnavailable(n *Notification, call func() error) error {
+	for {
+		err := call()
+		if !errors.Is(err, content.ErrCircuitOpen) {
+			return err
+		}
+		if h.tooOld(n, circuitRetryInterval) {
+			return fmt.Errorf("giving up while downstream services are unavailable: %w", err)
+		}
+
+		select {
+		case <-time.After(circuitRetryInterval):
+		case <-n.Quit:
+			return fmt.Errorf("waiting for downstream services terminated due to shutdown signal: %w", err)
+		}
+	}
+}
+
+// tooOld tells whether the notification would be older than the max age of the retries after the wait.
+func (h *NotificationHandler) tooOld(n *Notification, wait time.Duration) bool {
+	return time.Since(n.Received)+wait > h.retry.MaxAge
+}
+
+// available tells whether the downstream services can be called.
+func (h *NotificationHandler) available() bool {
+	return h.exporter.Available()
+}
@@ -38,7 +38,7 @@ func TestNotificationHandler_HandleUpdateSuccessfully(t *testing.T) {
 	fetcher := new(mockFetcher)
 	updater := new(mockUpdater)
 	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: UPDATE, Terminator: export.NewTerminator()}
-	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater), 0)
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{})
 
 	var testData []byte
 	fetcher.On("GetContent", n.Stub.UUID, n.Tid).Return(testData, nil)
@@ -51,11 +51,27 @@ func TestNotificationHandler_HandleUpdateSuccessfully(t *testing.T) {
 	updater.AssertExpectations(t)
 }
 
+func TestNotificationHandler_HandleUnchangedUpdate(t *testing.T) {
+	fetcher := new(mockFetcher)
+	updater := new(mockUpdater)
+	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: UPDATE, Terminator: export.NewTerminator()}
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, content.NewMemoryHashIndex()), 0, RetryConfig{})
+
+	testData := []byte("payload")
+	fetcher.On("GetContent", n.Stub.UUID, n.Tid).Return(testData, nil).Twice()
+	updater.On("Upload", testData, n.Tid, n.Stub.UUID, n.Stub.Date).Return(nil).Once()
+
+	assert.NoError(t, contentNotificationHandler.handleNotification(n))
+	assert.NoError(t, contentNotificationHandler.handleNotification(n))
+	fetcher.AssertExpectations(t)
+	updater.AssertExpectations(t)
+}
+
 func TestNotificationHandler_HandleUpdateWithError(t *testing.T) {
 	fetcher := new(mockFetcher)
 	updater := new(mockUpdater)
 	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: UPDATE, Terminator: export.NewTerminator()}
-	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater), 0)
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{})
 	var testData []byte
 	fetcher.On("GetContent", n.Stub.UUID, n.Tid).Return(testData, fmt.Errorf("fetcher err"))
 
@@ -71,7 +87,7 @@ func TestNotificationHandler_HandleUpdateWithQuitSignal(t *testing.T) {
 	fetcher := new(mockFetcher)
 	updater := new(mockUpdater)
 	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: UPDATE, Terminator: export.NewTerminator()}
-	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater), 30)
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 30, RetryConfig{})
 	go func() {
 		time.Sleep(500 * time.Millisecond)
 		n.Quit <- struct{}{}
@@ -88,7 +104,7 @@ func TestNotificationHandler_HandleDeleteSuccessfully(t *testing.T) {
 	fetcher := new(mockFetcher)
 	updater := new(mockUpdater)
 	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: DELETE, Terminator: export.NewTerminator()}
-	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater), 0)
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{})
 	updater.On("Delete", n.Stub.UUID, n.Tid).Return(nil)
 
 	err := contentNotificationHandler.handleNotification(n)
@@ -102,7 +118,7 @@ func TestNotificationHandler_HandleDeleteWithError(t *testing.T) {
 	fetcher := new(mockFetcher)
 	updater := new(mockUpdater)
 	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: DELETE, Terminator: export.NewTerminator()}
-	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater), 0)
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{})
 	updater.On("Delete", n.Stub.UUID, n.Tid).Return(fmt.Errorf("updater err"))
 
 	err := contentNotificationHandler.handleNotification(n)
@@ -112,3 +128,177 @@ func TestNotificationHandler_HandleDeleteWithError(t *testing.T) {
 	fetcher.AssertExpectations(t)
 	updater.AssertExpectations(t)
 }
+
+func TestNotificationHandler_HandleUpdateWhileCircuitIsOpen(t *testing.T) {
+	fetcher := new(mockFetcher)
+	updater := new(mockUpdater)
+	breaker := content.NewCircuitBreaker("enriched content", 1, time.Hour)
+	exporter := content.NewExporter(fetcher, updater, nil, breaker, nil, nil)
+	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: UPDATE, Terminator: export.NewTerminator()}
+	contentNotificationHandler := NewNotificationHandler(exporter, 0, RetryConfig{MaxAge: time.Hour})
+
+	var testData []byte
+	fetcher.On("GetContent", n.Stub.UUID, n.Tid).Return(testData, &content.UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: 503}).Once()
+	assert.Error(t, exporter.Export(n.Tid, &n.Stub))
+	assert.False(t, contentNotificationHandler.available())
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		n.Quit <- struct{}{}
+	}()
+	err := contentNotificationHandler.handleNotification(n)
+
+	assert.ErrorIs(t, err, content.ErrCircuitOpen)
+	assert.Contains(t, err.Error(), "terminated due to shutdown signal")
+	fetcher.AssertExpectations(t)
+	updater.AssertExpectations(t)
+}
+
+func TestNotificationHandler_GivesUpWhileCircuitIsOpenAfterMaxAge(t *testing.T) {
+	defer func(interval time.Duration) { circuitRetryInterval = interval }(circuitRetryInterval)
+	circuitRetryInterval = 40 * time.Millisecond
+
+	updater := new(mockUpdater)
+	breaker := content.NewCircuitBreaker("S3 writer", 1, time.Hour)
+	exporter := content.NewExporter(nil, updater, nil, nil, breaker, nil)
+	n := &Notification{Stub: content.Stub{UUID: "uuid1"}, Tid: "tid_1234", EvType: DELETE, Terminator: export.NewTerminator()}
+	contentNotificationHandler := NewNotificationHandler(exporter, 0, RetryConfig{MaxAge: 100 * time.Millisecond})
+
+	updater.On("Delete", n.Stub.UUID, n.Tid).Return(&content.UnexpectedStatusError{Operation: "deleting content", StatusCode: 503}).Once()
+	assert.Error(t, exporter.Delete(n.Stub.UUID, n.Tid))
+	assert.False(t, contentNotificationHandler.available())
+
+	err := contentNotificationHandler.handleNotification(n)
+
+	assert.ErrorIs(t, err, content.ErrCircuitOpen)
+	assert.Contains(t, err.Error(), "giving up while downstream services are unavailable")
+	updater.AssertExpectations(t)
+}
+
+func TestNotificationHandler_MaxAgeCountsFromReceipt(t *testing.T) {
+	fetcher := new(mockFetcher)
+	updater := new(mockUpdater)
+	n := &Notification{Stub: content.Stub{UUID: "uuid1"}, Tid: "tid_1234", EvType: DELETE, Terminator: export.NewTerminator(), Received: time.Now().Add(-time.Minute)}
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{
+		InitialInterval: 10 * time.Millisecond,
+		MaxAge:          time.Minute,
+	})
+	updater.On("Delete", n.Stub.UUID, n.Tid).Return(&content.UnexpectedStatusError{Operation: "deleting content", StatusCode: 503}).Once()
+
+	err := contentNotificationHandler.handleNotification(n)
+
+	assert.EqualError(t, err, "deleting content: deleting content failed with unexpected status code: 503")
+	updater.AssertExpectations(t)
+}
+
+func TestNotificationHandler_RetriesUnavailableServices(t *testing.T) {
+	fetcher := new(mockFetcher)
+	updater := new(mockUpdater)
+	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: UPDATE, Terminator: export.NewTerminator()}
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{
+		InitialInterval: 10 * time.Millisecond,
+		MaxAge:          time.Second,
+	})
+
+	testData := []byte("payload")
+	fetcher.On("GetContent", n.Stub.UUID, n.Tid).Return([]byte(nil), &content.UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: 503}).Twice()
+	fetcher.On("GetContent", n.Stub.UUID, n.Tid).Return(testData, nil).Once()
+	updater.On("Upload", testData, n.Tid, n.Stub.UUID, n.Stub.Date).Return(nil).Once()
+
+	assert.NoError(t, contentNotificationHandler.handleNotification(n))
+	fetcher.AssertExpectations(t)
+	updater.AssertExpectations(t)
+}
+
+func TestNotificationHandler_GivesUpRetryingAfterMaxAge(t *testing.T) {
+	fetcher := new(mockFetcher)
+	updater := new(mockUpdater)
+	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: DELETE, Terminator: export.NewTerminator()}
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{
+		InitialInterval: 40 * time.Millisecond,
+		MaxAge:          100 * time.Millisecond,
+	})
+	updater.On("Delete", n.Stub.UUID, n.Tid).Return(&content.UnexpectedStatusError{Operation: "deleting content", StatusCode: 503}).Twice()
+
+	err := contentNotificationHandler.handleNotification(n)
+
+	assert.EqualError(t, err, "deleting content: giving up after 2 attempts: deleting content failed with unexpected status code: 503")
+	updater.AssertExpectations(t)
+}
+
+func TestNotificationHandler_DoesNotRetryRejectedContent(t *testing.T) {
+	fetcher := new(mockFetcher)
+	updater := new(mockUpdater)
+	n := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1234", EvType: UPDATE, Terminator: export.NewTerminator()}
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{
+		InitialInterval: 10 * time.Millisecond,
+		MaxAge:          time.Second,
+	})
+	fetcher.On("GetContent", n.Stub.UUID, n.Tid).Return([]byte(nil), &content.UnexpectedStatusError{Operation: "fetching enriched content", StatusCode: 404}).Once()
+
+	err := contentNotificationHandler.handleNotification(n)
+
+	assert.EqualError(t, err, "exporting content: getting content: fetching enriched content failed with unexpected status code: 404")
+	fetcher.AssertExpectations(t)
+}
+
+func TestRetryConfig_Backoff(t *testing.T) {
+	retry := RetryConfig{InitialInterval: time.Second, MaxInterval: 5 * time.Second}
+	assert.Equal(t, time.Second, retry.backoff(1))
+	assert.Equal(t, 2*time.Second, retry.backoff(2))
+	assert.Equal(t, 4*time.Second, retry.backoff(3))
+	assert.Equal(t, 5*time.Second, retry.backoff(4))
+
+	retry.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		wait := retry.backoff(2)
+		assert.GreaterOrEqual(t, wait, time.Second)
+		assert.LessOrEqual(t, wait, 3*time.Second)
+	}
+}
+
+func TestNotificationHandler_LaterUpdateSupersedesDelayedOne(t *testing.T) {
+	fetcher := new(mockFetcher)
+	updater := new(mockUpdater)
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 30, RetryConfig{})
+	first := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1", EvType: UPDATE, Terminator: export.NewTerminator()}
+	second := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_2", EvType: UPDATE, Terminator: export.NewTerminator()}
+	other := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid2"}, Tid: "tid_3", EvType: UPDATE, Terminator: export.NewTerminator()}
+
+	contentNotificationHandler.coalesce(first)
+	contentNotificationHandler.coalesce(other)
+	handled := make(chan error, 1)
+	go func() {
+		handled <- contentNotificationHandler.handleNotification(first)
+	}()
+	contentNotificationHandler.coalesce(second)
+
+	select {
+	case err := <-handled:
+		assert.ErrorIs(t, err, errSuperseded)
+	case <-time.After(time.Second):
+		t.Fatal("the delayed update was not superseded")
+	}
+	assert.Nil(t, contentNotificationHandler.pending.lookup(first))
+	assert.NotNil(t, contentNotificationHandler.pending.lookup(second))
+	assert.NotNil(t, contentNotificationHandler.pending.lookup(other))
+	fetcher.AssertExpectations(t)
+	updater.AssertExpectations(t)
+}
+
+func TestNotificationHandler_DeleteCancelsPendingUpdate(t *testing.T) {
+	fetcher := new(mockFetcher)
+	updater := new(mockUpdater)
+	contentNotificationHandler := NewNotificationHandler(content.NewExporter(fetcher, updater, nil, nil, nil, nil), 0, RetryConfig{})
+	update := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_1", EvType: UPDATE, Terminator: export.NewTerminator()}
+	deletion := &Notification{Stub: content.Stub{Date: "aDate", UUID: "uuid1"}, Tid: "tid_2", EvType: DELETE, Terminator: export.NewTerminator()}
+	updater.On("Delete", deletion.Stub.UUID, deletion.Tid).Return(nil).Once()
+
+	contentNotificationHandler.coalesce(update)
+	contentNotificationHandler.coalesce(deletion)
+
+	assert.ErrorIs(t, contentNotificationHandler.handleNotification(update), errSuperseded)
+	assert.NoError(t, contentNotificationHandler.handleNotification(deletion))
+	fetcher.AssertExpectations(t)
+	updater.AssertExpectations(t)
+}
@@ -0,0 +1,38 @@
+package queue
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// retryMultiplier is how much the wait between two attempts grows after each failed attempt.
+const retryMultiplier = 2
+
+// RetryConfig bounds the retries of a notification which failed because a downstream service was unavailable.
+// A zero MaxAge disables the retries.
+type RetryConfig struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// Jitter is the fraction, between 0 and 1, by which a wait is randomly shortened or lengthened,
+	// so that notifications failing together are not retried together.
+	Jitter float64
+	// MaxAge is how long after it was received a notification may still be retried, including while it waits
+	// for an open circuit breaker.
+	MaxAge time.Duration
+}
+
+// backoff returns how long to wait after the given number of failed attempts.
+func (c RetryConfig) backoff(attempts int) time.Duration {
+	interval := float64(c.InitialInterval)
+	for i := 1; i < attempts; i++ {
+		interval *= retryMultiplier
+		if c.MaxInterval > 0 && interval >= float64(c.MaxInterval) {
+			interval = float64(c.MaxInterval)
+			break
+		}
+	}
+	if c.Jitter > 0 {
+		interval *= 1 + c.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(interval)
+}
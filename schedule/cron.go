@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLookahead bounds the search for the next run of an expression which never matches, e.g. "0 0 31 2 *".
+const maxLookahead = 5 * 366 * 24 * time.Hour
+
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// Cron is a standard five field cron expression: minute, hour, day of month, month and day of week.
+// Fields accept *, numbers, ranges, lists and steps, e.g. "*/15 2-4 * * 1,3". Sunday is 0 or 7.
+// Like in cron, a day matches if either the day of month or the day of week matches when both are restricted.
+// Expressions are evaluated in UTC.
+type Cron struct {
+	expr       string
+	minute     bitset
+	hour       bitset
+	dayOfMonth bitset
+	month      bitset
+	dayOfWeek  bitset
+	anyDom     bool
+	anyDow     bool
+}
+
+type bitset uint64
+
+func (b bitset) has(n int) bool {
+	return b&(1<<uint(n)) != 0
+}
+
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q should have 5 fields", expr)
+	}
+
+	c := &Cron{expr: expr}
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute of cron expression %q: %w", expr, err)
+	}
+	if c.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour of cron expression %q: %w", expr, err)
+	}
+	if c.dayOfMonth, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day of month of cron expression %q: %w", expr, err)
+	}
+	if c.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month of cron expression %q: %w", expr, err)
+	}
+	if c.dayOfWeek, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("day of week of cron expression %q: %w", expr, err)
+	}
+	if c.dayOfWeek.has(7) {
+		c.dayOfWeek |= 1
+	}
+	// A field which covers its whole range, however it is written, doesn't restrict the day
+	c.anyDom = c.dayOfMonth == fullRange(1, 31)
+	c.anyDow = c.dayOfWeek&fullRange(0, 6) == fullRange(0, 6)
+	return c, nil
+}
+
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Next returns the first time after the given one which matches the expression.
+// It returns the zero time if there is no such time within the next five years.
+func (c *Cron) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxLookahead)
+
+	for t.Before(limit) {
+		switch {
+		case !c.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !c.hour.has(t.Hour()):
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !c.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	dom := c.dayOfMonth.has(t.Day())
+	dow := c.dayOfWeek.has(int(t.Weekday()))
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// fullRange returns the set of all the values from min to max.
+func fullRange(min, max int) bitset {
+	return bitset(1<<uint(max+1) - 1<<uint(min))
+}
+
+func parseField(field string, min, max int) (bitset, error) {
+	var set bitset
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart = part[:i]
+		}
+
+		from, to := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if step > 1 {
+				// a step after a single value, e.g. 5/15, runs until the maximum
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", part, min, max)
+		}
+
+		for n := from; n <= to; n += step {
+			set |= 1 << uint(n)
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,131 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name        string
+		expr        string
+		expectedErr string
+	}{
+		{
+			name: "test that a standard expression is parsed",
+			expr: "*/15 2-4 * * 1,3",
+		},
+		{
+			name: "test that a macro is parsed",
+			expr: "@daily",
+		},
+		{
+			name:        "test that an expression with too few fields results in an error",
+			expr:        "0 2 * *",
+			expectedErr: "cron expression \"0 2 * *\" should have 5 fields",
+		},
+		{
+			name:        "test that a value out of range results in an error",
+			expr:        "60 2 * * *",
+			expectedErr: "minute of cron expression \"60 2 * * *\": \"60\" is out of the range 0-59",
+		},
+		{
+			name:        "test that an invalid step results in an error",
+			expr:        "0 */0 * * *",
+			expectedErr: "hour of cron expression \"0 */0 * * *\": invalid step in \"*/0\"",
+		},
+		{
+			name:        "test that an inverted range results in an error",
+			expr:        "0 2 * * 5-1",
+			expectedErr: "day of week of cron expression \"0 2 * * 5-1\": \"5-1\" is out of the range 0-7",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cron, err := ParseCron(test.expr)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expr, cron.String())
+		})
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	// 2024-03-01 is a Friday
+	after := time.Date(2024, 3, 1, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected time.Time
+	}{
+		{
+			name:     "test that every minute runs in the next minute",
+			expr:     "* * * * *",
+			expected: time.Date(2024, 3, 1, 10, 8, 0, 0, time.UTC),
+		},
+		{
+			name:     "test that a step runs at the next multiple",
+			expr:     "*/15 * * * *",
+			expected: time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			name:     "test that a daily expression runs the next day once the time has passed",
+			expr:     "0 2 * * *",
+			expected: time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "test that a day of week runs on the next matching weekday",
+			expr:     "30 1 * * 1",
+			expected: time.Date(2024, 3, 4, 1, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "test that Sunday can be given as 7",
+			expr:     "0 0 * * 7",
+			expected: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "test that a restricted day of month and day of week match either",
+			expr:     "0 0 15 * 0",
+			expected: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "test that a day of week covering every day doesn't restrict the day of month",
+			expr:     "0 0 15 * */1",
+			expected: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "test that a day of month covering every day doesn't restrict the day of week",
+			expr:     "0 0 1-31 * 1",
+			expected: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "test that a monthly expression runs on the first of the next month",
+			expr:     "@monthly",
+			expected: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "test that a leap day is found",
+			expr:     "0 0 29 2 *",
+			expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "test that an expression which never matches has no next run",
+			expr: "0 0 31 2 *",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cron, err := ParseCron(test.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, cron.Next(after))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/content-exporter/export"
+	"gopkg.in/yaml.v3"
+)
+
+// Schedule describes a recurring full or filtered export. If Since is set, every run is a delta export
+// of the content modified within that duration before the run.
+type Schedule struct {
+	Name       string          `yaml:"name" json:"Name"`
+	Cron       string          `yaml:"cron" json:"Cron"`
+	FullExport bool            `yaml:"fullExport" json:"FullExport,omitempty"`
+	Filter     *content.Filter `yaml:"filter" json:"Filter,omitempty"`
+	Since      string          `yaml:"since" json:"Since,omitempty"`
+	Workers    *int            `yaml:"workers" json:"Workers,omitempty"`
+	Throttle   *int            `yaml:"throttle" json:"Throttle,omitempty"`
+}
+
+type config struct {
+	Schedules []Schedule `yaml:"schedules"`
+}
+
+// ParseSchedules reads the schedules from YAML, either as a list or as the schedules field of an object.
+// As JSON is valid YAML, the schedules can be passed as JSON too.
+func ParseSchedules(data []byte) ([]Schedule, error) {
+	var schedules []Schedule
+	if err := yaml.Unmarshal(data, &schedules); err != nil {
+		var cfg config
+		if err = yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("unmarshaling schedules: %w", err)
+		}
+		schedules = cfg.Schedules
+	}
+
+	names := make(map[string]bool, len(schedules))
+	for _, s := range schedules {
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("schedule %q is defined more than once", s.Name)
+		}
+		names[s.Name] = true
+	}
+	return schedules, nil
+}
+
+func (s Schedule) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("schedule with cron expression %q has no name", s.Cron)
+	}
+	if _, err := ParseCron(s.Cron); err != nil {
+		return fmt.Errorf("schedule %q: %w", s.Name, err)
+	}
+	if _, err := s.sinceDuration(); err != nil {
+		return fmt.Errorf("schedule %q: %w", s.Name, err)
+	}
+	if !s.FullExport && s.Filter == nil && s.Since == "" {
+		return fmt.Errorf("schedule %q should be a full export or have a filter or a since duration", s.Name)
+	}
+	if s.Filter != nil {
+		if err := s.Filter.Validate(); err != nil {
+			return fmt.Errorf("schedule %q: invalid filter: %w", s.Name, err)
+		}
+	}
+	if err := s.settings().Validate(); err != nil {
+		return fmt.Errorf("schedule %q: %w", s.Name, err)
+	}
+	return nil
+}
+
+func (s Schedule) sinceDuration() (time.Duration, error) {
+	if s.Since == "" {
+		return 0, nil
+	}
+	since, err := time.ParseDuration(s.Since)
+	if err != nil || since <= 0 {
+		return 0, fmt.Errorf("since should be a positive duration like 26h")
+	}
+	return since, nil
+}
+
+func (s Schedule) settings() export.JobSettings {
+	return export.JobSettings{
+		Workers:  s.Workers,
+		Throttle: s.Throttle,
+	}
+}
+
+// filterAt returns the filter of a run at the given time.
+func (s Schedule) filterAt(at time.Time) *content.Filter {
+	since, _ := s.sinceDuration()
+	if since == 0 {
+		return s.Filter
+	}
+
+	var filter content.Filter
+	if s.Filter != nil {
+		filter = *s.Filter
+	}
+	filter.ModifiedSince = at.Add(-since).UTC().Format(time.RFC3339)
+	return &filter
+}
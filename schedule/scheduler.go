@@ -0,0 +1,134 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/content-exporter/export"
+	"github.com/Financial-Times/go-logger/v2"
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+)
+
+type exportStarter interface {
+	StartExport(isFullExport bool, filter *content.Filter, settings export.JobSettings, tid string) (export.Job, error)
+}
+
+// Status describes a schedule together with its last and next run.
+type Status struct {
+	Schedule
+	NextRun   time.Time  `json:"NextRun"`
+	LastRun   *time.Time `json:"LastRun,omitempty"`
+	LastJobID string     `json:"LastJobID,omitempty"`
+	LastError string     `json:"LastError,omitempty"`
+}
+
+type entry struct {
+	lock   sync.Mutex
+	cron   *Cron
+	status Status
+}
+
+// Scheduler starts the exports of its schedules when their cron expressions match. A run is skipped
+// if another export job is running at that time.
+type Scheduler struct {
+	starter exportStarter
+	entries []*entry
+	log     *logger.UPPLogger
+	now     func() time.Time
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewScheduler(schedules []Schedule, starter exportStarter, log *logger.UPPLogger) (*Scheduler, error) {
+	s := &Scheduler{
+		starter: starter,
+		log:     log,
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+
+	for _, schedule := range schedules {
+		if err := schedule.validate(); err != nil {
+			return nil, err
+		}
+		cron, _ := ParseCron(schedule.Cron)
+		s.entries = append(s.entries, &entry{
+			cron:   cron,
+			status: Status{Schedule: schedule},
+		})
+	}
+	return s, nil
+}
+
+func (s *Scheduler) Start() {
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go s.run(e)
+	}
+}
+
+// Stop stops scheduling new runs. Jobs which have already been started are left running.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Statuses returns the schedules with their last and next runs.
+func (s *Scheduler) Statuses() []Status {
+	statuses := make([]Status, 0, len(s.entries))
+	for _, e := range s.entries {
+		e.lock.Lock()
+		statuses = append(statuses, e.status)
+		e.lock.Unlock()
+	}
+	return statuses
+}
+
+func (s *Scheduler) run(e *entry) {
+	defer s.wg.Done()
+
+	for {
+		next := e.cron.Next(s.now())
+		if next.IsZero() {
+			s.log.Warnf("Schedule %q never runs", e.status.Name)
+			return
+		}
+		e.lock.Lock()
+		e.status.NextRun = next
+		e.lock.Unlock()
+
+		timer := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-timer.C:
+			s.trigger(e, next)
+		case <-s.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// trigger starts the export of a schedule for the run at the given time.
+func (s *Scheduler) trigger(e *entry, at time.Time) {
+	e.lock.Lock()
+	schedule := e.status.Schedule
+	e.lock.Unlock()
+
+	tid := transactionidutils.NewTransactionID()
+	log := s.log.WithTransactionID(tid).WithField("schedule", schedule.Name)
+
+	job, err := s.starter.StartExport(schedule.FullExport, schedule.filterAt(at), schedule.settings(), tid)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.status.LastRun = &at
+	e.status.LastJobID = job.ID
+	e.status.LastError = ""
+	if err != nil {
+		log.WithError(err).Warn("Skipping scheduled export")
+		e.status.LastError = err.Error()
+		return
+	}
+	log.Infof("Started scheduled export job %v", job.ID)
+}
@@ -0,0 +1,233 @@
+package schedule
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/content-exporter/export"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type starterMock struct {
+	startExportF func(isFullExport bool, filter *content.Filter, settings export.JobSettings, tid string) (export.Job, error)
+}
+
+func (s *starterMock) StartExport(isFullExport bool, filter *content.Filter, settings export.JobSettings, tid string) (export.Job, error) {
+	if s.startExportF != nil {
+		return s.startExportF(isFullExport, filter, settings, tid)
+	}
+	panic("starterMock.StartExport is not implemented")
+}
+
+func TestParseSchedules(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		expectedNames []string
+		expectedErr   string
+	}{
+		{
+			name: "test that a YAML list is parsed",
+			data: `
+- name: nightly
+  cron: "0 2 * * *"
+  fullExport: true
+- name: hourly-delta
+  cron: "@hourly"
+  since: 90m
+  filter:
+    contentTypes: [Article]
+`,
+			expectedNames: []string{"nightly", "hourly-delta"},
+		},
+		{
+			name: "test that a YAML object with schedules is parsed",
+			data: `
+schedules:
+  - name: nightly
+    cron: "0 2 * * *"
+    fullExport: true
+`,
+			expectedNames: []string{"nightly"},
+		},
+		{
+			name:          "test that JSON is parsed",
+			data:          `[{"name": "weekly", "cron": "@weekly", "fullExport": true, "workers": 5}]`,
+			expectedNames: []string{"weekly"},
+		},
+		{
+			name:        "test that a schedule without a name results in an error",
+			data:        `[{"cron": "@weekly", "fullExport": true}]`,
+			expectedErr: "schedule with cron expression \"@weekly\" has no name",
+		},
+		{
+			name:        "test that an invalid cron expression results in an error",
+			data:        `[{"name": "broken", "cron": "0 2 * *", "fullExport": true}]`,
+			expectedErr: "schedule \"broken\": cron expression \"0 2 * *\" should have 5 fields",
+		},
+		{
+			name:        "test that an invalid since duration results in an error",
+			data:        `[{"name": "delta", "cron": "@hourly", "since": "yesterday"}]`,
+			expectedErr: "schedule \"delta\": since should be a positive duration like 26h",
+		},
+		{
+			name:        "test that a schedule without anything to export results in an error",
+			data:        `[{"name": "empty", "cron": "@hourly"}]`,
+			expectedErr: "schedule \"empty\" should be a full export or have a filter or a since duration",
+		},
+		{
+			name:        "test that an invalid filter results in an error",
+			data:        `[{"name": "filtered", "cron": "@hourly", "filter": {"publishedFrom": "yesterday"}}]`,
+			expectedErr: "schedule \"filtered\": invalid filter: publishedFrom should be a date like 2024-03-01",
+		},
+		{
+			name:        "test that invalid job settings result in an error",
+			data:        `[{"name": "nightly", "cron": "@daily", "fullExport": true, "workers": 0}]`,
+			expectedErr: "schedule \"nightly\": invalid job settings: workers must be between 1 and 100",
+		},
+		{
+			name: "test that duplicate names result in an error",
+			data: `
+- name: nightly
+  cron: "0 2 * * *"
+  fullExport: true
+- name: nightly
+  cron: "0 3 * * *"
+  fullExport: true
+`,
+			expectedErr: "schedule \"nightly\" is defined more than once",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedules, err := ParseSchedules([]byte(test.data))
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			var names []string
+			for _, s := range schedules {
+				names = append(names, s.Name)
+			}
+			assert.Equal(t, test.expectedNames, names)
+		})
+	}
+}
+
+func TestSchedule_FilterAt(t *testing.T) {
+	at := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	s := Schedule{Name: "full", Cron: "@daily", FullExport: true}
+	assert.Nil(t, s.filterAt(at))
+
+	filter := &content.Filter{ContentTypes: []string{"Article"}}
+	s = Schedule{Name: "delta", Cron: "@daily", Filter: filter, Since: "26h"}
+	assert.Equal(t, &content.Filter{ContentTypes: []string{"Article"}, ModifiedSince: "2024-02-29T00:00:00Z"}, s.filterAt(at))
+	assert.Empty(t, filter.ModifiedSince, "the filter of the schedule should not change")
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	at := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+	workers := 4
+
+	tests := []struct {
+		name              string
+		schedule          Schedule
+		job               export.Job
+		err               error
+		expectedFull      bool
+		expectedFilter    *content.Filter
+		expectedLastJobID string
+		expectedLastError string
+	}{
+		{
+			name:              "test that a full export is started",
+			schedule:          Schedule{Name: "nightly", Cron: "0 2 * * *", FullExport: true, Workers: &workers},
+			job:               export.Job{ID: "job-1"},
+			expectedFull:      true,
+			expectedLastJobID: "job-1",
+		},
+		{
+			name:              "test that a delta export is started with the modified since of the run",
+			schedule:          Schedule{Name: "delta", Cron: "0 2 * * *", Since: "1h"},
+			job:               export.Job{ID: "job-2"},
+			expectedFilter:    &content.Filter{ModifiedSince: "2024-03-01T01:00:00Z"},
+			expectedLastJobID: "job-2",
+		},
+		{
+			name:              "test that a run is skipped while other jobs are running",
+			schedule:          Schedule{Name: "nightly", Cron: "0 2 * * *", FullExport: true, Workers: &workers},
+			err:               fmt.Errorf("There are already running export jobs. Please wait them to finish"),
+			expectedFull:      true,
+			expectedLastError: "There are already running export jobs. Please wait them to finish",
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			starter := &starterMock{
+				startExportF: func(isFullExport bool, filter *content.Filter, settings export.JobSettings, tid string) (export.Job, error) {
+					assert.Equal(t, test.expectedFull, isFullExport)
+					assert.Equal(t, test.expectedFilter, filter)
+					assert.Equal(t, test.schedule.Workers, settings.Workers)
+					assert.NotEmpty(t, tid)
+					return test.job, test.err
+				},
+			}
+			s, err := NewScheduler([]Schedule{test.schedule}, starter, log)
+			assert.NoError(t, err)
+
+			s.trigger(s.entries[0], at)
+
+			statuses := s.Statuses()
+			assert.Len(t, statuses, 1)
+			assert.Equal(t, test.schedule.Name, statuses[0].Name)
+			assert.Equal(t, &at, statuses[0].LastRun)
+			assert.Equal(t, test.expectedLastJobID, statuses[0].LastJobID)
+			assert.Equal(t, test.expectedLastError, statuses[0].LastError)
+		})
+	}
+}
+
+func TestScheduler_StartAndStop(t *testing.T) {
+	started := make(chan string, 1)
+	starter := &starterMock{
+		startExportF: func(isFullExport bool, filter *content.Filter, settings export.JobSettings, tid string) (export.Job, error) {
+			started <- tid
+			return export.Job{ID: "job-1"}, nil
+		},
+	}
+	s, err := NewScheduler([]Schedule{{Name: "minutely", Cron: "* * * * *", FullExport: true}}, starter, logger.NewUPPLogger("test", "PANIC"))
+	assert.NoError(t, err)
+
+	// the clock jumps ahead after the first run is scheduled, so that it is due immediately
+	base := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+	calls := 0
+	s.now = func() time.Time {
+		calls++
+		if calls == 1 {
+			return base
+		}
+		return base.Add(time.Hour)
+	}
+	s.Start()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the scheduled export was not started")
+	}
+	s.Stop()
+
+	statuses := s.Statuses()
+	assert.Equal(t, "job-1", statuses[0].LastJobID)
+	assert.False(t, statuses[0].NextRun.IsZero())
+}
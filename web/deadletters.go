@@ -0,0 +1,67 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Financial-Times/content-exporter/deadletter"
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+type deadLetterReplayer interface {
+	DeadLetters() []deadletter.Letter
+	Replay(ids ...string) int
+}
+
+type DeadLetterHandler struct {
+	replayer deadLetterReplayer
+	log      *logger.UPPLogger
+}
+
+func NewDeadLetterHandler(replayer deadLetterReplayer, log *logger.UPPLogger) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		replayer: replayer,
+		log:      log,
+	}
+}
+
+// GetDeadLetters returns the notifications of the incremental export which failed, the oldest first.
+func (h *DeadLetterHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	letters := h.replayer.DeadLetters()
+
+	w.Header().Add("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(letters)
+	if err != nil {
+		h.log.WithError(err).Warn("Failed to marshal dead letters")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ReplayDeadLetters hands the dead letters with the ids in the JSON body, or all of them without a body,
+// back to the notification handler.
+func (h *DeadLetterHandler) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IDs []string `json:"ids"`
+	}
+	if r.Body != nil {
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil && !errors.Is(err, io.EOF) {
+			h.log.WithError(err).Warn("Failed to decode dead letter ids")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "Pass the ids of the dead letters as a JSON object"})
+			return
+		}
+	}
+
+	replayed := h.replayer.Replay(body.IDs...)
+	h.log.Infof("Replaying %v dead letter(s)", replayed)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]int{"Replayed": replayed}); err != nil {
+		h.log.WithError(err).Warn("Failed to write replay response")
+	}
+}
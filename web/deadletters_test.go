@@ -0,0 +1,107 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/content-exporter/deadletter"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type replayerMock struct {
+	letters  []deadletter.Letter
+	replayed []string
+}
+
+func (m *replayerMock) DeadLetters() []deadletter.Letter {
+	return m.letters
+}
+
+func (m *replayerMock) Replay(ids ...string) int {
+	m.replayed = ids
+	if len(ids) == 0 {
+		return len(m.letters)
+	}
+	return len(ids)
+}
+
+func TestDeadLetterHandler_GetDeadLetters(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	replayer := &replayerMock{
+		letters: []deadletter.Letter{
+			{
+				ID:            "letter-a",
+				Stage:         deadletter.HandlingStage,
+				EventType:     "UPDATE",
+				TransactionID: "tid_1234",
+				Stub:          content.Stub{UUID: "uuid-a", Date: "2024-03-01"},
+				Error:         "exporting content: upload failed",
+				FailedAt:      time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	h := NewDeadLetterHandler(replayer, log)
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	req, _ := http.NewRequest("GET", "/deadletters", nil)
+
+	r.HandleFunc("/deadletters", h.GetDeadLetters).Methods("GET")
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[{\"ID\":\"letter-a\",\"Stage\":\"handling\",\"EventType\":\"UPDATE\",\"TransactionID\":\"tid_1234\",\"Stub\":{\"UUID\":\"uuid-a\",\"Date\":\"2024-03-01\",\"ContentType\":\"\",\"CanBeDistributed\":\"\",\"Publication\":null,\"EditorialDesk\":\"\",\"LastModified\":\"\"},\"Error\":\"exporting content: upload failed\",\"FailedAt\":\"2024-03-01T10:00:00Z\"}]\n", rr.Body.String())
+}
+
+func TestDeadLetterHandler_ReplayDeadLetters(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		expectedReplayed []string
+		expectedBody     string
+		expectedStatus   int
+	}{
+		{
+			name:           "test that all dead letters are replayed without a body",
+			expectedBody:   "{\"Replayed\":2}\n",
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:             "test that the passed dead letters are replayed",
+			body:             `{"ids": ["letter-b"]}`,
+			expectedReplayed: []string{"letter-b"},
+			expectedBody:     "{\"Replayed\":1}\n",
+			expectedStatus:   http.StatusAccepted,
+		},
+		{
+			name:           "test that an invalid body results in an error",
+			body:           `letter-b`,
+			expectedBody:   "{\"error\":\"Pass the ids of the dead letters as a JSON object\"}\n",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			replayer := &replayerMock{letters: []deadletter.Letter{{ID: "letter-a"}, {ID: "letter-b"}}}
+			h := NewDeadLetterHandler(replayer, log)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("POST", "/deadletters/replay", strings.NewReader(test.body))
+
+			r.HandleFunc("/deadletters/replay", h.ReplayDeadLetters).Methods("POST")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			assert.Equal(t, test.expectedBody, rr.Body.String())
+			assert.Equal(t, test.expectedReplayed, replayer.replayed)
+		})
+	}
+}
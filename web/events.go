@@ -0,0 +1,82 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/export"
+	"github.com/gorilla/mux"
+)
+
+// keepAliveInterval is how often a comment is sent to keep an idle event stream open.
+var keepAliveInterval = 15 * time.Second
+
+// GetJobEvents streams the progress, failure and state events of a job as server-sent events.
+// The stream starts with the current state of the job and ends once the job has ended.
+func (h *RequestHandler) GetJobEvents(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobID"]
+	log := h.log.WithField("jobID", jobID)
+
+	events, unsubscribe, err := h.fullExporter.Subscribe(jobID)
+	if err != nil {
+		log.WithError(err).Warn("Failed to subscribe to job events")
+
+		if errors.Is(err, export.ErrJobNotFound) {
+			h.sendErrorResponse(w, http.StatusNotFound, "Job not found")
+		} else {
+			h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to subscribe to job events")
+		}
+		return
+	}
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	// The stream lasts as long as the job, which is usually longer than the write timeout of the server
+	if err = rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.WithError(err).Warn("Failed to clear the write deadline of the job events")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err = writeEvent(w, event); err != nil {
+				log.WithError(err).Warn("Failed to write job event")
+				return
+			}
+		case <-keepAlive.C:
+			if _, err = fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+
+		if err = rc.Flush(); err != nil {
+			log.WithError(err).Warn("Failed to flush job events")
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event export.Event) error {
+	data, err := json.Marshal(event.Data())
+	if err != nil {
+		return fmt.Errorf("marshaling %s event: %w", event.Type, err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/content-exporter/export"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestHandler_GetJobEvents(t *testing.T) {
+	tests := []struct {
+		name           string
+		events         []export.Event
+		err            error
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name: "test that the events of a job are streamed until it ends",
+			events: []export.Event{
+				{Type: export.StateEvent, Job: &export.Job{ID: "some-job", Status: export.RUNNING}},
+				{Type: export.FailureEvent, Failure: &export.Failure{UUID: "uuid-a", Stage: "fetch", StatusCode: 404, Error: "not found"}},
+				{Type: export.ProgressEvent, Job: &export.Job{ID: "some-job", Progress: 2, Status: export.RUNNING}},
+				{Type: export.StateEvent, Job: &export.Job{ID: "some-job", Progress: 2, Status: export.FINISHED}},
+			},
+			expectedBody: "event: state\ndata: {\"ID\":\"some-job\",\"Status\":\"Running\"}\n\n" +
+				"event: failure\ndata: {\"UUID\":\"uuid-a\",\"Stage\":\"fetch\",\"StatusCode\":404,\"Error\":\"not found\"}\n\n" +
+				"event: progress\ndata: {\"ID\":\"some-job\",\"Progress\":2,\"Status\":\"Running\"}\n\n" +
+				"event: state\ndata: {\"ID\":\"some-job\",\"Progress\":2,\"Status\":\"Finished\"}\n\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test that an unknown job results in not found",
+			err:            export.ErrJobNotFound,
+			expectedBody:   "{\"error\":\"Job not found\"}",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "test that an unexpected error results in an internal server error",
+			err:            fmt.Errorf("unexpected"),
+			expectedBody:   "{\"error\":\"Failed to subscribe to job events\"}",
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unsubscribed := false
+			exporter := &exporterMock{
+				subscribeF: func(jobID string) (<-chan export.Event, func(), error) {
+					assert.Equal(t, "some-job", jobID)
+					if test.err != nil {
+						return nil, nil, test.err
+					}
+					events := make(chan export.Event, len(test.events))
+					for _, e := range test.events {
+						events <- e
+					}
+					close(events)
+					return events, func() { unsubscribed = true }, nil
+				},
+			}
+			h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("GET", "/jobs/some-job/events", nil)
+
+			r.HandleFunc("/jobs/{jobID}/events", h.GetJobEvents).Methods("GET")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			assert.Equal(t, test.expectedBody, rr.Body.String())
+			if test.err == nil {
+				assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+				assert.True(t, unsubscribed)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/content-exporter/export"
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/gorilla/mux"
+)
+
+// Reconcile starts a job comparing the content in Mongo with the objects in S3. The S3 listing is read from
+// the request body if there is one, otherwise the job requests it from the S3 writer once it starts.
+// With fix=true the job exports missing and stale content again and deletes orphaned objects.
+func (h *RequestHandler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	settings, err := getJobSettings(r)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid job settings")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	priority, err := getPriority(r)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid job priority")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	workers := h.fullExporter.GetWorkerCount()
+	if settings.Workers != nil {
+		workers = *settings.Workers
+	}
+	throttle := h.contentRetrievalThrottle
+	if settings.Throttle != nil {
+		throttle = *settings.Throttle
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	listing, err := readS3Listing(r)
+	if err != nil {
+		h.log.WithTransactionID(tid).WithError(err).Warn("Failed to read S3 listing")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if listing == nil && h.lister == nil {
+		h.sendErrorResponse(w, http.StatusBadRequest, "Pass the S3 listing in the request body, no S3 listing URL is configured")
+		return
+	}
+
+	// Only a reconciliation which fixes the discrepancies writes to S3
+	fix := r.URL.Query().Get("fix") == "true"
+	if fix && !h.acquireLock(w) {
+		return
+	}
+
+	job := export.NewReconcileJob(workers, throttle, fix, listing, h.log)
+	job.TransactionID = tid
+	job.Priority = priority
+	h.fullExporter.AddJob(job)
+	h.fullExporter.Enqueue(job)
+	accepted := job.Copy()
+
+	go h.startReconcile(job, fix, listing == nil, tid)
+
+	h.sendJobAccepted(w, accepted, nil)
+}
+
+// readS3Listing reads the S3 listing from the request body. It returns a nil listing for an empty body.
+func readS3Listing(r *http.Request) (content.S3Listing, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body := bufio.NewReader(r.Body)
+	if _, err := body.Peek(1); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+	return content.ReadS3Listing(body)
+}
+
+// listS3 requests the S3 listing from the S3 writer.
+func (h *RequestHandler) listS3(ctx context.Context, tid string) (content.S3Listing, error) {
+	listing, err := h.lister.List(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+	defer listing.Close()
+	return content.ReadS3Listing(listing)
+}
+
+// startReconcile runs a reconciliation once its turn comes. Without a listing in the request the listing
+// is requested first, as reading the listing of the whole bucket takes too long to be done in the request.
+func (h *RequestHandler) startReconcile(job *export.Job, fix, requestListing bool, tid string) {
+	if fix {
+		defer h.releaseLock()
+	}
+	if !h.fullExporter.WaitTurn(job) {
+		return
+	}
+
+	log := h.log.WithTransactionID(tid)
+	if requestListing {
+		log.Info("Requesting the S3 listing for a reconciliation")
+		listing, err := h.listS3(job.Context(), tid)
+		if err != nil {
+			msg := "Failed to list S3 content"
+			if errors.Is(err, content.ErrListingNotConfigured) {
+				msg = "No S3 listing URL is configured"
+			}
+			log.WithError(err).Warn(msg)
+			job.Fail(msg)
+			return
+		}
+		job.SetListing(listing)
+	}
+	log.Info("Calling mongo for a reconciliation")
+
+	docs, count, err := h.inquirer.Inquire(job.Context(), nil, nil, "")
+	if err != nil {
+		msg := "Failed to read content from mongo"
+		log.WithError(err).Warn(msg)
+		job.Fail(msg)
+		return
+	}
+	log.Infof("Number of UUIDs found: %v", count)
+	job.SetCount(count)
+
+	// Missing and stale content has to be uploaded even if it is unchanged since its last upload
+	job.RunReconcile(tid, docs, h.fullExporter.ForceExport, h.fullExporter.Delete)
+}
+
+// GetReconciliation downloads the UUIDs of a kind of discrepancy found by a reconciliation as a newline separated list.
+func (h *RequestHandler) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["jobID"]
+	discrepancy := export.Discrepancy(vars["discrepancy"])
+
+	uuids, err := h.fullExporter.GetReconciliation(jobID, discrepancy)
+	if err != nil {
+		h.log.
+			WithField("jobID", jobID).
+			WithError(err).
+			Warn("Failed to retrieve reconciliation")
+
+		switch {
+		case errors.Is(err, export.ErrJobNotFound):
+			h.sendErrorResponse(w, http.StatusNotFound, "Job not found")
+		case errors.Is(err, export.ErrNotReconciliation):
+			h.sendErrorResponse(w, http.StatusConflict, "Job is not a reconciliation")
+		default:
+			h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve reconciliation")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", jobID+"-"+string(discrepancy)+".txt"))
+	for _, uuid := range uuids {
+		fmt.Fprintln(w, uuid)
+	}
+}
@@ -0,0 +1,199 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/content-exporter/export"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type listerMock struct {
+	listF func(tid string) (io.ReadCloser, error)
+}
+
+func (l *listerMock) List(_ context.Context, tid string) (io.ReadCloser, error) {
+	if l.listF != nil {
+		return l.listF(tid)
+	}
+	panic("listerMock.List is not implemented")
+}
+
+func TestRequestHandler_Reconcile(t *testing.T) {
+	emptyInquirer := &inquirerMock{
+		inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+			c := make(chan *content.Stub)
+			close(c)
+			return content.NewStream(c), 0, nil
+		},
+	}
+
+	tests := []struct {
+		name           string
+		lister         s3Lister
+		body           string
+		query          string
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name:           "test that a listing in the body starts a reconciliation",
+			body:           "2024-03-01/811e0591-5c71-4457-b8eb-8c22cf093117 2024-03-01T10:00:00Z\n",
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "test that the listing is requested from the S3 writer without a body",
+			lister: &listerMock{
+				listF: func(tid string) (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader("2024-03-01/811e0591-5c71-4457-b8eb-8c22cf093117\n")), nil
+				},
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "test that a reconciliation without a body or a listing URL results in an error",
+			expectedBody:   "{\"error\":\"Pass the S3 listing in the request body, no S3 listing URL is configured\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "test that invalid job settings result in an error",
+			body:           "2024-03-01/811e0591-5c71-4457-b8eb-8c22cf093117\n",
+			query:          "?workers=0",
+			expectedBody:   "{\"error\":\"invalid job settings: workers must be between 1 and 100\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "test that a fixing reconciliation is started",
+			body:           "2024-03-01/811e0591-5c71-4457-b8eb-8c22cf093117\n",
+			query:          "?fix=true",
+			expectedStatus: http.StatusAccepted,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+				getWorkerCountF: func() int {
+					return 1
+				},
+				deleteF: func(uuid, tid string) error {
+					return nil
+				},
+			}
+			h := NewRequestHandler(exporter, emptyInquirer, export.NewLocker(), false, 0, log, nil, 0, test.lister, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("POST", "/reconcile"+test.query, strings.NewReader(test.body))
+
+			r.HandleFunc("/reconcile", h.Reconcile).Methods("POST")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestHandler_ReconcileFailsTheJobWhenTheS3ListingFails(t *testing.T) {
+	lister := &listerMock{
+		listF: func(tid string) (io.ReadCloser, error) {
+			return nil, &content.UnexpectedStatusError{Operation: "listing content", StatusCode: http.StatusBadGateway}
+		},
+	}
+	jobs := make(chan *export.Job, 1)
+	exporter := &exporterMock{
+		getWorkerCountF: func() int {
+			return 1
+		},
+		enqueueF: func(job *export.Job) {
+			jobs <- job
+		},
+	}
+	h := NewRequestHandler(exporter, nil, export.NewLocker(), false, 0, logger.NewUPPLogger("test", "PANIC"), nil, 0, lister, nil)
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/reconcile", nil)
+
+	h.Reconcile(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code, "the listing should be requested once the job is accepted")
+	job := <-jobs
+	assert.Eventually(t, func() bool {
+		return job.Copy().Status == export.FINISHED
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "Failed to list S3 content", job.Copy().ErrorMessage)
+}
+
+func TestRequestHandler_GetReconciliation(t *testing.T) {
+	tests := []struct {
+		name           string
+		uuids          []string
+		err            error
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name:           "test that the missing uuids are downloaded",
+			uuids:          []string{"uuid-a", "uuid-b"},
+			expectedBody:   "uuid-a\nuuid-b\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test that an unknown job results in not found",
+			err:            export.ErrJobNotFound,
+			expectedBody:   "{\"error\":\"Job not found\"}",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "test that a job which is not a reconciliation results in a conflict",
+			err:            export.ErrNotReconciliation,
+			expectedBody:   "{\"error\":\"Job is not a reconciliation\"}",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "test that an unexpected error results in an internal server error",
+			err:            fmt.Errorf("unexpected"),
+			expectedBody:   "{\"error\":\"Failed to retrieve reconciliation\"}",
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &exporterMock{
+				getReconcileF: func(jobID string, discrepancy export.Discrepancy) ([]string, error) {
+					assert.Equal(t, "some-job", jobID)
+					assert.Equal(t, export.Missing, discrepancy)
+					return test.uuids, test.err
+				},
+			}
+			h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("GET", "/jobs/some-job/reconciliation/missing", nil)
+
+			r.HandleFunc("/jobs/{jobID}/reconciliation/{discrepancy:missing|stale|orphaned}", h.GetReconciliation).Methods("GET")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			assert.Equal(t, test.expectedBody, rr.Body.String())
+		})
+	}
+}
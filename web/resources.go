@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Financial-Times/content-exporter/content"
@@ -19,21 +21,48 @@ import (
 )
 
 const (
-	targetedExportTimeout = 30 * time.Second
-	fullExportTimeout     = 120 * time.Second
-	dateFormat            = "2006-01-02"
+	dateFormat = "2006-01-02"
+)
+
+// ErrFullExportRunning is returned when a recurring export is started while another full export is queued or running.
+var ErrFullExportRunning = errors.New("a full export is already queued or running")
+
+var (
+	errLockTimeout      = errors.New("lock initiation timed out")
+	errKafkaStopTimeout = errors.New("stopping kafka consumption timed out")
 )
 
 type exporter interface {
 	GetJob(jobID string) (export.Job, error)
 	GetRunningJobs() []export.Job
+	ListJobs(states []export.State, limit int) []export.Job
 	AddJob(job *export.Job)
+	AddJobUnlessFullExportRunning(job *export.Job) bool
+	Enqueue(job *export.Job)
+	WaitTurn(job *export.Job) bool
+	CheckResumable(jobID string) error
+	ResumeJob(jobID string, contentRetrievalThrottle int) (*export.Job, error)
+	CancelJob(jobID string) error
+	PauseJob(jobID string) error
+	UnpauseJob(jobID string) error
+	UpdateJob(jobID string, settings export.JobSettings) (export.Job, error)
+	CheckRetryable(jobID string) error
+	RetryJob(jobID string, contentRetrievalThrottle int, tid string) (*export.Job, []string, error)
 	Export(tid string, doc *content.Stub) error
+	ForceExport(tid string, doc *content.Stub) error
+	GetJobUUIDs(jobID string) ([]string, error)
+	Subscribe(jobID string) (<-chan export.Event, func(), error)
+	GetReconciliation(jobID string, discrepancy export.Discrepancy) ([]string, error)
+	Delete(uuid, tid string) error
 	GetWorkerCount() int
 }
 
 type inquirer interface {
-	Inquire(ctx context.Context, candidates []string) (chan *content.Stub, int, error)
+	Inquire(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error)
+}
+
+type s3Lister interface {
+	List(ctx context.Context, tid string) (io.ReadCloser, error)
 }
 
 type RequestHandler struct {
@@ -45,9 +74,14 @@ type RequestHandler struct {
 	log                      *logger.UPPLogger
 	ea                       *ecsarchive.ECSArchive
 	rangeInHours             int
+	lister                   s3Lister
+	notifier                 notifier
+	lockMu                   sync.Mutex
+	lockHolders              int
+	lockChange               chan struct{}
 }
 
-func NewRequestHandler(fullExporter exporter, inquirer inquirer, locker *export.Locker, isIncExportEnabled bool, contentRetrievalThrottle int, log *logger.UPPLogger, ea *ecsarchive.ECSArchive, rangeInHours int) *RequestHandler {
+func NewRequestHandler(fullExporter exporter, inquirer inquirer, locker *export.Locker, isIncExportEnabled bool, contentRetrievalThrottle int, log *logger.UPPLogger, ea *ecsarchive.ECSArchive, rangeInHours int, lister s3Lister, notifier notifier) *RequestHandler {
 	return &RequestHandler{
 		fullExporter:             fullExporter,
 		inquirer:                 inquirer,
@@ -57,6 +91,8 @@ func NewRequestHandler(fullExporter exporter, inquirer inquirer, locker *export.
 		log:                      log,
 		ea:                       ea,
 		rangeInHours:             rangeInHours,
+		lister:                   lister,
+		notifier:                 notifier,
 	}
 }
 
@@ -90,10 +126,23 @@ func (h *RequestHandler) GenerateArticlesZipS3(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	callbackURL, err := h.getCallback(r)
+	if err != nil {
+		log.WithError(err).Warn("Invalid callback")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	key := startDate.Format(dateFormat) + "-" + endDate.Format(dateFormat) + ".zip"
 	// We expect OutputArchive to return error (archive does not exist) in order to proceed
 	output, err := h.ea.OutputArchive(key)
 	if err == nil {
+		// The archive is generated once, so its end wouldn't be notified to this callback
+		if callbackURL != "" {
+			log.Warn("Callback for an existing archive")
+			h.sendErrorResponse(w, http.StatusConflict, "The archive is already being generated or has been generated, request it without a callback")
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "%s", output)
 		w.Header().Set("Content-Type", "application/json")
@@ -101,71 +150,378 @@ func (h *RequestHandler) GenerateArticlesZipS3(w http.ResponseWriter, r *http.Re
 	}
 
 	log.Infof("Start creating the archive... %s %s", startDate, endDate)
-	go h.ea.GenerateArchiveS3(startDate.Format(dateFormat), endDate.Format(dateFormat), tid, log)
+	go func() {
+		url, err := h.ea.GenerateArchiveS3(startDate.Format(dateFormat), endDate.Format(dateFormat), tid, log)
+		h.notifyArchiveEnded(callbackURL, key, url, err, tid)
+	}()
 
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 }
 
 func (h *RequestHandler) Export(w http.ResponseWriter, r *http.Request) {
-	jobs := h.fullExporter.GetRunningJobs()
-	if len(jobs) > 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "There are already running export jobs. Please wait them to finish")
-		return
-	}
-
 	isFullExport := r.URL.Query().Get("fullExport") == "true"
 
-	candidates, err := getCandidateUUIDs(r)
+	body, err := readExportBody(r)
 	if err != nil {
-		if !isFullExport {
-			h.log.WithError(err).Warn("Can't trigger a non-full export without ids")
-			h.sendErrorResponse(w, http.StatusBadRequest, "Pass a list of ids or trigger a full export flag")
-			return
-		}
-	} else if isFullExport {
+		h.log.WithError(err).Warn("Invalid export request body")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter, err := withSince(body.filter, r.URL.Query().Get("since"))
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid since parameter")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	callbackURL, err := h.getCallback(r)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid callback")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch {
+	case body.hasIDs() && filter != nil:
+		h.log.Warn("Can't trigger an export with both ids and a filter")
+		h.sendErrorResponse(w, http.StatusBadRequest, "Pass either a list of ids or a filter, not both")
+		return
+	case body.hasIDs() && isFullExport:
 		h.log.Warn("Can't trigger a full export with ids")
 		h.sendErrorResponse(w, http.StatusBadRequest, "Pass either a list of ids or the full export flag, not both")
 		return
+	case !body.hasIDs() && filter == nil && !isFullExport:
+		h.log.Warn("Can't trigger a non-full export without ids")
+		h.sendErrorResponse(w, http.StatusBadRequest, "Pass a list of ids, a filter or trigger a full export flag")
+		return
+	case body.hasIDs() && len(body.candidates) == 0:
+		// Inquiring without candidates would select every document
+		h.log.Warnf("None of the %v passed ids is a valid UUID", len(body.rejected))
+		h.sendRejectedResponse(w, body)
+		return
+	}
+	candidates := body.candidates
+	if len(body.rejected) != 0 {
+		h.log.Warnf("Skipping %v invalid id(s)", len(body.rejected))
+	}
+	// A filtered export goes through the DB like a full export does, so it can be resumed the same way
+	if filter != nil {
+		isFullExport = true
 	}
 
-	if h.isIncExportEnabled {
-		select {
-		case h.locker.Locked <- true:
-			h.log.Info("Lock initiated")
-		case <-time.After(time.Second * 3):
-			msg := "Lock initiation timed out"
-			h.log.Info(msg)
-			h.sendErrorResponse(w, http.StatusServiceUnavailable, msg)
-			return
-		}
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
 
-		select {
-		case <-h.locker.Acked:
-			h.log.Info("Locker acquired")
-		case <-time.After(time.Second * 20):
-			msg := "Stopping kafka consumption timed out"
-			h.log.Info(msg)
-			h.sendErrorResponse(w, http.StatusServiceUnavailable, msg)
-			return
+	if r.URL.Query().Get("dryRun") == "true" {
+		job := export.NewDryRunJob(isFullExport, r.URL.Query().Get("listUUIDs") == "true", h.log)
+		job.Filter = filter
+		job.TransactionID = tid
+		job.SetCallbackURL(callbackURL)
+		h.fullExporter.AddJob(job)
+		accepted := job.Copy()
+
+		go h.startDryRun(job, candidates, tid)
+
+		h.sendJobAccepted(w, accepted, body.rejected)
+		return
+	}
+
+	settings, err := getJobSettings(r)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid job settings")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	priority, err := getPriority(r)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid job priority")
+		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	accepted, err := h.startJob(isFullExport, candidates, filter, settings, priority, callbackURL, tid)
+	if err != nil {
+		h.sendErrorResponse(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	h.sendJobAccepted(w, accepted, body.rejected)
+}
+
+// StartExport queues a full or filtered export unless another full export is already queued or running.
+// It is used to start the recurring exports of the scheduler.
+func (h *RequestHandler) StartExport(isFullExport bool, filter *content.Filter, settings export.JobSettings, tid string) (export.Job, error) {
+	if err := settings.Validate(); err != nil {
+		return export.Job{}, err
+	}
+	if filter != nil {
+		if err := filter.Validate(); err != nil {
+			return export.Job{}, fmt.Errorf("invalid filter: %w", err)
 		}
+		isFullExport = true
+	}
+	if !isFullExport {
+		return export.Job{}, fmt.Errorf("only full and filtered exports can be started without ids")
 	}
 
-	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	if err := h.lock(); err != nil {
+		return export.Job{}, err
+	}
+	job := h.newJob(isFullExport, filter, settings, tid)
+	if !h.fullExporter.AddJobUnlessFullExportRunning(job) {
+		h.releaseLock()
+		return export.Job{}, ErrFullExportRunning
+	}
+	return h.queueJob(job, nil, tid), nil
+}
 
-	job := export.NewJob(h.fullExporter.GetWorkerCount(), h.contentRetrievalThrottle, isFullExport, h.log)
+// startJob creates an export job and queues it once the incremental export is paused. Without a priority
+// the queue picks one for the job. The optional callback URL is notified when the job ends.
+func (h *RequestHandler) startJob(isFullExport bool, candidates []string, filter *content.Filter, settings export.JobSettings, priority export.Priority, callbackURL, tid string) (export.Job, error) {
+	if err := h.lock(); err != nil {
+		return export.Job{}, err
+	}
+
+	job := h.newJob(isFullExport, filter, settings, tid)
+	job.Priority = priority
+	job.SetCallbackURL(callbackURL)
 	h.fullExporter.AddJob(job)
-	response := map[string]string{
+	return h.queueJob(job, candidates, tid), nil
+}
+
+// newJob creates an export job with the given settings, or the configured ones where they are not set.
+func (h *RequestHandler) newJob(isFullExport bool, filter *content.Filter, settings export.JobSettings, tid string) *export.Job {
+	workers := h.fullExporter.GetWorkerCount()
+	if settings.Workers != nil {
+		workers = *settings.Workers
+	}
+	throttle := h.contentRetrievalThrottle
+	if settings.Throttle != nil {
+		throttle = *settings.Throttle
+	}
+
+	job := export.NewJob(workers, throttle, isFullExport, h.log)
+	job.Filter = filter
+	job.TransactionID = tid
+	return job
+}
+
+// queueJob queues an added job and exports its documents in the background once its turn comes.
+func (h *RequestHandler) queueJob(job *export.Job, candidates []string, tid string) export.Job {
+	h.fullExporter.Enqueue(job)
+	accepted := job.Copy()
+
+	go h.startExport(job, candidates, "", tid)
+
+	return accepted
+}
+
+// ResumeJob continues a paused job, or an interrupted full export from the last checkpoint of the job.
+func (h *RequestHandler) ResumeJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobID"]
+	log := h.log.WithField("jobID", jobID)
+
+	err := h.fullExporter.UnpauseJob(jobID)
+	if err == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if !errors.Is(err, export.ErrJobNotPaused) {
+		h.sendResumeError(w, log, err)
+		return
+	}
+
+	// The incremental export is only paused for a job which can be resumed
+	if err = h.fullExporter.CheckResumable(jobID); err != nil {
+		h.sendResumeError(w, log, err)
+		return
+	}
+	if !h.acquireLock(w) {
+		return
+	}
+
+	job, err := h.fullExporter.ResumeJob(jobID, h.contentRetrievalThrottle)
+	if err != nil {
+		h.releaseLock()
+		h.sendResumeError(w, log, err)
+		return
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	after := job.CheckpointUUID()
+	log.Infof("Resuming job after UUID %q", after)
+	h.fullExporter.Enqueue(job)
+	accepted := job.Copy()
+
+	go h.startExport(job, nil, after, tid)
+
+	h.sendJobAccepted(w, accepted, nil)
+}
+
+// RetryJob starts a targeted export of the documents which failed in the given job.
+func (h *RequestHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobID"]
+	log := h.log.WithField("jobID", jobID)
+
+	// The incremental export is only paused for a job which can be retried
+	if err := h.fullExporter.CheckRetryable(jobID); err != nil {
+		h.sendRetryError(w, log, err)
+		return
+	}
+	if !h.acquireLock(w) {
+		return
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	job, failed, err := h.fullExporter.RetryJob(jobID, h.contentRetrievalThrottle, tid)
+	if err != nil {
+		h.releaseLock()
+		h.sendRetryError(w, log, err)
+		return
+	}
+
+	log.Infof("Retrying %v failed document(s) in job %v", len(failed), job.ID)
+	h.fullExporter.Enqueue(job)
+	accepted := job.Copy()
+
+	go h.startExport(job, failed, "", tid)
+
+	h.sendJobAccepted(w, accepted, nil)
+}
+
+func (h *RequestHandler) sendResumeError(w http.ResponseWriter, log *logger.LogEntry, err error) {
+	log.WithError(err).Warn("Failed to resume job")
+
+	switch {
+	case errors.Is(err, export.ErrJobNotFound):
+		h.sendErrorResponse(w, http.StatusNotFound, "Job not found")
+	case errors.Is(err, export.ErrJobNotResumable):
+		h.sendErrorResponse(w, http.StatusConflict, "Only paused jobs or interrupted full export jobs can be resumed")
+	default:
+		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to resume job")
+	}
+}
+
+func (h *RequestHandler) sendRetryError(w http.ResponseWriter, log *logger.LogEntry, err error) {
+	log.WithError(err).Warn("Failed to retry job")
+
+	switch {
+	case errors.Is(err, export.ErrJobNotFound):
+		h.sendErrorResponse(w, http.StatusNotFound, "Job not found")
+	case errors.Is(err, export.ErrJobNotRetryable):
+		h.sendErrorResponse(w, http.StatusConflict, "Only finished jobs with failures can be retried")
+	default:
+		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retry job")
+	}
+}
+
+// acquireLock pauses the incremental export for the duration of a full or targeted export, including the time
+// the export is queued. On failure the error response is already written.
+func (h *RequestHandler) acquireLock(w http.ResponseWriter) bool {
+	if err := h.lock(); err != nil {
+		h.sendErrorResponse(w, http.StatusServiceUnavailable, err.Error())
+		return false
+	}
+	return true
+}
+
+// lock pauses the incremental export unless it is already paused for another job. It stays paused
+// until every job which locked it has released the lock.
+func (h *RequestHandler) lock() error {
+	if !h.isIncExportEnabled {
+		return nil
+	}
+
+	h.lockMu.Lock()
+	defer h.lockMu.Unlock()
+	h.awaitLockChange()
+	if h.lockHolders == 0 {
+		if err := h.changeLock(h.pauseIncrementalExport); err != nil {
+			return err
+		}
+	}
+	h.lockHolders++
+	return nil
+}
+
+func (h *RequestHandler) releaseLock() {
+	if !h.isIncExportEnabled {
+		return
+	}
+
+	h.lockMu.Lock()
+	defer h.lockMu.Unlock()
+	h.awaitLockChange()
+	h.lockHolders--
+	if h.lockHolders == 0 {
+		_ = h.changeLock(func() error {
+			h.log.Info("Locker released")
+			h.locker.Locked <- false
+			return nil
+		})
+	}
+}
+
+// awaitLockChange waits until the incremental export is neither being paused nor resumed.
+// It is called holding lockMu, which it releases while waiting.
+func (h *RequestHandler) awaitLockChange() {
+	for h.lockChange != nil {
+		change := h.lockChange
+		h.lockMu.Unlock()
+		<-change
+		h.lockMu.Lock()
+	}
+}
+
+// changeLock pauses or resumes the incremental export without holding lockMu, which it is called with.
+// Other callers of lock and releaseLock wait until the change is done.
+func (h *RequestHandler) changeLock(change func() error) error {
+	done := make(chan struct{})
+	h.lockChange = done
+	h.lockMu.Unlock()
+	defer func() {
+		h.lockMu.Lock()
+		h.lockChange = nil
+		close(done)
+	}()
+	return change()
+}
+
+// pauseIncrementalExport asks the listener to stop handling notifications and waits until it has.
+func (h *RequestHandler) pauseIncrementalExport() error {
+	select {
+	case h.locker.Locked <- true:
+		h.log.Info("Lock initiated")
+	case <-time.After(time.Second * 3):
+		h.log.Info(errLockTimeout.Error())
+		return errLockTimeout
+	}
+
+	select {
+	case <-h.locker.Acked:
+		h.log.Info("Locker acquired")
+	case <-time.After(time.Second * 20):
+		h.log.Info(errKafkaStopTimeout.Error())
+		return errKafkaStopTimeout
+	}
+	return nil
+}
+
+// sendJobAccepted reports the newly created job together with the ids which were rejected as invalid, if any.
+func (h *RequestHandler) sendJobAccepted(w http.ResponseWriter, job export.Job, rejected []string) {
+	response := map[string]interface{}{
 		"ID":     job.ID,
 		"Status": string(job.Status),
 	}
-
-	go h.startExport(job, isFullExport, candidates, tid)
+	if len(rejected) != 0 {
+		response["RejectedCount"] = len(rejected)
+		response["Rejected"] = reportedRejected(rejected)
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 
-	err = json.NewEncoder(w).Encode(response)
+	err := json.NewEncoder(w).Encode(response)
 	if err != nil {
 		msg := fmt.Sprintf("Failed to parse response for new job with ID: %s", job.ID)
 		h.log.WithError(err).Warn(msg)
@@ -173,37 +529,61 @@ func (h *RequestHandler) Export(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *RequestHandler) startExport(job *export.Job, isFullExport bool, candidates []string, tid string) {
-	if h.isIncExportEnabled {
-		defer func() {
-			h.log.Info("Locker released")
-			h.locker.Locked <- false
-		}()
+func (h *RequestHandler) sendRejectedResponse(w http.ResponseWriter, body exportBody) {
+	response := map[string]interface{}{
+		"error":         "None of the passed ids is a valid UUID",
+		"RejectedCount": len(body.rejected),
+		"Rejected":      reportedRejected(body.rejected),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.log.WithError(err).Warn("Failed to write rejected ids response")
+	}
+}
+
+func (h *RequestHandler) startExport(job *export.Job, candidates []string, after string, tid string) {
+	defer h.notifyJobEnded(job, tid)
+	defer h.releaseLock()
+
+	if !h.fullExporter.WaitTurn(job) {
+		return
 	}
 
 	log := h.log.WithTransactionID(tid)
 	log.Info("Calling mongo")
 
-	timeout := targetedExportTimeout
-	if isFullExport {
-		timeout = fullExportTimeout
+	docs, count, err := h.inquirer.Inquire(job.Context(), candidates, job.Filter, after)
+	if err != nil {
+		msg := "Failed to read content from mongo"
+		log.WithError(err).Warn(msg)
+		job.Fail(msg)
+		return
 	}
+	log.Infof("Number of UUIDs found: %v", count)
+	job.SetCount(count)
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	job.RunExport(tid, docs, h.fullExporter.Export)
+}
 
-	docs, count, err := h.inquirer.Inquire(ctx, candidates)
+// startDryRun counts the documents an export would select. Nothing is written, so the incremental export keeps running.
+func (h *RequestHandler) startDryRun(job *export.Job, candidates []string, tid string) {
+	defer h.notifyJobEnded(job, tid)
+	log := h.log.WithTransactionID(tid)
+	log.Info("Calling mongo for a dry run")
+
+	docs, count, err := h.inquirer.Inquire(job.Context(), candidates, job.Filter, "")
 	if err != nil {
 		msg := "Failed to read content from mongo"
 		log.WithError(err).Warn(msg)
-		job.ErrorMessage = msg
-		job.Status = export.FINISHED
+		job.Fail(msg)
 		return
 	}
 	log.Infof("Number of UUIDs found: %v", count)
-	job.Count = count
+	job.SetCount(count)
 
-	job.RunExport(tid, docs, h.fullExporter.Export)
+	job.RunDryRun(docs)
 }
 
 func (h *RequestHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
@@ -227,27 +607,75 @@ func (h *RequestHandler) sendErrorResponse(w http.ResponseWriter, statusCode int
 	}
 }
 
-func getCandidateUUIDs(request *http.Request) ([]string, error) {
-	var result map[string]interface{}
-	body, err := io.ReadAll(request.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading request body: %w", err)
+// getJobSettings reads the optional workers and throttle query parameters of an export request.
+func getJobSettings(request *http.Request) (export.JobSettings, error) {
+	var settings export.JobSettings
+	query := request.URL.Query()
+
+	if value := query.Get("workers"); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil {
+			return settings, fmt.Errorf("%w: workers must be a number", export.ErrInvalidSettings)
+		}
+		settings.Workers = &workers
+	}
+	if value := query.Get("throttle"); value != "" {
+		throttle, err := strconv.Atoi(value)
+		if err != nil {
+			return settings, fmt.Errorf("%w: throttle must be a number", export.ErrInvalidSettings)
+		}
+		settings.Throttle = &throttle
 	}
 
-	if err = json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("unmarshaling request body: %w", err)
+	return settings, settings.Validate()
+}
+
+var jobStates = []export.State{export.QUEUED, export.STARTING, export.RUNNING, export.PAUSED, export.FINISHED, export.INTERRUPTED, export.CANCELLED}
+
+// getPriority reads the optional priority query parameter of a request.
+func getPriority(request *http.Request) (export.Priority, error) {
+	value := request.URL.Query().Get("priority")
+	if value == "" {
+		return "", nil
 	}
+	return export.ParsePriority(value)
+}
+
+// getHistoryParams reads the comma separated states and the maximum number of jobs to list.
+// A missing status or the status all selects jobs in any state.
+func getHistoryParams(request *http.Request) ([]export.State, int, error) {
+	query := request.URL.Query()
 
-	ids, ok := result["ids"]
-	if !ok {
-		return nil, fmt.Errorf("'ids' field in request body not found")
+	var states []export.State
+	for _, value := range strings.Split(query.Get("status"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" || strings.EqualFold(value, "all") {
+			continue
+		}
+		state, ok := parseState(value)
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid status %q, should be all or one of queued, starting, running, paused, finished, interrupted and cancelled", value)
+		}
+		states = append(states, state)
 	}
-	idsString, ok := ids.(string)
-	if !ok {
-		return nil, fmt.Errorf("'ids' field is not a string")
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			return nil, 0, fmt.Errorf("limit should be a positive number")
+		}
 	}
+	return states, limit, nil
+}
 
-	return strings.Split(idsString, ","), nil
+func parseState(value string) (export.State, bool) {
+	for _, state := range jobStates {
+		if strings.EqualFold(string(state), value) {
+			return state, true
+		}
+	}
+	return "", false
 }
 
 func (h *RequestHandler) GetJob(w http.ResponseWriter, r *http.Request) {
@@ -282,8 +710,134 @@ func (h *RequestHandler) GetJob(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *RequestHandler) GetRunningJobs(w http.ResponseWriter, r *http.Request) {
+// CancelJob stops a running job. The incremental export is resumed once its in-flight documents are processed.
+func (h *RequestHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobID"]
+
+	err := h.fullExporter.CancelJob(jobID)
+	if err != nil {
+		h.log.
+			WithField("jobID", jobID).
+			WithError(err).
+			Warn("Failed to cancel job")
+
+		switch {
+		case errors.Is(err, export.ErrJobNotFound):
+			h.sendErrorResponse(w, http.StatusNotFound, "Job not found")
+		case errors.Is(err, export.ErrJobNotRunning):
+			h.sendErrorResponse(w, http.StatusConflict, "Only starting or running jobs can be cancelled")
+		default:
+			h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to cancel job")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// UpdateJob changes the number of workers and the throttle of an active job.
+func (h *RequestHandler) UpdateJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobID"]
+	log := h.log.WithField("jobID", jobID)
+
+	var settings export.JobSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		log.WithError(err).Warn("Failed to decode job settings")
+		h.sendErrorResponse(w, http.StatusBadRequest, "Pass the job settings as a JSON object")
+		return
+	}
+
+	job, err := h.fullExporter.UpdateJob(jobID, settings)
+	if err != nil {
+		log.WithError(err).Warn("Failed to update job")
+
+		switch {
+		case errors.Is(err, export.ErrInvalidSettings):
+			h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, export.ErrJobNotFound):
+			h.sendErrorResponse(w, http.StatusNotFound, "Job not found")
+		case errors.Is(err, export.ErrJobNotRunning):
+			h.sendErrorResponse(w, http.StatusConflict, "Only starting, running or paused jobs can be updated")
+		default:
+			h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to update job")
+		}
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(job); err != nil {
+		log.WithError(err).Warn("Failed to marshal job")
+		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to parse job response")
+	}
+}
+
+// GetJobUUIDs downloads the UUIDs found by a dry run as a newline separated list.
+func (h *RequestHandler) GetJobUUIDs(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobID"]
+
+	uuids, err := h.fullExporter.GetJobUUIDs(jobID)
+	if err != nil {
+		h.log.
+			WithField("jobID", jobID).
+			WithError(err).
+			Warn("Failed to retrieve job UUIDs")
+
+		switch {
+		case errors.Is(err, export.ErrJobNotFound):
+			h.sendErrorResponse(w, http.StatusNotFound, "Job not found")
+		case errors.Is(err, export.ErrUUIDsNotListed):
+			h.sendErrorResponse(w, http.StatusConflict, "UUIDs are only listed by dry runs triggered with listUUIDs=true")
+		default:
+			h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve job UUIDs")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", jobID+"-uuids.txt"))
+	for _, uuid := range uuids {
+		fmt.Fprintln(w, uuid)
+	}
+}
+
+// PauseJob stops a running job from exporting further documents until it is resumed.
+func (h *RequestHandler) PauseJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobID"]
+
+	err := h.fullExporter.PauseJob(jobID)
+	if err != nil {
+		h.log.
+			WithField("jobID", jobID).
+			WithError(err).
+			Warn("Failed to pause job")
+
+		switch {
+		case errors.Is(err, export.ErrJobNotFound):
+			h.sendErrorResponse(w, http.StatusNotFound, "Job not found")
+		case errors.Is(err, export.ErrJobNotRunning):
+			h.sendErrorResponse(w, http.StatusConflict, "Only running jobs can be paused")
+		default:
+			h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to pause job")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GetJobs returns the queued, running and paused jobs. With the status or limit query parameters it returns the job history
+// instead, the most recently started jobs first.
+func (h *RequestHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
 	jobs := h.fullExporter.GetRunningJobs()
+	if query.Has("status") || query.Has("limit") {
+		states, limit, err := getHistoryParams(r)
+		if err != nil {
+			h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		jobs = h.fullExporter.ListJobs(states, limit)
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(jobs)
@@ -2,23 +2,43 @@ package web
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/content-exporter/content"
 	"github.com/Financial-Times/content-exporter/export"
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type exporterMock struct {
 	getJobF         func(jobID string) (export.Job, error)
 	getRunningJobsF func() []export.Job
+	enqueueF        func(job *export.Job)
+	waitTurnF       func(job *export.Job) bool
+	addJobUnlessF   func(job *export.Job) bool
+	listJobsF       func(states []export.State, limit int) []export.Job
 	exportF         func(tid string, doc *content.Stub) error
+	forceExportF    func(tid string, doc *content.Stub) error
 	getWorkerCountF func() int
+	resumeJobF      func(jobID string, contentRetrievalThrottle int) (*export.Job, error)
+	checkResumableF func(jobID string) error
+	checkRetryableF func(jobID string) error
+	cancelJobF      func(jobID string) error
+	retryJobF       func(jobID string, contentRetrievalThrottle int, tid string) (*export.Job, []string, error)
+	pauseJobF       func(jobID string) error
+	unpauseJobF     func(jobID string) error
+	updateJobF      func(jobID string, settings export.JobSettings) (export.Job, error)
+	getJobUUIDsF    func(jobID string) ([]string, error)
+	subscribeF      func(jobID string) (<-chan export.Event, func(), error)
+	getReconcileF   func(jobID string, discrepancy export.Discrepancy) ([]string, error)
+	deleteF         func(uuid, tid string) error
 }
 
 func (e *exporterMock) GetJob(jobID string) (export.Job, error) {
@@ -33,15 +53,116 @@ func (e *exporterMock) GetRunningJobs() []export.Job {
 	}
 	panic("exporterMock.GetRunningJobs is not implemented")
 }
+func (e *exporterMock) ListJobs(states []export.State, limit int) []export.Job {
+	if e.listJobsF != nil {
+		return e.listJobsF(states, limit)
+	}
+	panic("exporterMock.ListJobs is not implemented")
+}
+func (e *exporterMock) Subscribe(jobID string) (<-chan export.Event, func(), error) {
+	if e.subscribeF != nil {
+		return e.subscribeF(jobID)
+	}
+	panic("exporterMock.Subscribe is not implemented")
+}
+func (e *exporterMock) Enqueue(job *export.Job) {
+	if e.enqueueF != nil {
+		e.enqueueF(job)
+	}
+}
+func (e *exporterMock) WaitTurn(job *export.Job) bool {
+	if e.waitTurnF != nil {
+		return e.waitTurnF(job)
+	}
+	return true
+}
 func (e *exporterMock) AddJob(_ *export.Job) {
 	// Function doesn't return anything so a facade would do
 }
+func (e *exporterMock) AddJobUnlessFullExportRunning(job *export.Job) bool {
+	if e.addJobUnlessF != nil {
+		return e.addJobUnlessF(job)
+	}
+	panic("exporterMock.AddJobUnlessFullExportRunning is not implemented")
+}
+func (e *exporterMock) ResumeJob(jobID string, contentRetrievalThrottle int) (*export.Job, error) {
+	if e.resumeJobF != nil {
+		return e.resumeJobF(jobID, contentRetrievalThrottle)
+	}
+	panic("exporterMock.ResumeJob is not implemented")
+}
+func (e *exporterMock) CheckResumable(jobID string) error {
+	if e.checkResumableF != nil {
+		return e.checkResumableF(jobID)
+	}
+	return nil
+}
+func (e *exporterMock) CheckRetryable(jobID string) error {
+	if e.checkRetryableF != nil {
+		return e.checkRetryableF(jobID)
+	}
+	return nil
+}
+func (e *exporterMock) CancelJob(jobID string) error {
+	if e.cancelJobF != nil {
+		return e.cancelJobF(jobID)
+	}
+	panic("exporterMock.CancelJob is not implemented")
+}
+func (e *exporterMock) RetryJob(jobID string, contentRetrievalThrottle int, tid string) (*export.Job, []string, error) {
+	if e.retryJobF != nil {
+		return e.retryJobF(jobID, contentRetrievalThrottle, tid)
+	}
+	panic("exporterMock.RetryJob is not implemented")
+}
+func (e *exporterMock) PauseJob(jobID string) error {
+	if e.pauseJobF != nil {
+		return e.pauseJobF(jobID)
+	}
+	panic("exporterMock.PauseJob is not implemented")
+}
+func (e *exporterMock) UnpauseJob(jobID string) error {
+	if e.unpauseJobF != nil {
+		return e.unpauseJobF(jobID)
+	}
+	panic("exporterMock.UnpauseJob is not implemented")
+}
+func (e *exporterMock) UpdateJob(jobID string, settings export.JobSettings) (export.Job, error) {
+	if e.updateJobF != nil {
+		return e.updateJobF(jobID, settings)
+	}
+	panic("exporterMock.UpdateJob is not implemented")
+}
 func (e *exporterMock) Export(tid string, doc *content.Stub) error {
 	if e.exportF != nil {
 		return e.exportF(tid, doc)
 	}
 	panic("exporterMock.Export is not implemented")
 }
+func (e *exporterMock) ForceExport(tid string, doc *content.Stub) error {
+	if e.forceExportF != nil {
+		return e.forceExportF(tid, doc)
+	}
+	panic("exporterMock.ForceExport is not implemented")
+}
+func (e *exporterMock) GetJobUUIDs(jobID string) ([]string, error) {
+	if e.getJobUUIDsF != nil {
+		return e.getJobUUIDsF(jobID)
+	}
+	panic("exporterMock.GetJobUUIDs is not implemented")
+}
+func (e *exporterMock) GetReconciliation(jobID string, discrepancy export.Discrepancy) ([]string, error) {
+	if e.getReconcileF != nil {
+		return e.getReconcileF(jobID, discrepancy)
+	}
+	panic("exporterMock.GetReconciliation is not implemented")
+}
+func (e *exporterMock) Delete(uuid, tid string) error {
+	if e.deleteF != nil {
+		return e.deleteF(uuid, tid)
+	}
+	panic("exporterMock.Delete is not implemented")
+}
 func (e *exporterMock) GetWorkerCount() int {
 	if e.getWorkerCountF != nil {
 		return e.getWorkerCountF()
@@ -50,12 +171,12 @@ func (e *exporterMock) GetWorkerCount() int {
 }
 
 type inquirerMock struct {
-	inquireF func(ctx context.Context, candidates []string) (chan *content.Stub, int, error)
+	inquireF func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error)
 }
 
-func (i *inquirerMock) Inquire(ctx context.Context, candidates []string) (chan *content.Stub, int, error) {
+func (i *inquirerMock) Inquire(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
 	if i.inquireF != nil {
-		return i.inquireF(ctx, candidates)
+		return i.inquireF(ctx, candidates, filter, after)
 	}
 	panic("inquirerMock.Inquire is not implemented")
 }
@@ -88,7 +209,7 @@ func TestRequestHandler_Export(t *testing.T) {
 				req, _ := http.NewRequest("POST", "/export", body)
 				return req
 			},
-			expectedBody:   "{\"error\":\"Pass a list of ids or trigger a full export flag\"}",
+			expectedBody:   "{\"error\":\"Pass a list of ids, a filter or trigger a full export flag\"}",
 			expectedStatus: http.StatusBadRequest,
 		},
 		{
@@ -107,7 +228,7 @@ func TestRequestHandler_Export(t *testing.T) {
 				req, _ := http.NewRequest("POST", "/export?fullExport=false", body)
 				return req
 			},
-			expectedBody:   "{\"error\":\"Pass a list of ids or trigger a full export flag\"}",
+			expectedBody:   "{\"error\":\"Pass a list of ids, a filter or trigger a full export flag\"}",
 			expectedStatus: http.StatusBadRequest,
 		},
 		{
@@ -129,6 +250,63 @@ func TestRequestHandler_Export(t *testing.T) {
 			expectedBody:   "{\"error\":\"Pass either a list of ids or the full export flag, not both\"}",
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name: "test that a malformed JSON body with a full export flag results in an error",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			},
+			inquirer:         &inquirerMock{},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				body := strings.NewReader(`{"filter":{"publishedFrom":"2024-03-01"`)
+				req, _ := http.NewRequest("POST", "/export?fullExport=true", body)
+				return req
+			},
+			expectedBody:   "{\"error\":\"unmarshaling request body: unexpected end of JSON input\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "test that passing an invalid number of workers results in an error",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			},
+			inquirer:         &inquirerMock{},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				body := strings.NewReader(``)
+				req, _ := http.NewRequest("POST", "/export?fullExport=true&workers=0", body)
+				return req
+			},
+			expectedBody:   "{\"error\":\"invalid job settings: workers must be between 1 and 100\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "test that passing a non numeric throttle results in an error",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			},
+			inquirer:         &inquirerMock{},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				body := strings.NewReader(``)
+				req, _ := http.NewRequest("POST", "/export?fullExport=true&throttle=fast", body)
+				return req
+			},
+			expectedBody:   "{\"error\":\"invalid job settings: throttle must be a number\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name: "test that passing a full export flag triggers an export",
 			exporter: &exporterMock{
@@ -143,9 +321,9 @@ func TestRequestHandler_Export(t *testing.T) {
 				},
 			},
 			inquirer: &inquirerMock{
-				inquireF: func(ctx context.Context, candidates []string) (chan *content.Stub, int, error) {
+				inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
 					c := make(chan *content.Stub)
-					return c, 0, nil
+					return content.NewStream(c), 0, nil
 				},
 			},
 			locker:           export.NewLocker(),
@@ -169,28 +347,227 @@ func TestRequestHandler_Export(t *testing.T) {
 				},
 			},
 			inquirer: &inquirerMock{
-				inquireF: func(ctx context.Context, candidates []string) (chan *content.Stub, int, error) {
+				inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
 					c := make(chan *content.Stub)
-					return c, 0, nil
+					return content.NewStream(c), 0, nil
 				},
 			},
 			locker:           export.NewLocker(),
 			incExportEnabled: false,
 			throttle:         10,
 			getHTTPRequest: func() *http.Request {
-				body := strings.NewReader(`{"ids":"some-valid-uuids"}`)
+				body := strings.NewReader(`{"ids":"a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e,2c1d77f1-c087-495b-bcd7-0680844d622b"}`)
+				req, _ := http.NewRequest("POST", "/export", body)
+				return req
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "test that invalid ids are reported when triggering a targeted export",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+				getWorkerCountF: func() int {
+					return 1
+				},
+			},
+			inquirer: &inquirerMock{
+				inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+					c := make(chan *content.Stub)
+					return content.NewStream(c), 0, nil
+				},
+			},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				body := strings.NewReader("a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e\nnot-a-uuid\n")
+				req, _ := http.NewRequest("POST", "/export", body)
+				req.Header.Set("Content-Type", "text/plain")
+				return req
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "test that passing only invalid ids results in an error",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			},
+			inquirer:         &inquirerMock{},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				body := strings.NewReader(`{"ids":["not-a-uuid"," "]}`)
+				req, _ := http.NewRequest("POST", "/export", body)
+				return req
+			},
+			expectedBody:   "{\"Rejected\":[\"not-a-uuid\"],\"RejectedCount\":1,\"error\":\"None of the passed ids is a valid UUID\"}\n",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "test that passing both ids and a filter results in an error",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			},
+			inquirer:         &inquirerMock{},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				body := strings.NewReader(`{"ids":"some-valid-uuids","filter":{"contentTypes":["Article"]}}`)
+				req, _ := http.NewRequest("POST", "/export", body)
+				return req
+			},
+			expectedBody:   "{\"error\":\"Pass either a list of ids or a filter, not both\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "test that passing an invalid filter results in an error",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			},
+			inquirer:         &inquirerMock{},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				body := strings.NewReader(`{"filter":{"publishedFrom":"March 2024"}}`)
+				req, _ := http.NewRequest("POST", "/export", body)
+				return req
+			},
+			expectedBody:   "{\"error\":\"invalid filter: publishedFrom should be a date like 2024-03-01\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "test that passing a filter triggers a filtered export",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+				getWorkerCountF: func() int {
+					return 1
+				},
+			},
+			inquirer: &inquirerMock{
+				inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+					c := make(chan *content.Stub)
+					return content.NewStream(c), 0, nil
+				},
+			},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				body := strings.NewReader(`{"filter":{"publishedFrom":"2024-03-01","publishedTo":"2024-03-31","contentTypes":["Article"]}}`)
 				req, _ := http.NewRequest("POST", "/export", body)
 				return req
 			},
 			expectedStatus: http.StatusAccepted,
 		},
+		{
+			name: "test that passing an invalid since timestamp results in an error",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			},
+			inquirer:         &inquirerMock{},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				req, _ := http.NewRequest("POST", "/export?since=2024-03-01", strings.NewReader(``))
+				return req
+			},
+			expectedBody:   "{\"error\":\"invalid since parameter: modifiedSince should be a timestamp like 2024-03-01T10:00:00Z\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "test that passing a since timestamp triggers a delta export",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+				getWorkerCountF: func() int {
+					return 1
+				},
+			},
+			inquirer: &inquirerMock{
+				inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+					if filter == nil || filter.ModifiedSince != "2024-03-01T10:00:00Z" {
+						return nil, 0, fmt.Errorf("unexpected filter: %v", filter)
+					}
+					c := make(chan *content.Stub)
+					return content.NewStream(c), 0, nil
+				},
+			},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				req, _ := http.NewRequest("POST", "/export?since=2024-03-01T10:00:00Z", strings.NewReader(``))
+				return req
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "test that passing an invalid priority results in an error",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			},
+			inquirer:         &inquirerMock{},
+			locker:           export.NewLocker(),
+			incExportEnabled: false,
+			throttle:         10,
+			getHTTPRequest: func() *http.Request {
+				req, _ := http.NewRequest("POST", "/export?fullExport=true&priority=urgent", strings.NewReader(``))
+				return req
+			},
+			expectedBody:   "{\"error\":\"invalid priority \\\"urgent\\\", should be one of low, normal and high\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "test that a dry run is triggered without pausing the incremental export",
+			exporter: &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			},
+			inquirer: &inquirerMock{
+				inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+					c := make(chan *content.Stub)
+					close(c)
+					return content.NewStream(c), 0, nil
+				},
+			},
+			// nobody acks the lock, so locking would fail
+			locker:           export.NewLocker(),
+			incExportEnabled: true,
+			throttle:         0,
+			getHTTPRequest: func() *http.Request {
+				body := strings.NewReader(``)
+				req, _ := http.NewRequest("POST", "/export?fullExport=true&dryRun=true&listUUIDs=true", body)
+				return req
+			},
+			expectedStatus: http.StatusAccepted,
+		},
 	}
 
 	log := logger.NewUPPLogger("test", "PANIC")
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			h := NewRequestHandler(test.exporter, test.inquirer, test.locker, test.incExportEnabled, test.throttle, log, nil, 0)
+			h := NewRequestHandler(test.exporter, test.inquirer, test.locker, test.incExportEnabled, test.throttle, log, nil, 0, nil, nil)
 			rr := httptest.NewRecorder()
 			r := mux.NewRouter()
 			req := test.getHTTPRequest()
@@ -206,3 +583,668 @@ func TestRequestHandler_Export(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestHandler_ExportIsQueuedWithPriority(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	var queued *export.Job
+	exporter := &exporterMock{
+		getWorkerCountF: func() int {
+			return 1
+		},
+		enqueueF: func(job *export.Job) {
+			job.Status = export.QUEUED
+			queued = job
+		},
+		waitTurnF: func(job *export.Job) bool {
+			return false
+		},
+	}
+	h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	req, _ := http.NewRequest("POST", "/export?fullExport=true&priority=high", strings.NewReader(``))
+
+	r.HandleFunc("/export", h.Export).Methods("POST")
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	require.NotNil(t, queued)
+	assert.Equal(t, export.HighPriority, queued.Priority)
+	assert.Equal(t, fmt.Sprintf("{\"ID\":%q,\"Status\":\"Queued\"}\n", queued.ID), rr.Body.String())
+}
+
+func TestRequestHandler_LockIsSharedByJobs(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	locker := export.NewLocker()
+	signals := make(chan bool, 4)
+	go func() {
+		for locked := range locker.Locked {
+			signals <- locked
+			if locked {
+				locker.Acked <- struct{}{}
+			}
+		}
+	}()
+	defer close(locker.Locked)
+
+	h := NewRequestHandler(&exporterMock{}, &inquirerMock{}, locker, true, 0, log, nil, 0, nil, nil)
+
+	require.NoError(t, h.lock())
+	require.NoError(t, h.lock())
+	assert.Equal(t, true, <-signals)
+
+	h.releaseLock()
+	assert.Empty(t, signals)
+	h.releaseLock()
+	assert.Equal(t, false, <-signals)
+}
+
+func TestRequestHandler_LockWaitsForThePauseWithoutHoldingTheMutex(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	locker := export.NewLocker()
+	h := NewRequestHandler(&exporterMock{}, &inquirerMock{}, locker, true, 0, log, nil, 0, nil, nil)
+
+	locked := make(chan error, 2)
+	go func() {
+		locked <- h.lock()
+	}()
+	assert.True(t, <-locker.Locked)
+	go func() {
+		locked <- h.lock()
+	}()
+
+	// Both callers wait for the listener to acknowledge the pause, but the mutex is free meanwhile
+	require.Eventually(t, func() bool {
+		if !h.lockMu.TryLock() {
+			return false
+		}
+		defer h.lockMu.Unlock()
+		return h.lockChange != nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, locked)
+
+	locker.Acked <- struct{}{}
+	require.NoError(t, <-locked)
+	require.NoError(t, <-locked)
+	assert.Equal(t, 2, h.lockHolders)
+}
+
+func TestRequestHandler_ResumeJob(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	tests := []struct {
+		name           string
+		exporter       *exporterMock
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name: "test that resuming a paused job unpauses it",
+			exporter: &exporterMock{
+				unpauseJobF: func(jobID string) error {
+					return nil
+				},
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "test that resuming an unknown job results in not found",
+			exporter: &exporterMock{
+				unpauseJobF: func(jobID string) error {
+					return export.ErrJobNotPaused
+				},
+				checkResumableF: func(jobID string) error {
+					return export.ErrJobNotFound
+				},
+			},
+			expectedBody:   "{\"error\":\"Job not found\"}",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "test that resuming a job which is not interrupted results in a conflict",
+			exporter: &exporterMock{
+				unpauseJobF: func(jobID string) error {
+					return export.ErrJobNotPaused
+				},
+				checkResumableF: func(jobID string) error {
+					return export.ErrJobNotResumable
+				},
+			},
+			expectedBody:   "{\"error\":\"Only paused jobs or interrupted full export jobs can be resumed\"}",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "test that resuming a job while another job is running queues it",
+			exporter: &exporterMock{
+				unpauseJobF: func(jobID string) error {
+					return export.ErrJobNotPaused
+				},
+				resumeJobF: func(jobID string, contentRetrievalThrottle int) (*export.Job, error) {
+					job := export.NewJob(1, contentRetrievalThrottle, true, log)
+					job.ID = jobID
+					return job, nil
+				},
+				enqueueF: func(job *export.Job) {
+					job.Status = export.QUEUED
+				},
+				waitTurnF: func(job *export.Job) bool {
+					return false
+				},
+			},
+			expectedBody:   "{\"ID\":\"some-job\",\"Status\":\"Queued\"}\n",
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "test that resuming an interrupted job triggers an export",
+			exporter: &exporterMock{
+				unpauseJobF: func(jobID string) error {
+					return export.ErrJobNotPaused
+				},
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+				resumeJobF: func(jobID string, contentRetrievalThrottle int) (*export.Job, error) {
+					return export.NewJob(1, contentRetrievalThrottle, true, log), nil
+				},
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inquirer := &inquirerMock{
+				inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+					c := make(chan *content.Stub)
+					close(c)
+					return content.NewStream(c), 0, nil
+				},
+			}
+			h := NewRequestHandler(test.exporter, inquirer, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("POST", "/jobs/some-job/resume", nil)
+
+			r.HandleFunc("/jobs/{jobID}/resume", h.ResumeJob).Methods("POST")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestHandler_RejectsJobsBeforePausingTheIncrementalExport(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+	exporter := &exporterMock{
+		unpauseJobF: func(jobID string) error {
+			return export.ErrJobNotPaused
+		},
+		checkResumableF: func(jobID string) error {
+			return export.ErrJobNotResumable
+		},
+		checkRetryableF: func(jobID string) error {
+			return export.ErrJobNotRetryable
+		},
+	}
+	// Nothing acknowledges the pause of the incremental export, so pausing it would time out
+	h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), true, 0, log, nil, 0, nil, nil)
+	r := mux.NewRouter()
+	r.HandleFunc("/jobs/{jobID}/resume", h.ResumeJob).Methods("POST")
+	r.HandleFunc("/jobs/{jobID}/retry", h.RetryJob).Methods("POST")
+
+	for _, action := range []string{"resume", "retry"} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/jobs/some-job/"+action, nil)
+		start := time.Now()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusConflict, rr.Code, action)
+		assert.Less(t, time.Since(start), time.Second, action)
+	}
+}
+
+func TestRequestHandler_CancelJob(t *testing.T) {
+	tests := []struct {
+		name           string
+		cancelErr      error
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name:           "test that cancelling an unknown job results in not found",
+			cancelErr:      export.ErrJobNotFound,
+			expectedBody:   "{\"error\":\"Job not found\"}",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "test that cancelling a finished job results in a conflict",
+			cancelErr:      export.ErrJobNotRunning,
+			expectedBody:   "{\"error\":\"Only starting or running jobs can be cancelled\"}",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "test that cancelling a running job is accepted",
+			expectedStatus: http.StatusAccepted,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &exporterMock{
+				cancelJobF: func(jobID string) error {
+					assert.Equal(t, "some-job", jobID)
+					return test.cancelErr
+				},
+			}
+			h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("DELETE", "/jobs/some-job", nil)
+
+			r.HandleFunc("/jobs/{jobID}", h.CancelJob).Methods("DELETE")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestHandler_RetryJob(t *testing.T) {
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	tests := []struct {
+		name               string
+		retryErr           error
+		expectedCandidates []string
+		expectedBody       string
+		expectedStatus     int
+	}{
+		{
+			name:           "test that retrying an unknown job results in not found",
+			retryErr:       export.ErrJobNotFound,
+			expectedBody:   "{\"error\":\"Job not found\"}",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "test that retrying a job without failures results in a conflict",
+			retryErr:       export.ErrJobNotRetryable,
+			expectedBody:   "{\"error\":\"Only finished jobs with failures can be retried\"}",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:               "test that retrying a job exports its failed documents",
+			expectedCandidates: []string{"uuid1", "uuid2"},
+			expectedStatus:     http.StatusAccepted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inquired := make(chan []string, 1)
+			exporter := &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+				checkRetryableF: func(jobID string) error {
+					return test.retryErr
+				},
+				retryJobF: func(jobID string, contentRetrievalThrottle int, tid string) (*export.Job, []string, error) {
+					return export.NewJob(1, contentRetrievalThrottle, false, log), test.expectedCandidates, nil
+				},
+			}
+			inquirer := &inquirerMock{
+				inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+					inquired <- candidates
+					c := make(chan *content.Stub)
+					close(c)
+					return content.NewStream(c), 0, nil
+				},
+			}
+			h := NewRequestHandler(exporter, inquirer, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("POST", "/jobs/some-job/retry", nil)
+
+			r.HandleFunc("/jobs/{jobID}/retry", h.RetryJob).Methods("POST")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, rr.Body.String())
+			}
+			if test.expectedCandidates != nil {
+				assert.Equal(t, test.expectedCandidates, <-inquired)
+			}
+		})
+	}
+}
+
+func TestRequestHandler_PauseJob(t *testing.T) {
+	tests := []struct {
+		name           string
+		pauseErr       error
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name:           "test that pausing an unknown job results in not found",
+			pauseErr:       export.ErrJobNotFound,
+			expectedBody:   "{\"error\":\"Job not found\"}",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "test that pausing a job which is not running results in a conflict",
+			pauseErr:       export.ErrJobNotRunning,
+			expectedBody:   "{\"error\":\"Only running jobs can be paused\"}",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "test that pausing a running job is accepted",
+			expectedStatus: http.StatusAccepted,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &exporterMock{
+				pauseJobF: func(jobID string) error {
+					assert.Equal(t, "some-job", jobID)
+					return test.pauseErr
+				},
+			}
+			h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("POST", "/jobs/some-job/pause", nil)
+
+			r.HandleFunc("/jobs/{jobID}/pause", h.PauseJob).Methods("POST")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestHandler_UpdateJob(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		updateErr      error
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name:           "test that a malformed body results in an error",
+			body:           `workers=5`,
+			expectedBody:   "{\"error\":\"Pass the job settings as a JSON object\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "test that invalid settings result in an error",
+			body:           `{"workers":1000}`,
+			updateErr:      fmt.Errorf("%w: workers must be between 1 and 100", export.ErrInvalidSettings),
+			expectedBody:   "{\"error\":\"invalid job settings: workers must be between 1 and 100\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "test that updating a finished job results in a conflict",
+			body:           `{"workers":5}`,
+			updateErr:      export.ErrJobNotRunning,
+			expectedBody:   "{\"error\":\"Only starting, running or paused jobs can be updated\"}",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "test that updating a running job returns the job",
+			body:           `{"workers":5,"throttle":100}`,
+			expectedBody:   "{\"ID\":\"some-job\",\"Workers\":5,\"Throttle\":100,\"Status\":\"Running\"}\n",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &exporterMock{
+				updateJobF: func(jobID string, settings export.JobSettings) (export.Job, error) {
+					if test.updateErr != nil {
+						return export.Job{}, test.updateErr
+					}
+					return export.Job{ID: jobID, Workers: *settings.Workers, Throttle: *settings.Throttle, Status: export.RUNNING}, nil
+				},
+			}
+			h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("PATCH", "/jobs/some-job", strings.NewReader(test.body))
+
+			r.HandleFunc("/jobs/{jobID}", h.UpdateJob).Methods("PATCH")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			assert.Equal(t, test.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestRequestHandler_GetJobUUIDs(t *testing.T) {
+	tests := []struct {
+		name           string
+		uuids          []string
+		err            error
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name:           "test that the uuids of a dry run are listed",
+			uuids:          []string{"uuid-a", "uuid-b"},
+			expectedBody:   "uuid-a\nuuid-b\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test that an unknown job results in an error",
+			err:            export.ErrJobNotFound,
+			expectedBody:   "{\"error\":\"Job not found\"}",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "test that a job without uuid list results in a conflict",
+			err:            export.ErrUUIDsNotListed,
+			expectedBody:   "{\"error\":\"UUIDs are only listed by dry runs triggered with listUUIDs=true\"}",
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &exporterMock{
+				getJobUUIDsF: func(jobID string) ([]string, error) {
+					assert.Equal(t, "some-job", jobID)
+					return test.uuids, test.err
+				},
+			}
+			h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("GET", "/jobs/some-job/uuids", nil)
+
+			r.HandleFunc("/jobs/{jobID}/uuids", h.GetJobUUIDs).Methods("GET")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			assert.Equal(t, test.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestRequestHandler_StartExport(t *testing.T) {
+	emptyInquirer := &inquirerMock{
+		inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+			c := make(chan *content.Stub)
+			close(c)
+			return content.NewStream(c), 0, nil
+		},
+	}
+	workers := 0
+
+	tests := []struct {
+		name         string
+		isFullExport bool
+		filter       *content.Filter
+		settings     export.JobSettings
+		fullExport   bool
+		expectedErr  string
+	}{
+		{
+			name:         "test that a full export is started",
+			isFullExport: true,
+		},
+		{
+			name:   "test that a filtered export is started",
+			filter: &content.Filter{ModifiedSince: "2024-03-01T10:00:00Z"},
+		},
+		{
+			name:         "test that an export is not started while another full export is queued or running",
+			isFullExport: true,
+			fullExport:   true,
+			expectedErr:  "a full export is already queued or running",
+		},
+		{
+			name:         "test that invalid job settings result in an error",
+			isFullExport: true,
+			settings:     export.JobSettings{Workers: &workers},
+			expectedErr:  "invalid job settings: workers must be between 1 and 100",
+		},
+		{
+			name:        "test that an invalid filter results in an error",
+			filter:      &content.Filter{ModifiedSince: "yesterday"},
+			expectedErr: "invalid filter: modifiedSince should be a timestamp like 2024-03-01T10:00:00Z",
+		},
+		{
+			name:        "test that an export without a filter or the full export flag results in an error",
+			expectedErr: "only full and filtered exports can be started without ids",
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &exporterMock{
+				addJobUnlessF: func(*export.Job) bool {
+					return !test.fullExport
+				},
+				getWorkerCountF: func() int {
+					return 1
+				},
+			}
+			h := NewRequestHandler(exporter, emptyInquirer, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+
+			job, err := h.StartExport(test.isFullExport, test.filter, test.settings, "tid_test")
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotEmpty(t, job.ID)
+			assert.Equal(t, test.filter, job.Filter)
+		})
+	}
+}
+
+func TestRequestHandler_GetJobs(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStates []export.State
+		expectedLimit  int
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name:           "test that the running jobs are returned without parameters",
+			expectedBody:   "[{\"ID\":\"running\",\"Status\":\"Running\"}]\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test that the finished jobs are returned with a limit",
+			query:          "?status=finished&limit=10",
+			expectedStates: []export.State{export.FINISHED},
+			expectedLimit:  10,
+			expectedBody:   "[{\"ID\":\"listed\",\"Status\":\"Finished\"}]\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test that several states can be requested",
+			query:          "?status=Finished,cancelled",
+			expectedStates: []export.State{export.FINISHED, export.CANCELLED},
+			expectedBody:   "[{\"ID\":\"listed\",\"Status\":\"Finished\"}]\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test that all jobs are returned with status all",
+			query:          "?status=all",
+			expectedBody:   "[{\"ID\":\"listed\",\"Status\":\"Finished\"}]\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test that an unknown status results in an error",
+			query:          "?status=done",
+			expectedBody:   "{\"error\":\"invalid status \\\"done\\\", should be all or one of queued, starting, running, paused, finished, interrupted and cancelled\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "test that an invalid limit results in an error",
+			query:          "?limit=0",
+			expectedBody:   "{\"error\":\"limit should be a positive number\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{{ID: "running", Status: export.RUNNING}}
+				},
+				listJobsF: func(states []export.State, limit int) []export.Job {
+					assert.Equal(t, test.expectedStates, states)
+					assert.Equal(t, test.expectedLimit, limit)
+					return []export.Job{{ID: "listed", Status: export.FINISHED}}
+				},
+			}
+			h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, nil)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("GET", "/jobs"+test.query, nil)
+
+			r.HandleFunc("/jobs", h.GetJobs).Methods("GET")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			assert.Equal(t, test.expectedBody, rr.Body.String())
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Financial-Times/content-exporter/schedule"
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+type scheduleLister interface {
+	Statuses() []schedule.Status
+}
+
+type ScheduleHandler struct {
+	scheduler scheduleLister
+	log       *logger.UPPLogger
+}
+
+func NewScheduleHandler(scheduler scheduleLister, log *logger.UPPLogger) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduler: scheduler,
+		log:       log,
+	}
+}
+
+// GetSchedules returns the configured recurring exports with their last and next runs.
+func (h *ScheduleHandler) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	statuses := h.scheduler.Statuses()
+
+	w.Header().Add("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(statuses)
+	if err != nil {
+		h.log.WithError(err).Warn("Failed to marshal schedules")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
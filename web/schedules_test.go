@@ -0,0 +1,65 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/schedule"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type scheduleListerMock struct {
+	statuses []schedule.Status
+}
+
+func (s *scheduleListerMock) Statuses() []schedule.Status {
+	return s.statuses
+}
+
+func TestScheduleHandler_GetSchedules(t *testing.T) {
+	lastRun := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		statuses     []schedule.Status
+		expectedBody string
+	}{
+		{
+			name:         "test that no schedules result in an empty list",
+			statuses:     []schedule.Status{},
+			expectedBody: "[]\n",
+		},
+		{
+			name: "test that the schedules are listed with their runs",
+			statuses: []schedule.Status{
+				{
+					Schedule:  schedule.Schedule{Name: "nightly", Cron: "0 2 * * *", FullExport: true},
+					NextRun:   time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC),
+					LastRun:   &lastRun,
+					LastJobID: "job-1",
+				},
+			},
+			expectedBody: "[{\"Name\":\"nightly\",\"Cron\":\"0 2 * * *\",\"FullExport\":true,\"NextRun\":\"2024-03-02T02:00:00Z\",\"LastRun\":\"2024-03-01T02:00:00Z\",\"LastJobID\":\"job-1\"}]\n",
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewScheduleHandler(&scheduleListerMock{statuses: test.statuses}, log)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("GET", "/schedules", nil)
+
+			r.HandleFunc("/schedules", h.GetSchedules).Methods("GET")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, test.expectedBody, rr.Body.String())
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Financial-Times/content-exporter/content"
+)
+
+// maxReportedRejected caps the number of rejected ids echoed back in a response.
+const maxReportedRejected = 1000
+
+var uuidRegexp = regexp.MustCompile("^[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}$")
+
+// exportBody holds what the body of an export request asks for.
+type exportBody struct {
+	candidates []string
+	rejected   []string
+	filter     *content.Filter
+	seen       map[string]bool
+}
+
+// addID validates an id and adds it to the candidates or to the rejected ids. Blank ids and duplicates are skipped.
+func (b *exportBody) addID(id string) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" || b.seen[id] {
+		return
+	}
+	if b.seen == nil {
+		b.seen = make(map[string]bool)
+	}
+	b.seen[id] = true
+
+	if uuidRegexp.MatchString(id) {
+		b.candidates = append(b.candidates, id)
+	} else {
+		b.rejected = append(b.rejected, id)
+	}
+}
+
+func (b *exportBody) hasIDs() bool {
+	return len(b.candidates) != 0 || len(b.rejected) != 0
+}
+
+// reportedRejected returns the rejected ids to be included in a response.
+func reportedRejected(rejected []string) []string {
+	if len(rejected) > maxReportedRejected {
+		return rejected[:maxReportedRejected]
+	}
+	return rejected
+}
+
+// readExportBody reads the ids and the filter of an export request. The ids can be sent as
+//   - a JSON object with a comma separated string or an array of strings in its ids field,
+//   - a text/plain body with one id per line,
+//   - a multipart/form-data upload of such text files.
+//
+// The filter can only be sent in a JSON body. An empty body is treated as no ids and no filter, while any other
+// body which isn't JSON is rejected, so that e.g. a mistyped filter doesn't result in a full export.
+func readExportBody(r *http.Request) (exportBody, error) {
+	var body exportBody
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "text/plain":
+		err = readIDLines(r.Body, &body)
+	case "multipart/form-data":
+		err = readMultipartIDs(r, &body)
+	default:
+		err = readJSONBody(r.Body, &body)
+	}
+	return body, err
+}
+
+func readIDLines(reader io.Reader, body *exportBody) error {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		body.addID(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading ids: %w", err)
+	}
+	return nil
+}
+
+func readMultipartIDs(r *http.Request, body *exportBody) error {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return fmt.Errorf("reading multipart body: %w", err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading multipart body: %w", err)
+		}
+
+		if part.FormName() == "ids" || part.FileName() != "" {
+			err = readIDLines(part, body)
+		}
+		_ = part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readJSONBody(reader io.Reader, body *exportBody) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("reading request body: %w", err)
+	}
+
+	var result struct {
+		IDs    json.RawMessage `json:"ids"`
+		Filter json.RawMessage `json:"filter"`
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("unmarshaling request body: %w", err)
+	}
+
+	if body.filter, err = getFilter(result.Filter); err != nil {
+		return err
+	}
+	return readJSONIDs(result.IDs, body)
+}
+
+func readJSONIDs(raw json.RawMessage, body *exportBody) error {
+	if raw == nil {
+		return nil
+	}
+
+	var joined string
+	if err := json.Unmarshal(raw, &joined); err == nil {
+		for _, id := range strings.Split(joined, ",") {
+			body.addID(id)
+		}
+		return nil
+	}
+
+	var ids []string
+	if err := json.Unmarshal(raw, &ids); err != nil {
+		return fmt.Errorf("'ids' field should be a comma separated string or an array of strings")
+	}
+	for _, id := range ids {
+		body.addID(id)
+	}
+	return nil
+}
+
+// getFilter reads the optional filter object of an export request body. It returns nil if there is no filter.
+func getFilter(raw json.RawMessage) (*content.Filter, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	var filter content.Filter
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&filter); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return &filter, nil
+}
+
+// withSince adds the since query parameter of a delta export to the filter of the request.
+// It returns the filter unchanged if there is no since parameter.
+func withSince(filter *content.Filter, since string) (*content.Filter, error) {
+	if since == "" {
+		return filter, nil
+	}
+
+	if filter == nil {
+		filter = &content.Filter{}
+	} else if filter.ModifiedSince != "" {
+		return nil, fmt.Errorf("pass the since timestamp either as a query parameter or in the filter, not both")
+	}
+	filter.ModifiedSince = since
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid since parameter: %w", err)
+	}
+	return filter, nil
+}
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadExportBody(t *testing.T) {
+	tests := []struct {
+		name               string
+		contentType        string
+		body               string
+		expectedCandidates []string
+		expectedRejected   []string
+		expectedFilter     *content.Filter
+		expectedErr        string
+	}{
+		{
+			name: "no body",
+			body: ``,
+		},
+		{
+			name: "whitespace only body",
+			body: " \n\t",
+		},
+		{
+			name:        "body which isn't JSON",
+			body:        `ids=a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e`,
+			expectedErr: "unmarshaling request body: invalid character 'i' looking for beginning of value",
+		},
+		{
+			name:        "malformed JSON filter",
+			body:        `{"filter":{"publishedFrom":"2024-03-01",}}`,
+			expectedErr: "unmarshaling request body: invalid character '}' looking for beginning of object key string",
+		},
+		{
+			name:               "comma separated ids",
+			body:               `{"ids":"a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e, 2C1D77F1-C087-495B-BCD7-0680844D622B,,bad-id"}`,
+			expectedCandidates: []string{"a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e", "2c1d77f1-c087-495b-bcd7-0680844d622b"},
+			expectedRejected:   []string{"bad-id"},
+		},
+		{
+			name:               "array of ids",
+			body:               `{"ids":["a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e","a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e","a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4ef"]}`,
+			expectedCandidates: []string{"a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e"},
+			expectedRejected:   []string{"a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4ef"},
+		},
+		{
+			name:        "ids of a wrong type",
+			body:        `{"ids":42}`,
+			expectedErr: "'ids' field should be a comma separated string or an array of strings",
+		},
+		{
+			name:               "newline separated ids",
+			contentType:        "text/plain; charset=utf-8",
+			body:               "a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e\r\n\r\n  2c1d77f1-c087-495b-bcd7-0680844d622b  \nbad-id\n",
+			expectedCandidates: []string{"a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e", "2c1d77f1-c087-495b-bcd7-0680844d622b"},
+			expectedRejected:   []string{"bad-id"},
+		},
+		{
+			name:           "valid filter",
+			body:           `{"filter":{"publishedFrom":"2024-03-01","publishedTo":"2024-03-31","contentTypes":["Article"],"publications":["pub-1"],"editorialDesk":"/FT/Newsdesk"}}`,
+			expectedFilter: &content.Filter{PublishedFrom: "2024-03-01", PublishedTo: "2024-03-31", ContentTypes: []string{"Article"}, Publications: []string{"pub-1"}, EditorialDesk: "/FT/Newsdesk"},
+		},
+		{
+			name:        "filter with an unknown field",
+			body:        `{"filter":{"contentType":"Article"}}`,
+			expectedErr: "invalid filter: json: unknown field \"contentType\"",
+		},
+		{
+			name:        "empty filter",
+			body:        `{"filter":{}}`,
+			expectedErr: "invalid filter: filter has no criteria",
+		},
+		{
+			name:        "reversed date range",
+			body:        `{"filter":{"publishedFrom":"2024-04-01","publishedTo":"2024-03-01"}}`,
+			expectedErr: "invalid filter: publishedFrom is after publishedTo",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/export", strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+
+			body, err := readExportBody(req)
+
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCandidates, body.candidates)
+			assert.Equal(t, test.expectedRejected, body.rejected)
+			assert.Equal(t, test.expectedFilter, body.filter)
+		})
+	}
+}
+
+func TestWithSince(t *testing.T) {
+	filter, err := withSince(nil, "")
+	assert.NoError(t, err)
+	assert.Nil(t, filter)
+
+	filter, err = withSince(&content.Filter{ContentTypes: []string{"Article"}}, "2024-03-01T10:00:00+01:00")
+	assert.NoError(t, err)
+	assert.Equal(t, &content.Filter{ContentTypes: []string{"Article"}, ModifiedSince: "2024-03-01T10:00:00+01:00"}, filter)
+
+	_, err = withSince(&content.Filter{ModifiedSince: "2024-03-01T10:00:00Z"}, "2024-03-01T10:00:00Z")
+	assert.EqualError(t, err, "pass the since timestamp either as a query parameter or in the filter, not both")
+
+	_, err = withSince(nil, "3000-01-01T00:00:00Z")
+	assert.EqualError(t, err, "invalid since parameter: modifiedSince is in the future")
+}
+
+func TestReadExportBody_Multipart(t *testing.T) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	file, err := writer.CreateFormFile("file", "uuids.txt")
+	require.NoError(t, err)
+	_, _ = file.Write([]byte("a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e\nbad-id\n"))
+
+	ids, err := writer.CreateFormField("ids")
+	require.NoError(t, err)
+	_, _ = ids.Write([]byte("2c1d77f1-c087-495b-bcd7-0680844d622b"))
+
+	other, err := writer.CreateFormField("comment")
+	require.NoError(t, err)
+	_, _ = other.Write([]byte("not an id"))
+	require.NoError(t, writer.Close())
+
+	req, _ := http.NewRequest("POST", "/export", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	body, err := readExportBody(req)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a164336a-7e3e-48ff-a3fe-e1bf1c8c0d4e", "2c1d77f1-c087-495b-bcd7-0680844d622b"}, body.candidates)
+	assert.Equal(t, []string{"bad-id"}, body.rejected)
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Financial-Times/content-exporter/ecsarchive"
+	"github.com/Financial-Times/content-exporter/export"
+)
+
+// maxNotifiedFailures caps the failures listed in a notification. The job itself lists all of them.
+const maxNotifiedFailures = 100
+
+const (
+	jobEndedEvent     = "job.ended"
+	archiveEndedEvent = "ecsarchive.ended"
+)
+
+type notifier interface {
+	Validate(callbackURL string) error
+	Notify(callbackURL, tid string, payload interface{}) error
+}
+
+// notification is posted to the callback URL of a job or an archive once it has ended.
+type notification struct {
+	Event       string          `json:"Event"`
+	Job         *export.Job     `json:"Job,omitempty"`
+	FailedCount int             `json:"FailedCount,omitempty"`
+	Archive     *archiveSummary `json:"Archive,omitempty"`
+}
+
+type archiveSummary struct {
+	Key   string `json:"Key"`
+	State string `json:"State"`
+	URL   string `json:"URL,omitempty"`
+	Error string `json:"Error,omitempty"`
+}
+
+// getCallback returns the optional callback URL of a request.
+func (h *RequestHandler) getCallback(r *http.Request) (string, error) {
+	callbackURL := r.URL.Query().Get("callback")
+	if callbackURL == "" {
+		return "", nil
+	}
+	if h.notifier == nil {
+		return "", fmt.Errorf("callbacks are not enabled")
+	}
+	if err := h.notifier.Validate(callbackURL); err != nil {
+		return "", err
+	}
+	return callbackURL, nil
+}
+
+// notifyJobEnded posts the summary of an ended job to its callback URL, if it has one.
+func (h *RequestHandler) notifyJobEnded(job *export.Job, tid string) {
+	callbackURL := job.CallbackURL()
+	if callbackURL == "" || h.notifier == nil {
+		return
+	}
+
+	summary := job.Copy()
+	failedCount := len(summary.Failed)
+	if failedCount > maxNotifiedFailures {
+		summary.Failed = summary.Failed[:maxNotifiedFailures]
+	}
+
+	log := h.log.WithTransactionID(tid).WithField("jobID", summary.ID)
+	err := h.notifier.Notify(callbackURL, tid, notification{
+		Event:       jobEndedEvent,
+		Job:         &summary,
+		FailedCount: failedCount,
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to notify the end of the job")
+		return
+	}
+	log.Info("Notified the end of the job")
+}
+
+// notifyArchiveEnded posts the outcome of an archive to the callback URL. An archive which another request
+// started generating in the meantime is notified as failed, as its end isn't notified to this callback.
+func (h *RequestHandler) notifyArchiveEnded(callbackURL, key, url string, err error, tid string) {
+	if callbackURL == "" || h.notifier == nil {
+		return
+	}
+
+	summary := &archiveSummary{Key: key, State: "CREATED", URL: url}
+	switch {
+	case errors.Is(err, ecsarchive.ErrArchiveExists):
+		summary.State = "FAILED"
+		summary.Error = "the archive is already being generated by another request"
+	case err != nil:
+		summary.State = "FAILED"
+		summary.Error = err.Error()
+	}
+
+	log := h.log.WithTransactionID(tid).WithField("key", key)
+	if err = h.notifier.Notify(callbackURL, tid, notification{Event: archiveEndedEvent, Archive: summary}); err != nil {
+		log.WithError(err).Warn("Failed to notify the end of the archive")
+		return
+	}
+	log.Info("Notified the end of the archive")
+}
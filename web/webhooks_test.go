@@ -0,0 +1,216 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/content-exporter/content"
+	"github.com/Financial-Times/content-exporter/ecsarchive"
+	"github.com/Financial-Times/content-exporter/export"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notifierMock struct {
+	validateF func(callbackURL string) error
+	notifyF   func(callbackURL, tid string, payload interface{}) error
+}
+
+func (n *notifierMock) Validate(callbackURL string) error {
+	if n.validateF != nil {
+		return n.validateF(callbackURL)
+	}
+	panic("notifierMock.Validate is not implemented")
+}
+
+func (n *notifierMock) Notify(callbackURL, tid string, payload interface{}) error {
+	if n.notifyF != nil {
+		return n.notifyF(callbackURL, tid, payload)
+	}
+	panic("notifierMock.Notify is not implemented")
+}
+
+func TestRequestHandler_ExportWithCallback(t *testing.T) {
+	const callbackURL = "https://hooks.example.com/exports"
+	tests := []struct {
+		name           string
+		notifier       notifier
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name:           "test that a callback without a notifier results in an error",
+			expectedBody:   "{\"error\":\"callbacks are not enabled\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "test that an invalid callback results in an error",
+			notifier: &notifierMock{
+				validateF: func(string) error {
+					return fmt.Errorf("invalid callback URL: it is not allowed")
+				},
+			},
+			expectedBody:   "{\"error\":\"invalid callback URL: it is not allowed\"}",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &exporterMock{
+				getRunningJobsF: func() []export.Job {
+					return []export.Job{}
+				},
+			}
+			h := NewRequestHandler(exporter, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, test.notifier)
+			rr := httptest.NewRecorder()
+			r := mux.NewRouter()
+			req, _ := http.NewRequest("POST", "/export?fullExport=true&callback="+callbackURL, strings.NewReader(``))
+
+			r.HandleFunc("/export", h.Export).Methods("POST")
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			assert.Equal(t, test.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestRequestHandler_NotifiesTheEndOfAnExport(t *testing.T) {
+	const callbackURL = "https://hooks.example.com/exports"
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	notified := make(chan notification, 1)
+	notifier := &notifierMock{
+		validateF: func(url string) error {
+			assert.Equal(t, callbackURL, url)
+			return nil
+		},
+		notifyF: func(url, tid string, payload interface{}) error {
+			assert.Equal(t, callbackURL, url)
+			assert.Equal(t, "tid_test", tid)
+			notified <- payload.(notification)
+			return nil
+		},
+	}
+	exporter := &exporterMock{
+		getRunningJobsF: func() []export.Job {
+			return []export.Job{}
+		},
+		getWorkerCountF: func() int {
+			return 1
+		},
+	}
+	inquirer := &inquirerMock{
+		inquireF: func(ctx context.Context, candidates []string, filter *content.Filter, after string) (*content.Stream, int, error) {
+			docs := make(chan *content.Stub, maxNotifiedFailures+1)
+			for i := 0; i <= maxNotifiedFailures; i++ {
+				docs <- &content.Stub{UUID: fmt.Sprintf("uuid-%03d", i)}
+			}
+			close(docs)
+			return content.NewStream(docs), maxNotifiedFailures + 1, nil
+		},
+	}
+	exporter.exportF = func(tid string, doc *content.Stub) error {
+		return fmt.Errorf("export failed")
+	}
+
+	h := NewRequestHandler(exporter, inquirer, export.NewLocker(), false, 0, log, nil, 0, nil, notifier)
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	req, _ := http.NewRequest("POST", "/export?fullExport=true&callback="+callbackURL, strings.NewReader(``))
+	req.Header.Set("X-Request-Id", "tid_test")
+
+	r.HandleFunc("/export", h.Export).Methods("POST")
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	select {
+	case n := <-notified:
+		assert.Equal(t, jobEndedEvent, n.Event)
+		require.NotNil(t, n.Job)
+		assert.Equal(t, export.FINISHED, n.Job.Status)
+		assert.Equal(t, maxNotifiedFailures+1, n.FailedCount)
+		assert.Len(t, n.Job.Failed, maxNotifiedFailures)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the end of the export was not notified")
+	}
+}
+
+func TestRequestHandler_NotifyArchiveEnded(t *testing.T) {
+	const callbackURL = "https://hooks.example.com/archives"
+
+	tests := []struct {
+		name            string
+		url             string
+		err             error
+		expectedArchive *archiveSummary
+	}{
+		{
+			name:            "test that a created archive is notified with its presigned URL",
+			url:             "https://s3.example.com/2024-03-01-2024-03-02.zip?signature=abc",
+			expectedArchive: &archiveSummary{Key: "2024-03-01-2024-03-02.zip", State: "CREATED", URL: "https://s3.example.com/2024-03-01-2024-03-02.zip?signature=abc"},
+		},
+		{
+			name:            "test that a failed archive is notified with its error",
+			err:             fmt.Errorf("upload failed"),
+			expectedArchive: &archiveSummary{Key: "2024-03-01-2024-03-02.zip", State: "FAILED", Error: "upload failed"},
+		},
+		{
+			name:            "test that an archive generated by another request is notified as failed",
+			err:             ecsarchive.ErrArchiveExists,
+			expectedArchive: &archiveSummary{Key: "2024-03-01-2024-03-02.zip", State: "FAILED", Error: "the archive is already being generated by another request"},
+		},
+	}
+
+	log := logger.NewUPPLogger("test", "PANIC")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var notified *notification
+			notifier := &notifierMock{
+				notifyF: func(url, tid string, payload interface{}) error {
+					n := payload.(notification)
+					notified = &n
+					return nil
+				},
+			}
+			h := NewRequestHandler(&exporterMock{}, &inquirerMock{}, export.NewLocker(), false, 0, log, nil, 0, nil, notifier)
+
+			h.notifyArchiveEnded(callbackURL, "2024-03-01-2024-03-02.zip", test.url, test.err, "tid_test")
+
+			require.NotNil(t, notified)
+			assert.Equal(t, archiveEndedEvent, notified.Event)
+			assert.Equal(t, test.expectedArchive, notified.Archive)
+		})
+	}
+}
+
+func TestRequestHandler_ArchiveWithCallbackRejectsAnExistingArchive(t *testing.T) {
+	ea := ecsarchive.NewECSAarchive(nil, nil, 1)
+	require.NoError(t, ea.CreateArchive("2024-03-01-2024-03-02.zip"))
+	notifier := &notifierMock{
+		validateF: func(string) error {
+			return nil
+		},
+	}
+	h := NewRequestHandler(&exporterMock{}, &inquirerMock{}, export.NewLocker(), false, 0, logger.NewUPPLogger("test", "PANIC"), ea, 48, nil, notifier)
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	req, _ := http.NewRequest("GET", "/ecsarchive/2024-03-01/2024-03-02?callback=https://hooks.example.com/archives", nil)
+
+	r.HandleFunc("/ecsarchive/{startDate}/{endDate}", h.GenerateArticlesZipS3).Methods("GET")
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "{\"error\":\"The archive is already being generated or has been generated, request it without a callback\"}", rr.Body.String())
+}
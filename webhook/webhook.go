@@ -0,0 +1,120 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// SignatureHeader holds the hex encoded HMAC-SHA256 of the request body, keyed with the shared secret
+// and prefixed with "sha256=".
+const SignatureHeader = "X-Signature-256"
+
+// notifyAttempts is how many times a notification is posted while its receiver can't be reached
+// or responds with 429 or 5xx.
+const notifyAttempts = 3
+
+// notifyRetryInterval is the wait before the second attempt. It doubles after each further attempt.
+var notifyRetryInterval = 2 * time.Second
+
+var (
+	ErrInvalidCallback   = errors.New("invalid callback URL")
+	ErrCallbacksDisabled = errors.New("callbacks are not enabled, no webhook secret or allowlist is configured")
+)
+
+type httpClient interface {
+	Do(req *http.Request) (resp *http.Response, err error)
+}
+
+// Notifier posts signed JSON notifications to callback URLs.
+type Notifier struct {
+	client    httpClient
+	secret    []byte
+	allowlist *regexp.Regexp
+}
+
+// NewNotifier returns a notifier signing with the given secret. Without a secret or an allowlist no callback URL
+// is valid, as notifications are never sent unsigned nor to arbitrary hosts.
+func NewNotifier(client httpClient, secret string, allowlist *regexp.Regexp) *Notifier {
+	return &Notifier{
+		client:    client,
+		secret:    []byte(secret),
+		allowlist: allowlist,
+	}
+}
+
+// Validate checks that the callback URL is an absolute HTTP(S) URL matching the allowlist.
+func (n *Notifier) Validate(callbackURL string) error {
+	if len(n.secret) == 0 || n.allowlist == nil {
+		return ErrCallbacksDisabled
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: it should be an absolute http or https URL", ErrInvalidCallback)
+	}
+	if !n.allowlist.MatchString(callbackURL) {
+		return fmt.Errorf("%w: it is not allowed", ErrInvalidCallback)
+	}
+	return nil
+}
+
+// Notify posts the payload as JSON to the callback URL, retrying while the receiver is unavailable.
+func (n *Notifier) Notify(callbackURL, tid string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshaling notification: %w", err)
+	}
+
+	wait := notifyRetryInterval
+	for attempts := 1; ; attempts++ {
+		retry, err := n.post(callbackURL, tid, body)
+		if err == nil || !retry {
+			return err
+		}
+		if attempts == notifyAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+		}
+
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post sends the notification once. It tells whether a failed notification should be retried.
+func (n *Notifier) post(callbackURL, tid string, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Add("User-Agent", "UPP Content Exporter")
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("X-Request-Id", tid)
+	req.Header.Add(SignatureHeader, Sign(n.secret, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("notifying %s failed with unexpected status code: %d", callbackURL, resp.StatusCode)
+	}
+	return false, nil
+}
+
+// Sign returns the value of the signature header of the given body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
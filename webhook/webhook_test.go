@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifier_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		disabled    bool
+		allowlist   *regexp.Regexp
+		callbackURL string
+		expectedErr string
+	}{
+		{
+			name:        "test that no URL is valid without a secret",
+			disabled:    true,
+			allowlist:   regexp.MustCompile(`^https://hooks\.example\.com/`),
+			callbackURL: "https://hooks.example.com/exports",
+			expectedErr: "callbacks are not enabled, no webhook secret or allowlist is configured",
+		},
+		{
+			name:        "test that no URL is valid without an allowlist",
+			callbackURL: "https://hooks.example.com/exports",
+			expectedErr: "callbacks are not enabled, no webhook secret or allowlist is configured",
+		},
+		{
+			name:        "test that a relative URL is invalid",
+			allowlist:   regexp.MustCompile(`.*`),
+			callbackURL: "/exports",
+			expectedErr: "invalid callback URL: it should be an absolute http or https URL",
+		},
+		{
+			name:        "test that another scheme is invalid",
+			allowlist:   regexp.MustCompile(`.*`),
+			callbackURL: "file:///etc/passwd",
+			expectedErr: "invalid callback URL: it should be an absolute http or https URL",
+		},
+		{
+			name:        "test that a URL matching the allowlist is valid",
+			allowlist:   regexp.MustCompile(`^https://hooks\.example\.com/`),
+			callbackURL: "https://hooks.example.com/exports",
+		},
+		{
+			name:        "test that a URL not matching the allowlist is invalid",
+			allowlist:   regexp.MustCompile(`^https://hooks\.example\.com/`),
+			callbackURL: "https://internal.example.com/exports",
+			expectedErr: "invalid callback URL: it is not allowed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secret := "secret"
+			if test.disabled {
+				secret = ""
+			}
+			n := NewNotifier(http.DefaultClient, secret, test.allowlist)
+
+			err := n.Validate(test.callbackURL)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	defer func(interval time.Duration) { notifyRetryInterval = interval }(notifyRetryInterval)
+	notifyRetryInterval = time.Millisecond
+
+	tests := []struct {
+		name             string
+		status           int
+		expectedAttempts int32
+		expectedErr      string
+	}{
+		{
+			name:             "test that a signed notification is posted",
+			status:           http.StatusNoContent,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "test that an unavailable receiver is retried",
+			status:           http.StatusServiceUnavailable,
+			expectedAttempts: notifyAttempts,
+			expectedErr:      "giving up after 3 attempts: notifying %s failed with unexpected status code: 503",
+		},
+		{
+			name:             "test that a rejected notification is not retried",
+			status:           http.StatusBadRequest,
+			expectedAttempts: 1,
+			expectedErr:      "notifying %s failed with unexpected status code: 400",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				assert.Equal(t, "POST", r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.Equal(t, "tid_test", r.Header.Get("X-Request-Id"))
+				assert.Equal(t, `{"ID":"some-job"}`, string(body))
+				assert.Equal(t, Sign([]byte("secret"), body), r.Header.Get(SignatureHeader))
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			n := NewNotifier(http.DefaultClient, "secret", regexp.MustCompile(`.*`))
+			err := n.Notify(server.URL, "tid_test", map[string]string{"ID": "some-job"})
+
+			assert.Equal(t, test.expectedAttempts, attempts.Load())
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, fmt.Sprintf(test.expectedErr, server.URL))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"ID":"some-job"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=f9dfe28af3259af35be81e1bbfba83af764a28583c6ec420dfcf482d5abeae57", Sign([]byte("secret"), []byte(`{"ID":"some-job"}`)))
+}